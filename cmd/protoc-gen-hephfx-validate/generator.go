@@ -0,0 +1,206 @@
+package main
+
+import (
+	"path"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"google.golang.org/protobuf/compiler/protogen"
+	"google.golang.org/protobuf/proto"
+
+	"github.com/daheige/hephfx/cmd/protoc-gen-hephfx-validate/hephfx"
+)
+
+const validatorPackage = protogen.GoImportPath("github.com/go-playground/validator/v10")
+
+// structRules the validator map rules of the go struct.
+type structRules struct {
+	ident protogen.GoIdent
+	rules [][2]string // the go field name and the rules in field order
+}
+
+// generate generates validator.go for each go package,
+// and <file>.validate.go for each proto file which has the request messages or the field rules.
+func generate(gen *protogen.Plugin) error {
+	// the input messages of the methods are the request messages
+	requests := make(map[protogen.GoIdent]bool)
+	for _, f := range gen.Files {
+		if !f.Generate {
+			continue
+		}
+
+		for _, s := range f.Services {
+			for _, m := range s.Methods {
+				requests[m.Input.GoIdent] = true
+			}
+		}
+	}
+
+	packages := make(map[protogen.GoImportPath]bool)
+	for _, f := range gen.Files {
+		if !f.Generate || !generateFile(gen, f, requests) {
+			continue
+		}
+
+		if !packages[f.GoImportPath] {
+			packages[f.GoImportPath] = true
+			generateValidator(gen, f)
+		}
+	}
+
+	return nil
+}
+
+// generateValidator generates the validator instance shared by the files of the go package.
+func generateValidator(gen *protogen.Plugin, f *protogen.File) {
+	filename := path.Join(path.Dir(f.GeneratedFilenamePrefix), "validator.go")
+	g := gen.NewGeneratedFile(filename, f.GoImportPath)
+	g.P("// Code generated by protoc-gen-hephfx-validate. DO NOT EDIT.")
+	g.P()
+	g.P("package ", f.GoPackageName)
+	g.P()
+	// import with the package name instead of the v10 path base
+	g.P("import ", strconv.Quote(string(validatorPackage)))
+	g.P()
+	g.P("var validate = validator.New()")
+	g.P()
+	g.P("// Validator returns the validator of the request messages,eg: register the translations.")
+	g.P("func Validator() *validator.Validate {")
+	g.P("return validate")
+	g.P("}")
+}
+
+// generateFile generates the Validate() methods and the field rules of the file,
+// it returns false when there is nothing to generate.
+func generateFile(gen *protogen.Plugin, f *protogen.File, requests map[protogen.GoIdent]bool) bool {
+	var (
+		messages []*protogen.Message
+		rules    []structRules
+	)
+	walkMessages(f.Messages, func(m *protogen.Message) {
+		if requests[m.GoIdent] {
+			messages = append(messages, m)
+		}
+
+		rules = append(rules, messageRules(m)...)
+	})
+
+	if len(messages) == 0 && len(rules) == 0 {
+		return false
+	}
+
+	g := gen.NewGeneratedFile(f.GeneratedFilenamePrefix+".validate.go", f.GoImportPath)
+	g.P("// Code generated by protoc-gen-hephfx-validate. DO NOT EDIT.")
+	g.P("// source: ", f.Desc.Path())
+	g.P()
+	g.P("package ", f.GoPackageName)
+	g.P()
+
+	if len(rules) > 0 {
+		g.P("func init() {")
+		for _, r := range rules {
+			g.P("validate.RegisterStructValidationMapRules(map[string]string{")
+			for _, rule := range r.rules {
+				g.P(strconv.Quote(rule[0]), ": ", strconv.Quote(rule[1]), ",")
+			}
+			g.P("}, (*", r.ident, ")(nil))")
+		}
+		g.P("}")
+		g.P()
+	}
+
+	for _, m := range messages {
+		g.P("// Validate validates the ", m.GoIdent, " by the validator rules.")
+		g.P("func (r *", m.GoIdent, ") Validate() error {")
+		g.P("return validate.Struct(r)")
+		g.P("}")
+		g.P()
+	}
+
+	return true
+}
+
+// walkMessages calls fn for the messages and the nested messages,the map entries are skipped.
+func walkMessages(messages []*protogen.Message, fn func(m *protogen.Message)) {
+	for _, m := range messages {
+		if m.Desc.IsMapEntry() {
+			continue
+		}
+
+		fn(m)
+		walkMessages(m.Messages, fn)
+	}
+}
+
+// messageRules returns the field rules of the message,
+// the rules of the oneof fields belong to the oneof wrapper structs.
+func messageRules(m *protogen.Message) []structRules {
+	message := structRules{ident: m.GoIdent}
+	var oneofs []structRules
+	for _, field := range m.Fields {
+		rules := fieldRules(field)
+		if rules == "" {
+			continue
+		}
+
+		if field.Oneof != nil && !field.Oneof.Desc.IsSynthetic() {
+			oneofs = append(oneofs, structRules{ident: field.GoIdent, rules: [][2]string{{field.GoName, rules}}})
+			continue
+		}
+
+		message.rules = append(message.rules, [2]string{field.GoName, rules})
+	}
+
+	if len(message.rules) == 0 {
+		return oneofs
+	}
+
+	return append([]structRules{message}, oneofs...)
+}
+
+// fieldRules returns the rules of the (hephfx.rules) option or the @inject_tag validate tag,
+// the elements of the repeated and map message fields are validated by dive.
+func fieldRules(field *protogen.Field) string {
+	rules, _ := proto.GetExtension(field.Desc.Options(), hephfx.E_Rules).(string)
+	if rules == "" {
+		rules = injectTagRules(string(field.Comments.Leading))
+	}
+
+	if rules == "" {
+		rules = injectTagRules(string(field.Comments.Trailing))
+	}
+
+	if field.Message != nil && (field.Desc.IsList() || field.Desc.IsMap()) && !hasDive(rules) {
+		if rules == "" {
+			return "dive"
+		}
+
+		return rules + ",dive"
+	}
+
+	return rules
+}
+
+// injectTagRules returns the validate tag of the protoc-go-inject-tag comment,
+// eg: // @inject_tag: json:"name" validate:"required,min=1"
+func injectTagRules(comments string) string {
+	for _, line := range strings.Split(comments, "\n") {
+		_, tag, ok := strings.Cut(line, "@inject_tag:")
+		if ok {
+			return reflect.StructTag(strings.TrimSpace(tag)).Get("validate")
+		}
+	}
+
+	return ""
+}
+
+func hasDive(rules string) bool {
+	for _, rule := range strings.Split(rules, ",") {
+		if rule == "dive" {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,116 @@
+package main
+
+import (
+	"flag"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"google.golang.org/protobuf/compiler/protogen"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/descriptorpb"
+	"google.golang.org/protobuf/types/pluginpb"
+)
+
+var update = flag.Bool("update", false, "update the golden files")
+
+// The descriptor sets of testdata are generated by:
+//
+//	protoc -I testdata/protos -I . --include_imports --include_source_info \
+//	    -o testdata/user.pb testdata/protos/user.proto
+//	protoc -I ../../example/protos -I . --include_imports --include_source_info \
+//	    -o testdata/hello.pb ../../example/protos/hello.proto
+func TestGolden(t *testing.T) {
+	tests := []struct {
+		name    string
+		set     string
+		file    string
+		outputs []string
+	}{
+		{"hello", "testdata/hello.pb", "hello.proto", []string{"validator.go", "hello.validate.go"}},
+		{"user", "testdata/user.pb", "user.proto", []string{"validator.go", "user.validate.go"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			files := runGenerator(t, tt.set, tt.file)
+			if len(files) != len(tt.outputs) {
+				t.Fatalf("generated files = %v", files)
+			}
+
+			for _, name := range tt.outputs {
+				content, ok := files[name]
+				if !ok {
+					t.Fatalf("%s is not generated", name)
+				}
+
+				golden := filepath.Join("testdata", "golden", tt.name, name+".golden")
+				if *update {
+					if err := os.MkdirAll(filepath.Dir(golden), 0755); err != nil {
+						t.Fatal(err)
+					}
+					if err := os.WriteFile(golden, []byte(content), 0644); err != nil {
+						t.Fatal(err)
+					}
+				}
+
+				want, err := os.ReadFile(golden)
+				if err != nil {
+					t.Fatal(err)
+				}
+
+				if content != string(want) {
+					t.Errorf("%s differs from %s,run go test -update to update:\n%s", name, golden, content)
+				}
+			}
+		})
+	}
+}
+
+func TestNoRequests(t *testing.T) {
+	// the extension file has no messages,nothing is generated
+	if files := runGenerator(t, "testdata/user.pb", "hephfx/validate.proto"); len(files) != 0 {
+		t.Fatalf("generated files = %v", files)
+	}
+}
+
+// runGenerator runs the generator with the descriptor set like protoc,
+// returns the generated file contents by name.
+func runGenerator(t *testing.T, set string, fileToGenerate string) map[string]string {
+	t.Helper()
+
+	data, err := os.ReadFile(set)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	fds := &descriptorpb.FileDescriptorSet{}
+	if err = proto.Unmarshal(data, fds); err != nil {
+		t.Fatal(err)
+	}
+
+	plugin, err := protogen.Options{}.New(&pluginpb.CodeGeneratorRequest{
+		FileToGenerate: []string{fileToGenerate},
+		Parameter:      proto.String("paths=source_relative"),
+		ProtoFile:      fds.File,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err = generate(plugin); err != nil {
+		t.Fatal(err)
+	}
+
+	resp := plugin.Response()
+	if resp.Error != nil {
+		t.Fatal(resp.GetError())
+	}
+
+	files := make(map[string]string, len(resp.File))
+	for _, f := range resp.File {
+		files[f.GetName()] = f.GetContent()
+	}
+
+	return files
+}
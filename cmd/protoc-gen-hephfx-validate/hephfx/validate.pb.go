@@ -0,0 +1,87 @@
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// versions:
+// 	protoc-gen-go v1.36.11
+// 	protoc        (unknown)
+// source: hephfx/validate.proto
+
+// hephfx validate field options for protoc-gen-hephfx-validate.
+
+package hephfx
+
+import (
+	protoreflect "google.golang.org/protobuf/reflect/protoreflect"
+	protoimpl "google.golang.org/protobuf/runtime/protoimpl"
+	descriptorpb "google.golang.org/protobuf/types/descriptorpb"
+	reflect "reflect"
+	unsafe "unsafe"
+)
+
+const (
+	// Verify that this generated code is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(20 - protoimpl.MinVersion)
+	// Verify that runtime/protoimpl is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
+)
+
+var file_hephfx_validate_proto_extTypes = []protoimpl.ExtensionInfo{
+	{
+		ExtendedType:  (*descriptorpb.FieldOptions)(nil),
+		ExtensionType: (*string)(nil),
+		Field:         50100,
+		Name:          "hephfx.rules",
+		Tag:           "bytes,50100,opt,name=rules",
+		Filename:      "hephfx/validate.proto",
+	},
+}
+
+// Extension fields to descriptorpb.FieldOptions.
+var (
+	// rules the go-playground/validator rules of the field,eg: required,min=1
+	// string name = 1 [(hephfx.rules) = "required,min=1"];
+	//
+	// optional string rules = 50100;
+	E_Rules = &file_hephfx_validate_proto_extTypes[0]
+)
+
+var File_hephfx_validate_proto protoreflect.FileDescriptor
+
+const file_hephfx_validate_proto_rawDesc = "" +
+	"\n" +
+	"\x15hephfx/validate.proto\x12\x06hephfx\x1a google/protobuf/descriptor.proto:5\n" +
+	"\x05rules\x12\x1d.google.protobuf.FieldOptions\x18\xb4\x87\x03 \x01(\tR\x05rulesBHZFgithub.com/daheige/hephfx/cmd/protoc-gen-hephfx-validate/hephfx;hephfxb\x06proto3"
+
+var file_hephfx_validate_proto_goTypes = []any{
+	(*descriptorpb.FieldOptions)(nil), // 0: google.protobuf.FieldOptions
+}
+var file_hephfx_validate_proto_depIdxs = []int32{
+	0, // 0: hephfx.rules:extendee -> google.protobuf.FieldOptions
+	1, // [1:1] is the sub-list for method output_type
+	1, // [1:1] is the sub-list for method input_type
+	1, // [1:1] is the sub-list for extension type_name
+	0, // [0:1] is the sub-list for extension extendee
+	0, // [0:0] is the sub-list for field type_name
+}
+
+func init() { file_hephfx_validate_proto_init() }
+func file_hephfx_validate_proto_init() {
+	if File_hephfx_validate_proto != nil {
+		return
+	}
+	type x struct{}
+	out := protoimpl.TypeBuilder{
+		File: protoimpl.DescBuilder{
+			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
+			RawDescriptor: unsafe.Slice(unsafe.StringData(file_hephfx_validate_proto_rawDesc), len(file_hephfx_validate_proto_rawDesc)),
+			NumEnums:      0,
+			NumMessages:   0,
+			NumExtensions: 1,
+			NumServices:   0,
+		},
+		GoTypes:           file_hephfx_validate_proto_goTypes,
+		DependencyIndexes: file_hephfx_validate_proto_depIdxs,
+		ExtensionInfos:    file_hephfx_validate_proto_extTypes,
+	}.Build()
+	File_hephfx_validate_proto = out.File
+	file_hephfx_validate_proto_goTypes = nil
+	file_hephfx_validate_proto_depIdxs = nil
+}
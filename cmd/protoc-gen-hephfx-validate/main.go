@@ -0,0 +1,34 @@
+// protoc-gen-hephfx-validate generates the Validate() methods of the request messages
+// for the request validator interceptor of micro.
+//
+// The validator rules of the fields are read from the (hephfx.rules) field option,
+// or from the validate struct tag of the @inject_tag comment:
+//
+//	import "hephfx/validate.proto";
+//
+//	message HelloReq {
+//	    string name = 1 [(hephfx.rules) = "required,min=1"];
+//
+//	    // @inject_tag: json:"age" validate:"gte=18"
+//	    int32 age = 2;
+//	}
+//
+// Install and run it with protoc:
+//
+//	go install github.com/daheige/hephfx/cmd/protoc-gen-hephfx-validate@latest
+//	protoc -I ./protos -I $(hephfx)/cmd/protoc-gen-hephfx-validate \
+//	    --hephfx-validate_out ./pb --hephfx-validate_opt paths=source_relative \
+//	    ./protos/*.proto
+package main
+
+import (
+	"google.golang.org/protobuf/compiler/protogen"
+	"google.golang.org/protobuf/types/pluginpb"
+)
+
+func main() {
+	protogen.Options{}.Run(func(gen *protogen.Plugin) error {
+		gen.SupportedFeatures = uint64(pluginpb.CodeGeneratorResponse_FEATURE_PROTO3_OPTIONAL)
+		return generate(gen)
+	})
+}
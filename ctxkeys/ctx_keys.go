@@ -34,4 +34,10 @@ var (
 
 	// FullStack full stack
 	FullStack = CtxKey{"full_stack"}
+
+	// Principal the authenticated principal of the request
+	Principal = CtxKey{"principal"}
+
+	// PathParams the path params of the http route
+	PathParams = CtxKey{"path_params"}
 )
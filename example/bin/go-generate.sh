@@ -41,7 +41,10 @@ $protoExec -I $proto_dir --grpc-gateway_out $pb_dir \
 sh $root_dir/bin/protoc-inject-tag.sh
 
 # gen request validator code
-sh $root_dir/bin/validator-generate.sh
+# go install github.com/daheige/hephfx/cmd/protoc-gen-hephfx-validate@latest
+$protoExec -I $proto_dir -I $root_dir/../cmd/protoc-gen-hephfx-validate \
+    --hephfx-validate_out $pb_dir --hephfx-validate_opt paths=source_relative \
+    $proto_dir/*.proto
 
 # cp golang client code
 mkdir -p $root_dir/clients/go/pb
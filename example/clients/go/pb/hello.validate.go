@@ -0,0 +1,15 @@
+// Code generated by protoc-gen-hephfx-validate. DO NOT EDIT.
+// source: hello.proto
+
+package pb
+
+func init() {
+	validate.RegisterStructValidationMapRules(map[string]string{
+		"Name": "required,min=1",
+	}, (*HelloReq)(nil))
+}
+
+// Validate validates the HelloReq by the validator rules.
+func (r *HelloReq) Validate() error {
+	return validate.Struct(r)
+}
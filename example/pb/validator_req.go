@@ -1,7 +0,0 @@
-// Code generated by validator_gen tools.DO NOT EDIT.
-package pb
-
-// Validate HelloReq interceptor validator.
-func (r *HelloReq) Validate() error {
-	return validate.Struct(r)
-}
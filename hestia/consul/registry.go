@@ -7,6 +7,7 @@ import (
 	"net"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	consulapi "github.com/hashicorp/consul/api"
@@ -21,10 +22,13 @@ type consulRegistry struct {
 	client          *consulapi.Client
 	ttl             string
 	deregisterAfter string
-	keepaliveCtx    context.Context
-	keepaliveCancel context.CancelFunc
 	validateAddress bool
 	prefix          string
+
+	// keepalive cancel functions by checkID,
+	// so that several service instances can be registered on one registry.
+	mu         sync.Mutex
+	keepalives map[string]context.CancelFunc
 }
 
 // NewRegistry create a consul Registry instance
@@ -52,6 +56,7 @@ func NewRegistry(endpoints []string, opts ...Option) (hestia.Registry, error) {
 		deregisterAfter: opt.deregisterCriticalServiceAfter,
 		validateAddress: opt.validateAddress,
 		prefix:          opt.prefix,
+		keepalives:      make(map[string]context.CancelFunc),
 	}
 
 	return r, nil
@@ -106,9 +111,7 @@ func (r *consulRegistry) Register(ctx context.Context, s *hestia.Service) error
 	}
 
 	// Start keepalive goroutine
-	r.stopKeepalive()
-	r.keepaliveCtx, r.keepaliveCancel = context.WithCancel(context.Background())
-	go r.keepalive(r.keepaliveCtx, checkID)
+	r.startKeepalive(checkID)
 
 	log.Printf("consul register service:%s version:%s instanceID:%s host:%s port:%d checkID:%s success\n",
 		s.Name, s.Version, s.InstanceID, host, port, checkID)
@@ -121,9 +124,9 @@ func (r *consulRegistry) Deregister(ctx context.Context, s *hestia.Service) erro
 		return fmt.Errorf("missing service name in Deregister")
 	}
 
-	r.stopKeepalive()
-
 	checkID := buildCheckID(s.InstanceID)
+	r.stopKeepalive(checkID)
+
 	// deregister the check first
 	if err := r.client.Agent().CheckDeregister(checkID); err != nil {
 		log.Printf("consul deregister check %s warning: %v", checkID, err)
@@ -146,9 +149,26 @@ func (r *consulRegistry) String() string {
 	return "consul"
 }
 
-func (r *consulRegistry) stopKeepalive() {
-	if r.keepaliveCancel != nil {
-		r.keepaliveCancel()
+func (r *consulRegistry) startKeepalive(checkID string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if cancel, ok := r.keepalives[checkID]; ok {
+		cancel()
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	r.keepalives[checkID] = cancel
+	go r.keepalive(ctx, checkID)
+}
+
+func (r *consulRegistry) stopKeepalive(checkID string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if cancel, ok := r.keepalives[checkID]; ok {
+		cancel()
+		delete(r.keepalives, checkID)
 	}
 }
 
@@ -7,6 +7,7 @@ import (
 	"fmt"
 	"log"
 	"strings"
+	"sync"
 	"time"
 
 	clientv3 "go.etcd.io/etcd/client/v3"
@@ -20,15 +21,17 @@ var _ hestia.Registry = (*etcdRegistry)(nil)
 type etcdRegistry struct {
 	client          *clientv3.Client
 	leaseTTL        int64
-	meta            *registerMeta
 	prefix          string
-	keepaliveCtx    context.Context
-	keepaliveCancel context.CancelFunc
 	validateAddress bool // 默认为false，不校验
+
+	// 按注册key保存每个服务实例的租约信息，支持同一个registry注册多个服务实例
+	mu    sync.Mutex
+	metas map[string]*registerMeta
 }
 
 type registerMeta struct {
 	leaseID clientv3.LeaseID
+	cancel  context.CancelFunc // 停止keepalive
 }
 
 // NewRegistry create a registry interface instance
@@ -54,6 +57,7 @@ func NewRegistry(endpoints []string, opts ...Option) (hestia.Registry, error) {
 		leaseTTL:        opt.leaseTTL,
 		prefix:          opt.prefix,
 		validateAddress: opt.validateAddress,
+		metas:           make(map[string]*registerMeta),
 	}
 
 	e.prefix = strings.TrimPrefix(e.prefix, "/")
@@ -102,23 +106,46 @@ func (e *etcdRegistry) Register(ctx context.Context, s *hestia.Service) error {
 		return err
 	}
 
+	keepaliveCtx, cancel := context.WithCancel(context.Background())
 	meta := &registerMeta{
 		leaseID: leaseID,
+		cancel:  cancel,
 	}
 
-	if e.keepaliveCancel != nil {
-		e.keepaliveCancel()
-	}
-	e.keepaliveCtx, e.keepaliveCancel = context.WithCancel(context.Background())
-	err = e.keepalive(e.keepaliveCtx, meta)
+	err = e.keepalive(keepaliveCtx, meta)
 	if err != nil {
+		cancel()
 		return err
 	}
 
-	e.meta = meta
+	e.storeMeta(e.registerKey(s), meta)
 	return nil
 }
 
+// storeMeta saves the lease meta of the service instance,
+// the keepalive of the previous registration is stopped.
+func (e *etcdRegistry) storeMeta(key string, meta *registerMeta) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if prev, ok := e.metas[key]; ok {
+		prev.cancel()
+	}
+
+	e.metas[key] = meta
+}
+
+// stopKeepalive stops the keepalive of the service instance.
+func (e *etcdRegistry) stopKeepalive(key string) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if meta, ok := e.metas[key]; ok {
+		meta.cancel()
+		delete(e.metas, key)
+	}
+}
+
 func (e *etcdRegistry) register(ctx context.Context, s *hestia.Service, leaseID clientv3.LeaseID) error {
 	b, err := json.Marshal(s)
 	if err != nil {
@@ -207,9 +234,7 @@ func (e *etcdRegistry) deregister(ctx context.Context, s *hestia.Service) error
 	log.Printf("deregister prefix:%s service:%v version:%s instanceID:%v success\n",
 		e.prefix, s.Name, s.Version, s.InstanceID)
 
-	if e.keepaliveCancel != nil {
-		e.keepaliveCancel()
-	}
+	e.stopKeepalive(key)
 
 	s.Healthy = false
 	return nil
@@ -37,8 +37,8 @@ func Resolve(address string) (string, error) {
 		return "", err
 	}
 
-	// if host is empty or "::", use local ipv4 address as host
-	if host == "" || host == "::" {
+	// if host is empty or unspecified address, use local ipv4 address as host
+	if host == "" || host == "::" || host == "0.0.0.0" {
 		host, err = localIPv4Host()
 		if err != nil {
 			return "", fmt.Errorf("parse address host error: %w", err)
@@ -7,7 +7,9 @@ import (
 	"testing"
 	"time"
 
+	"go.opentelemetry.io/otel/trace"
 	"go.uber.org/zap"
+	"go.uber.org/zap/zaptest/observer"
 
 	"github.com/daheige/hephfx/ctxkeys"
 )
@@ -67,6 +69,55 @@ func TestLogger(t *testing.T) {
 	log.Println("exit...")
 }
 
+// TestWithCtxKeys test ctx keys fields.
+func TestWithCtxKeys(t *testing.T) {
+	core, logs := observer.New(zap.InfoLevel)
+	tenantID := ctxkeys.CtxKey{Name: "x-tenant-id"}
+	logger := New(WithStdout(false), WithCores(core), WithCtxKeys(tenantID))
+
+	ctx := context.WithValue(context.Background(), tenantID, "tenant-1")
+	logger.Info(ctx, "hello")
+
+	entries := logs.All()
+	if len(entries) != 1 {
+		t.Fatalf("log entries = %d, want 1", len(entries))
+	}
+
+	if got := entries[0].ContextMap()["x-tenant-id"]; got != "tenant-1" {
+		t.Fatalf("x-tenant-id = %v, want tenant-1", got)
+	}
+}
+
+// TestTraceFields test trace_id and span_id fields.
+func TestTraceFields(t *testing.T) {
+	core, logs := observer.New(zap.InfoLevel)
+	logger := New(WithStdout(false), WithCores(core))
+
+	traceID, _ := trace.TraceIDFromHex("4bf92f3577b34da6a3ce929d0e0e4736")
+	spanID, _ := trace.SpanIDFromHex("00f067aa0ba902b7")
+	ctx := trace.ContextWithSpanContext(context.Background(), trace.NewSpanContext(trace.SpanContextConfig{
+		TraceID:    traceID,
+		SpanID:     spanID,
+		TraceFlags: trace.FlagsSampled,
+	}))
+	logger.Info(ctx, "hello")
+	logger.Info(context.Background(), "no trace")
+
+	entries := logs.All()
+	if len(entries) != 2 {
+		t.Fatalf("log entries = %d, want 2", len(entries))
+	}
+
+	fields := entries[0].ContextMap()
+	if fields["trace_id"] != traceID.String() || fields["span_id"] != spanID.String() {
+		t.Fatalf("unexpected trace fields: %v", fields)
+	}
+
+	if _, ok := entries[1].ContextMap()["trace_id"]; ok {
+		t.Fatalf("trace_id is logged without span context")
+	}
+}
+
 // TestNewLogSugar test log sugar.
 func TestNewLogSugar(t *testing.T) {
 	// 测试log sugar方法
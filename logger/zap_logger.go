@@ -12,6 +12,7 @@ import (
 	"time"
 
 	"github.com/getsentry/sentry-go"
+	"go.opentelemetry.io/otel/trace"
 	"go.uber.org/zap"
 	"go.uber.org/zap/zapcore"
 	"gopkg.in/natefinch/lumberjack.v2"
@@ -49,6 +50,9 @@ type zapLogWriter struct {
 	// hostname host
 	hostname string
 
+	// ctxKeys 需要从ctx上面额外输出的字段
+	ctxKeys []ctxkeys.CtxKey
+
 	// zap底层Logger接口
 	fLogger *zap.Logger
 
@@ -252,6 +256,19 @@ func (z *zapLogWriter) parseCtxFields(ctx context.Context) []zap.Field {
 		fields = append(fields, zap.String(ctxkeys.RequestURI.String(), uri))
 	}
 
+	// opentelemetry trace_id,span_id 存在就记录
+	if sc := trace.SpanContextFromContext(ctx); sc.IsValid() {
+		fields = append(fields, zap.String("trace_id", sc.TraceID().String()),
+			zap.String("span_id", sc.SpanID().String()))
+	}
+
+	// 额外设置的ctx字段，存在就记录
+	for _, key := range z.ctxKeys {
+		if val, ok := ctx.Value(key).(string); ok {
+			fields = append(fields, zap.String(key.String(), val))
+		}
+	}
+
 	return fields
 }
 
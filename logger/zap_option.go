@@ -4,6 +4,8 @@ import (
 	"time"
 
 	"go.uber.org/zap/zapcore"
+
+	"github.com/daheige/hephfx/ctxkeys"
 )
 
 // Option option for zapLogWriter
@@ -143,3 +145,11 @@ func WithSentryLevel(level zapcore.Level) Option {
 		z.sentryLevel = level
 	}
 }
+
+// WithCtxKeys 设置需要从ctx上面额外输出的字段
+// 例如：微服务透传的metadata header,ctx value必须是字符串
+func WithCtxKeys(keys ...ctxkeys.CtxKey) Option {
+	return func(z *zapLogWriter) {
+		z.ctxKeys = append(z.ctxKeys, keys...)
+	}
+}
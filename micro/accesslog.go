@@ -0,0 +1,291 @@
+package micro
+
+import (
+	"context"
+	"math/rand/v2"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+
+	"github.com/daheige/hephfx/ctxkeys"
+	"github.com/daheige/hephfx/logger"
+	"github.com/daheige/hephfx/micro/gerrors"
+)
+
+// accessLogMessage the message of access log entries.
+const accessLogMessage = "gRPC access log"
+
+// AccessLogOption access log interceptor option
+type AccessLogOption func(a *accessLog)
+
+// accessLog writes structured access logs through logger.Logger.
+type accessLog struct {
+	logger logger.Logger
+
+	// the ratio of the successful calls to be logged,range: [0,1],default: 1.
+	// the failed calls and the slow calls are always logged.
+	sampleRate float64
+
+	// the calls which take longer than slowThreshold are logged at warn level,0 means disabled
+	slowThreshold time.Duration
+
+	// log the request message,the mask fields are masked by logger.MaskString
+	logRequest bool
+	maskFields map[string]bool
+
+	// the methods are not logged,eg: /grpc.health.v1.Health/Check
+	skipMethods map[string]bool
+}
+
+// WithAccessLogSampleRate returns an AccessLogOption to set the ratio of the successful calls to be logged.
+// The failed calls and slow calls are always logged.
+func WithAccessLogSampleRate(rate float64) AccessLogOption {
+	return func(a *accessLog) {
+		a.sampleRate = rate
+	}
+}
+
+// WithAccessLogSlowThreshold returns an AccessLogOption to log the calls which take longer
+// than threshold at warn level.
+func WithAccessLogSlowThreshold(threshold time.Duration) AccessLogOption {
+	return func(a *accessLog) {
+		a.slowThreshold = threshold
+	}
+}
+
+// WithAccessLogRequest returns an AccessLogOption to log the request message.
+func WithAccessLogRequest() AccessLogOption {
+	return func(a *accessLog) {
+		a.logRequest = true
+	}
+}
+
+// WithAccessLogMaskFields returns an AccessLogOption to mask the request fields by logger.MaskString,
+// the fields are matched by the proto name or json name at any depth,eg: phone,id_card.
+func WithAccessLogMaskFields(fields ...string) AccessLogOption {
+	return func(a *accessLog) {
+		for _, field := range fields {
+			a.maskFields[field] = true
+		}
+	}
+}
+
+// WithAccessLogSkipMethods returns an AccessLogOption to skip the access log of the full methods.
+func WithAccessLogSkipMethods(methods ...string) AccessLogOption {
+	return func(a *accessLog) {
+		for _, method := range methods {
+			a.skipMethods[method] = true
+		}
+	}
+}
+
+func newAccessLog(l logger.Logger, opts ...AccessLogOption) *accessLog {
+	a := &accessLog{
+		logger:      l,
+		sampleRate:  1,
+		maskFields:  make(map[string]bool),
+		skipMethods: make(map[string]bool),
+	}
+
+	for _, o := range opts {
+		o(a)
+	}
+
+	return a
+}
+
+// AccessLogUnaryInterceptor returns a server unary interceptor which writes structured access logs
+// through logger.Logger.
+func AccessLogUnaryInterceptor(l logger.Logger, opts ...AccessLogOption) grpc.UnaryServerInterceptor {
+	a := newAccessLog(l, opts...)
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo,
+		handler grpc.UnaryHandler) (interface{}, error) {
+		if a.skipMethods[info.FullMethod] {
+			return handler(ctx, req)
+		}
+
+		start := time.Now()
+		reply, err := handler(ctx, req)
+		a.log(ctx, info.FullMethod, start, err, messageSize(req), messageSize(reply), req)
+		return reply, err
+	}
+}
+
+// AccessLogStreamInterceptor returns a server stream interceptor which writes structured access logs
+// through logger.Logger,the request and response sizes are the total sizes of the stream messages.
+func AccessLogStreamInterceptor(l logger.Logger, opts ...AccessLogOption) grpc.StreamServerInterceptor {
+	a := newAccessLog(l, opts...)
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		if a.skipMethods[info.FullMethod] {
+			return handler(srv, ss)
+		}
+
+		start := time.Now()
+		stream := &sizeServerStream{ServerStream: ss}
+		err := handler(srv, stream)
+		a.log(ss.Context(), info.FullMethod, start, err, stream.recvSize, stream.sendSize, nil)
+		return err
+	}
+}
+
+// sizeServerStream counts the sizes of received and sent messages.
+type sizeServerStream struct {
+	grpc.ServerStream
+	recvSize int
+	sendSize int
+}
+
+func (s *sizeServerStream) SendMsg(m interface{}) error {
+	err := s.ServerStream.SendMsg(m)
+	if err == nil {
+		s.sendSize += messageSize(m)
+	}
+
+	return err
+}
+
+func (s *sizeServerStream) RecvMsg(m interface{}) error {
+	err := s.ServerStream.RecvMsg(m)
+	if err == nil {
+		s.recvSize += messageSize(m)
+	}
+
+	return err
+}
+
+func (a *accessLog) log(ctx context.Context, method string, start time.Time, err error,
+	requestSize int, responseSize int, req interface{}) {
+	latency := time.Since(start)
+	code := status.Code(err)
+	slow := a.slowThreshold > 0 && latency > a.slowThreshold
+	if code == codes.OK && !slow && a.sampleRate < 1 && rand.Float64() >= a.sampleRate {
+		return
+	}
+
+	md := IncomingMD(ctx)
+	fields := []interface{}{
+		"method", method,
+		"code", code.String(),
+		"latency_ms", float64(latency.Microseconds()) / 1000,
+		"request_size", requestSize,
+		"response_size", responseSize,
+		ctxkeys.UserAgent.String(), userAgent(md),
+	}
+
+	if pr, ok := peer.FromContext(ctx); ok && pr.Addr != nil {
+		fields = append(fields, "peer", pr.Addr.String())
+	}
+
+	if slow {
+		fields = append(fields, "slow", true)
+	}
+
+	if err != nil {
+		fields = append(fields, "error", status.Convert(err).Message())
+	}
+
+	if a.logRequest && req != nil {
+		fields = append(fields, "request", a.maskRequest(req))
+	}
+
+	// the logger picks up x-request-id from the context value
+	if _, ok := ctx.Value(ctxkeys.XRequestID).(string); !ok {
+		if requestID := GetStringFromMD(md, ctxkeys.XRequestID); requestID != "" {
+			ctx = context.WithValue(ctx, ctxkeys.XRequestID, requestID)
+		}
+	}
+
+	switch {
+	case gerrors.IsServerErrorCode(code):
+		a.logger.Error(ctx, accessLogMessage, fields...)
+	case code != codes.OK || slow:
+		a.logger.Warn(ctx, accessLogMessage, fields...)
+	default:
+		a.logger.Info(ctx, accessLogMessage, fields...)
+	}
+}
+
+// maskRequest returns the json string of the request with the mask fields masked.
+func (a *accessLog) maskRequest(req interface{}) interface{} {
+	msg, ok := req.(proto.Message)
+	if !ok {
+		return req
+	}
+
+	if len(a.maskFields) > 0 {
+		msg = proto.Clone(msg)
+		a.maskMessage(msg.ProtoReflect())
+	}
+
+	b, err := protojson.MarshalOptions{UseProtoNames: true}.Marshal(msg)
+	if err != nil {
+		return err.Error()
+	}
+
+	return string(b)
+}
+
+// maskMessage masks the string fields of the message recursively.
+func (a *accessLog) maskMessage(m protoreflect.Message) {
+	m.Range(func(fd protoreflect.FieldDescriptor, v protoreflect.Value) bool {
+		masked := a.maskFields[string(fd.Name())] || a.maskFields[fd.JSONName()]
+		switch {
+		case fd.IsList():
+			list := v.List()
+			for i := 0; i < list.Len(); i++ {
+				if fd.Kind() == protoreflect.StringKind && masked {
+					list.Set(i, protoreflect.ValueOfString(logger.MaskString(list.Get(i).String())))
+				} else if fd.Message() != nil {
+					a.maskMessage(list.Get(i).Message())
+				}
+			}
+		case fd.IsMap():
+			v.Map().Range(func(k protoreflect.MapKey, mv protoreflect.Value) bool {
+				if fd.MapValue().Kind() == protoreflect.StringKind && masked {
+					v.Map().Set(k, protoreflect.ValueOfString(logger.MaskString(mv.String())))
+				} else if fd.MapValue().Message() != nil {
+					a.maskMessage(mv.Message())
+				}
+
+				return true
+			})
+		case fd.Kind() == protoreflect.StringKind && masked:
+			m.Set(fd, protoreflect.ValueOfString(logger.MaskString(v.String())))
+		case fd.Message() != nil:
+			a.maskMessage(v.Message())
+		}
+
+		return true
+	})
+}
+
+// messageSize returns the wire size of the proto message.
+func messageSize(m interface{}) int {
+	msg, ok := m.(proto.Message)
+	if !ok || msg == nil {
+		return 0
+	}
+
+	return proto.Size(msg)
+}
+
+// userAgent returns the user agent of the original client,
+// the gateway forwards it as grpcgateway-user-agent.
+func userAgent(md metadata.MD) string {
+	if ua := md.Get("grpcgateway-user-agent"); len(ua) > 0 {
+		return ua[0]
+	}
+
+	if ua := md.Get("user-agent"); len(ua) > 0 {
+		return ua[0]
+	}
+
+	return ""
+}
@@ -0,0 +1,143 @@
+package micro
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/protobuf/types/known/structpb"
+
+	"github.com/daheige/hephfx/ctxkeys"
+	"github.com/daheige/hephfx/example/pb"
+)
+
+func TestAccessLogUnary(t *testing.T) {
+	l, logs := newObservedLogger()
+	s := newTestService(t, &testGreeter{delay: 30 * time.Millisecond},
+		WithAccessLog(l, WithAccessLogRequest(), WithAccessLogMaskFields("name"),
+			WithAccessLogSlowThreshold(20*time.Millisecond)),
+	)
+	conn := dialService(t, s)
+
+	ctx := metadata.AppendToOutgoingContext(context.Background(), "x-request-id", "req-1")
+	_, err := pb.NewGreeterClient(conn).SayHello(ctx, &pb.HelloReq{Name: "13800138000"})
+	if err != nil {
+		t.Fatalf("say hello error: %v", err)
+	}
+
+	entries := logs.All()
+	if len(entries) != 1 {
+		t.Fatalf("access log entries = %d, want 1", len(entries))
+	}
+
+	level, fields := entries[0].Level.String(), entries[0].ContextMap()
+	if level != "warn" || fields["slow"] != true {
+		t.Fatalf("slow call entry level = %s fields = %v", level, fields)
+	}
+	if fields["method"] != "/Hello.Greeter/SayHello" || fields["code"] != "OK" {
+		t.Fatalf("unexpected entry fields: %v", fields)
+	}
+	if id := fields[ctxkeys.XRequestID.String()]; id != "req-1" {
+		t.Fatalf("entry x-request-id = %v, want req-1", id)
+	}
+	if fields["request_size"].(int64) == 0 || fields["response_size"].(int64) == 0 {
+		t.Fatalf("unexpected message sizes: %v", fields)
+	}
+	if ua, _ := fields[ctxkeys.UserAgent.String()].(string); !strings.Contains(ua, "grpc-go") {
+		t.Fatalf("entry user agent = %s", ua)
+	}
+	if _, ok := fields["peer"]; !ok {
+		t.Fatalf("entry has no peer field: %v", fields)
+	}
+
+	request, _ := fields["request"].(string)
+	if strings.Contains(request, "13800138000") || !strings.Contains(request, "138*****8000") {
+		t.Fatalf("request is not masked: %s", request)
+	}
+}
+
+func TestAccessLogSample(t *testing.T) {
+	l, logs := newObservedLogger()
+	s := newTestService(t, pb.UnimplementedGreeterServer{},
+		WithEnableHealthCheck(),
+		WithAccessLog(l, WithAccessLogSampleRate(0),
+			WithAccessLogSkipMethods("/grpc.health.v1.Health/Check")),
+	)
+	conn := dialService(t, s)
+
+	client := healthpb.NewHealthClient(conn)
+	_, _ = client.Check(context.Background(), &healthpb.HealthCheckRequest{})
+
+	// the successful calls are sampled out,the failed calls are always logged
+	_, err := pb.NewGreeterClient(conn).SayHello(context.Background(), &pb.HelloReq{Name: "heige"})
+	if err == nil {
+		t.Fatalf("expected unimplemented error")
+	}
+
+	// the stream call is canceled by the client
+	ctx, cancel := context.WithCancel(context.Background())
+	stream, err := client.Watch(ctx, &healthpb.HealthCheckRequest{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = stream.Recv(); err != nil {
+		t.Fatalf("watch recv error: %v", err)
+	}
+	cancel()
+
+	deadline := time.Now().Add(3 * time.Second)
+	for logs.Len() < 2 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	entries := logs.All()
+	if len(entries) != 2 {
+		t.Fatalf("access log entries = %v, want 2", entries)
+	}
+
+	levels := map[string]string{}
+	methods := map[string]map[string]interface{}{}
+	for _, entry := range entries {
+		fields := entry.ContextMap()
+		levels[fields["method"].(string)] = entry.Level.String()
+		methods[fields["method"].(string)] = fields
+	}
+
+	unary := methods["/Hello.Greeter/SayHello"]
+	if levels["/Hello.Greeter/SayHello"] != "error" || unary["code"] != "Unimplemented" {
+		t.Fatalf("unexpected unary entry: %v", unary)
+	}
+
+	watch := methods["/grpc.health.v1.Health/Watch"]
+	if levels["/grpc.health.v1.Health/Watch"] != "warn" || watch["code"] != "Canceled" {
+		t.Fatalf("unexpected stream entry: %v", watch)
+	}
+	if watch["request_size"].(int64) == 0 && watch["response_size"].(int64) == 0 {
+		t.Fatalf("unexpected stream message sizes: %v", watch)
+	}
+}
+
+func TestAccessLogMaskNested(t *testing.T) {
+	l, _ := newObservedLogger()
+	a := newAccessLog(l, WithAccessLogMaskFields("string_value"))
+	msg, err := structpb.NewStruct(map[string]interface{}{
+		"phone": "13800138000",
+		"list":  []interface{}{"13800138000"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	masked, _ := a.maskRequest(msg).(string)
+	if strings.Contains(masked, "13800138000") {
+		t.Fatalf("nested fields are not masked: %s", masked)
+	}
+
+	// the original request is not changed
+	if msg.Fields["phone"].GetStringValue() != "13800138000" {
+		t.Fatalf("the original request is changed")
+	}
+}
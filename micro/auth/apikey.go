@@ -0,0 +1,67 @@
+package auth
+
+import (
+	"context"
+	"crypto/sha256"
+	"strings"
+)
+
+// defaultAPIKeyHeader the default metadata key of the api key.
+const defaultAPIKeyHeader = "X-Api-Key"
+
+// APIKeyOption api key authenticator option
+type APIKeyOption func(a *APIKeyAuthenticator)
+
+// APIKeyAuthenticator authenticates the requests by the static api keys.
+type APIKeyAuthenticator struct {
+	header string
+	keys   map[[sha256.Size]byte]Principal // the keys are looked up by the hash
+}
+
+// WithAPIKeyHeader returns an APIKeyOption to set the metadata key of the api key,default: x-api-key
+func WithAPIKeyHeader(header string) APIKeyOption {
+	return func(a *APIKeyAuthenticator) {
+		a.header = strings.ToLower(header)
+	}
+}
+
+// WithAPIKey returns an APIKeyOption to add the api key and its principal.
+func WithAPIKey(key string, p Principal) APIKeyOption {
+	return func(a *APIKeyAuthenticator) {
+		a.keys[sha256.Sum256([]byte(key))] = p
+	}
+}
+
+// NewAPIKeyAuthenticator creates an api key authenticator.
+func NewAPIKeyAuthenticator(opts ...APIKeyOption) *APIKeyAuthenticator {
+	a := &APIKeyAuthenticator{
+		header: strings.ToLower(defaultAPIKeyHeader),
+		keys:   make(map[[sha256.Size]byte]Principal),
+	}
+
+	for _, o := range opts {
+		o(a)
+	}
+
+	return a
+}
+
+// Name returns apikey.
+func (a *APIKeyAuthenticator) Name() string {
+	return "apikey"
+}
+
+// Authenticate returns the principal of the api key.
+func (a *APIKeyAuthenticator) Authenticate(ctx context.Context) (*Principal, error) {
+	key := firstMD(ctx, a.header)
+	if key == "" {
+		return nil, ErrNoCredentials
+	}
+
+	p, ok := a.keys[sha256.Sum256([]byte(key))]
+	if !ok {
+		return nil, ErrInvalidCredentials
+	}
+
+	return &p, nil
+}
@@ -0,0 +1,272 @@
+// Package auth provides the authentication and authorization interceptors of gRPC services,
+// the requests are authenticated by JWT, static API keys or mTLS peer certificates,
+// and authorized by the role and scope policy of the methods.
+package auth
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+
+	"github.com/daheige/hephfx/ctxkeys"
+)
+
+var (
+	// ErrNoCredentials the request has no credentials of the authenticator,
+	// the next authenticator is tried.
+	ErrNoCredentials = errors.New("no credentials")
+
+	// ErrInvalidCredentials the credentials of the request are invalid.
+	ErrInvalidCredentials = errors.New("invalid credentials")
+)
+
+// Authenticator authenticates the request by the incoming context.
+type Authenticator interface {
+	// Name returns the name of the authenticator,eg: jwt,apikey,mtls
+	Name() string
+
+	// Authenticate returns the principal of the request,
+	// it returns ErrNoCredentials when the request has no credentials of the authenticator.
+	Authenticate(ctx context.Context) (*Principal, error)
+}
+
+// Principal the authenticated identity of the request.
+type Principal struct {
+	Subject       string                 // the subject,eg: the user id,the api key name or the certificate common name
+	Authenticator string                 // the name of the authenticator
+	Roles         []string               // the roles of the principal
+	Scopes        []string               // the scopes of the principal
+	Claims        map[string]interface{} // the jwt claims
+}
+
+// HasRole reports whether the principal has the role.
+func (p *Principal) HasRole(role string) bool {
+	for _, r := range p.Roles {
+		if r == role {
+			return true
+		}
+	}
+
+	return false
+}
+
+// HasScope reports whether the principal has the scope.
+func (p *Principal) HasScope(scope string) bool {
+	for _, s := range p.Scopes {
+		if s == scope {
+			return true
+		}
+	}
+
+	return false
+}
+
+// NewContext returns the context with the principal under ctxkeys.Principal.
+func NewContext(ctx context.Context, p *Principal) context.Context {
+	return context.WithValue(ctx, ctxkeys.Principal, p)
+}
+
+// FromContext returns the principal of the context.
+func FromContext(ctx context.Context) (*Principal, bool) {
+	p, ok := ctx.Value(ctxkeys.Principal).(*Principal)
+	return p, ok && p != nil
+}
+
+// Option auth interceptor option
+type Option func(a *auth)
+
+type auth struct {
+	authenticators []Authenticator
+	skipMethods    []string            // the method patterns which are not authenticated
+	methodAllows   map[string][]string // the method pattern => the allowed authenticator names
+}
+
+// WithAuthenticators returns an Option to append the authenticators,
+// they are tried in order until one of them does not return ErrNoCredentials.
+func WithAuthenticators(authenticators ...Authenticator) Option {
+	return func(a *auth) {
+		a.authenticators = append(a.authenticators, authenticators...)
+	}
+}
+
+// WithSkipMethods returns an Option to skip the authentication of the method patterns,
+// eg: /grpc.health.v1.Health/*
+func WithSkipMethods(patterns ...string) Option {
+	return func(a *auth) {
+		a.skipMethods = append(a.skipMethods, patterns...)
+	}
+}
+
+// WithMethodAuthenticators returns an Option to allow only the named authenticators
+// for the method pattern,eg: WithMethodAuthenticators("/admin.Admin/*", "mtls")
+func WithMethodAuthenticators(pattern string, names ...string) Option {
+	return func(a *auth) {
+		a.methodAllows[pattern] = append(a.methodAllows[pattern], names...)
+	}
+}
+
+func newAuth(opts ...Option) *auth {
+	a := &auth{
+		methodAllows: make(map[string][]string),
+	}
+
+	for _, o := range opts {
+		o(a)
+	}
+
+	return a
+}
+
+// UnaryServerInterceptor returns a server unary interceptor which authenticates the requests,
+// the principal is put into the context,the unauthenticated requests are rejected
+// with codes.Unauthenticated.
+func UnaryServerInterceptor(opts ...Option) grpc.UnaryServerInterceptor {
+	a := newAuth(opts...)
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo,
+		handler grpc.UnaryHandler) (interface{}, error) {
+		ctx, err := a.authenticate(ctx, info.FullMethod)
+		if err != nil {
+			return nil, err
+		}
+
+		return handler(ctx, req)
+	}
+}
+
+// StreamServerInterceptor returns a server stream interceptor which authenticates the streams,
+// the principal is put into the context of the stream.
+func StreamServerInterceptor(opts ...Option) grpc.StreamServerInterceptor {
+	a := newAuth(opts...)
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		ctx, err := a.authenticate(ss.Context(), info.FullMethod)
+		if err != nil {
+			return err
+		}
+
+		return handler(srv, &serverStream{ServerStream: ss, ctx: ctx})
+	}
+}
+
+// authenticate returns the context with the principal of the request.
+func (a *auth) authenticate(ctx context.Context, method string) (context.Context, error) {
+	for _, pattern := range a.skipMethods {
+		if MatchMethod(pattern, method) {
+			return ctx, nil
+		}
+	}
+
+	allowed := a.allowedAuthenticators(method)
+	for _, authenticator := range a.authenticators {
+		if allowed != nil && !allowed[authenticator.Name()] {
+			continue
+		}
+
+		p, err := authenticator.Authenticate(ctx)
+		if errors.Is(err, ErrNoCredentials) {
+			continue
+		}
+
+		if err != nil {
+			return nil, status.Errorf(codes.Unauthenticated, "%s authentication failed: %v", authenticator.Name(), err)
+		}
+
+		p.Authenticator = authenticator.Name()
+		return NewContext(ctx, p), nil
+	}
+
+	return nil, status.Error(codes.Unauthenticated, "request unauthenticated")
+}
+
+// allowedAuthenticators returns the allowed authenticator names of the most specific pattern,
+// nil means all the authenticators are allowed.
+func (a *auth) allowedAuthenticators(method string) map[string]bool {
+	pattern, ok := matchPattern(method, func(pattern string) bool {
+		_, ok := a.methodAllows[pattern]
+		return ok
+	})
+	if !ok {
+		return nil
+	}
+
+	allowed := make(map[string]bool, len(a.methodAllows[pattern]))
+	for _, name := range a.methodAllows[pattern] {
+		allowed[name] = true
+	}
+
+	return allowed
+}
+
+// MatchMethod reports whether the full method matches the pattern,
+// the pattern is a full method,/package.Service/* or *.
+func MatchMethod(pattern string, method string) bool {
+	if pattern == "*" || pattern == method {
+		return true
+	}
+
+	prefix, ok := strings.CutSuffix(pattern, "/*")
+	return ok && strings.HasPrefix(method, prefix+"/")
+}
+
+// matchPattern returns the most specific pattern of the method which exists:
+// the full method,the service pattern and *.
+func matchPattern(method string, exists func(pattern string) bool) (string, bool) {
+	patterns := []string{method}
+	if i := strings.LastIndex(method, "/"); i > 0 {
+		patterns = append(patterns, method[:i]+"/*")
+	}
+
+	for _, pattern := range append(patterns, "*") {
+		if exists(pattern) {
+			return pattern, true
+		}
+	}
+
+	return "", false
+}
+
+// GatewayAnnotator returns a grpc-gateway annotator which forwards the credential headers
+// to gRPC metadata,default: Authorization and X-Api-Key.
+// grpc-gateway passes Authorization by itself as well,the first value of the metadata is used.
+func GatewayAnnotator(headers ...string) func(ctx context.Context, r *http.Request) metadata.MD {
+	if len(headers) == 0 {
+		headers = []string{"Authorization", defaultAPIKeyHeader}
+	}
+
+	return func(ctx context.Context, r *http.Request) metadata.MD {
+		md := metadata.MD{}
+		for _, header := range headers {
+			if value := r.Header.Get(header); value != "" {
+				md.Set(header, value)
+			}
+		}
+
+		return md
+	}
+}
+
+// serverStream overrides the context of grpc.ServerStream.
+type serverStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+// Context returns the context with the principal.
+func (s *serverStream) Context() context.Context {
+	return s.ctx
+}
+
+// firstMD returns the first value of the incoming metadata key.
+func firstMD(ctx context.Context, key string) string {
+	md, _ := metadata.FromIncomingContext(ctx)
+	if values := md.Get(key); len(values) > 0 {
+		return values[0]
+	}
+
+	return ""
+}
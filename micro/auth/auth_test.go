@@ -0,0 +1,118 @@
+package auth
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"errors"
+	"net/http/httptest"
+	"testing"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+)
+
+// callUnary calls the unary interceptor with the incoming metadata,returns the principal of the handler.
+func callUnary(ctx context.Context, method string, md metadata.MD, opts ...Option) (*Principal, error) {
+	var p *Principal
+	_, err := UnaryServerInterceptor(opts...)(metadata.NewIncomingContext(ctx, md), nil,
+		&grpc.UnaryServerInfo{FullMethod: method},
+		func(ctx context.Context, req interface{}) (interface{}, error) {
+			p, _ = FromContext(ctx)
+			return nil, nil
+		})
+	return p, err
+}
+
+func TestAPIKey(t *testing.T) {
+	apiKey := NewAPIKeyAuthenticator(WithAPIKey("secret-key", Principal{Subject: "billing", Roles: []string{"admin"}}))
+
+	p, err := callUnary(context.Background(), "/Hello.Greeter/SayHello",
+		metadata.Pairs("x-api-key", "secret-key"), WithAuthenticators(apiKey))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.Subject != "billing" || p.Authenticator != "apikey" || !p.HasRole("admin") {
+		t.Fatalf("unexpected principal:%+v", p)
+	}
+
+	_, err = callUnary(context.Background(), "/Hello.Greeter/SayHello",
+		metadata.Pairs("x-api-key", "wrong-key"), WithAuthenticators(apiKey))
+	if status.Code(err) != codes.Unauthenticated {
+		t.Fatalf("expected Unauthenticated,got:%v", err)
+	}
+
+	_, err = callUnary(context.Background(), "/Hello.Greeter/SayHello", metadata.MD{}, WithAuthenticators(apiKey))
+	if status.Code(err) != codes.Unauthenticated {
+		t.Fatalf("expected Unauthenticated,got:%v", err)
+	}
+}
+
+func TestSkipAndMethodAuthenticators(t *testing.T) {
+	apiKey := NewAPIKeyAuthenticator(WithAPIKey("secret-key", Principal{Subject: "billing"}))
+	opts := []Option{
+		WithAuthenticators(apiKey, NewMTLSAuthenticator()),
+		WithSkipMethods("/grpc.health.v1.Health/*"),
+		WithMethodAuthenticators("/admin.Admin/*", "mtls"),
+	}
+
+	p, err := callUnary(context.Background(), "/grpc.health.v1.Health/Check", metadata.MD{}, opts...)
+	if err != nil || p != nil {
+		t.Fatalf("expected skipped,got:%v %v", p, err)
+	}
+
+	_, err = callUnary(context.Background(), "/admin.Admin/Delete", metadata.Pairs("x-api-key", "secret-key"), opts...)
+	if status.Code(err) != codes.Unauthenticated {
+		t.Fatalf("expected the api key to be rejected,got:%v", err)
+	}
+}
+
+func TestMTLSWithoutCertificate(t *testing.T) {
+	ctx := peer.NewContext(context.Background(), &peer.Peer{AuthInfo: credentials.TLSInfo{}})
+	if _, err := NewMTLSAuthenticator().Authenticate(ctx); !errors.Is(err, ErrNoCredentials) {
+		t.Fatalf("expected ErrNoCredentials,got:%v", err)
+	}
+
+	// the peer certificates not verified by the tls handshake are not the credentials
+	ctx = peer.NewContext(context.Background(), &peer.Peer{AuthInfo: credentials.TLSInfo{
+		State: tls.ConnectionState{PeerCertificates: []*x509.Certificate{{Subject: pkix.Name{CommonName: "ops"}}}},
+	}})
+	if _, err := NewMTLSAuthenticator().Authenticate(ctx); !errors.Is(err, ErrNoCredentials) {
+		t.Fatalf("expected ErrNoCredentials,got:%v", err)
+	}
+}
+
+func TestMatchMethod(t *testing.T) {
+	tests := []struct {
+		pattern string
+		method  string
+		match   bool
+	}{
+		{"*", "/Hello.Greeter/SayHello", true},
+		{"/Hello.Greeter/*", "/Hello.Greeter/SayHello", true},
+		{"/Hello.Greeter/SayHello", "/Hello.Greeter/SayHello", true},
+		{"/Hello.Greeter/*", "/Hello.GreeterV2/SayHello", false},
+		{"/Hello.Greeter/SayHi", "/Hello.Greeter/SayHello", false},
+	}
+	for _, tt := range tests {
+		if MatchMethod(tt.pattern, tt.method) != tt.match {
+			t.Errorf("MatchMethod(%q,%q) != %v", tt.pattern, tt.method, tt.match)
+		}
+	}
+}
+
+func TestGatewayAnnotator(t *testing.T) {
+	r := httptest.NewRequest("GET", "/v1/say/daheige", nil)
+	r.Header.Set("Authorization", "Bearer token")
+	r.Header.Set("X-Api-Key", "secret-key")
+
+	md := GatewayAnnotator()(context.Background(), r)
+	if md.Get("authorization")[0] != "Bearer token" || md.Get("x-api-key")[0] != "secret-key" {
+		t.Fatalf("unexpected metadata:%v", md)
+	}
+}
@@ -0,0 +1,310 @@
+package auth
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+
+	"golang.org/x/sync/singleflight"
+)
+
+const (
+	// defaultJWKSRefreshInterval the default interval of refreshing the remote jwks.
+	defaultJWKSRefreshInterval = 10 * time.Minute
+
+	// jwksMinRefreshInterval the min interval of refreshing the remote jwks for an unknown key id,
+	// and of retrying the failed refresh.
+	jwksMinRefreshInterval = 10 * time.Second
+)
+
+// ErrKeyNotFound the verification key of the key id is not found.
+var ErrKeyNotFound = errors.New("jwt key not found")
+
+// KeySet provides the verification keys of the jwt.
+type KeySet interface {
+	// Key returns the key of the key id,the key is []byte,*rsa.PublicKey or *ecdsa.PublicKey.
+	Key(ctx context.Context, kid string) (interface{}, error)
+}
+
+// JWKS the static json web key set.
+type JWKS struct {
+	keys map[string]interface{}
+}
+
+// jwk the json web key,see RFC 7517.
+type jwk struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	Crv string `json:"crv"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+	K   string `json:"k"`
+}
+
+// ParseJWKS parses the json web key set,the RSA,EC and oct keys are supported.
+func ParseJWKS(data []byte) (*JWKS, error) {
+	var set struct {
+		Keys []jwk `json:"keys"`
+	}
+	if err := json.Unmarshal(data, &set); err != nil {
+		return nil, fmt.Errorf("parse jwks error:%w", err)
+	}
+
+	j := &JWKS{keys: make(map[string]interface{}, len(set.Keys))}
+	for _, k := range set.Keys {
+		if k.Use == "enc" {
+			continue
+		}
+
+		key, err := k.key()
+		if err != nil {
+			return nil, fmt.Errorf("parse jwk kid:%s error:%w", k.Kid, err)
+		}
+
+		j.keys[k.Kid] = key
+	}
+
+	return j, nil
+}
+
+// LoadJWKSFile loads the json web key set from the file.
+func LoadJWKSFile(path string) (*JWKS, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	return ParseJWKS(data)
+}
+
+// Key returns the key of the key id,the only key is returned when the key id is empty.
+func (j *JWKS) Key(_ context.Context, kid string) (interface{}, error) {
+	if key, ok := j.keys[kid]; ok {
+		return key, nil
+	}
+
+	if kid == "" && len(j.keys) == 1 {
+		for _, key := range j.keys {
+			return key, nil
+		}
+	}
+
+	return nil, ErrKeyNotFound
+}
+
+func (k jwk) key() (interface{}, error) {
+	switch k.Kty {
+	case "RSA":
+		n, err := decodeSegment(k.N)
+		if err != nil {
+			return nil, err
+		}
+
+		e, err := decodeSegment(k.E)
+		if err != nil {
+			return nil, err
+		}
+
+		return &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(new(big.Int).SetBytes(e).Int64())}, nil
+	case "EC":
+		curve, size, err := ecCurve(k.Crv)
+		if err != nil {
+			return nil, err
+		}
+
+		x, err := decodeSegment(k.X)
+		if err != nil {
+			return nil, err
+		}
+
+		y, err := decodeSegment(k.Y)
+		if err != nil {
+			return nil, err
+		}
+
+		if len(x) > size || len(y) > size {
+			return nil, errors.New("invalid ec point")
+		}
+
+		// the uncompressed point: 0x04 || x || y
+		point := make([]byte, 1+2*size)
+		point[0] = 4
+		copy(point[1+size-len(x):1+size], x)
+		copy(point[1+2*size-len(y):], y)
+		return ecdsa.ParseUncompressedPublicKey(curve, point)
+	case "oct":
+		return decodeSegment(k.K)
+	default:
+		return nil, fmt.Errorf("unsupported key type:%s", k.Kty)
+	}
+}
+
+func ecCurve(crv string) (elliptic.Curve, int, error) {
+	switch crv {
+	case "P-256":
+		return elliptic.P256(), 32, nil
+	case "P-384":
+		return elliptic.P384(), 48, nil
+	case "P-521":
+		return elliptic.P521(), 66, nil
+	default:
+		return nil, 0, fmt.Errorf("unsupported curve:%s", crv)
+	}
+}
+
+func decodeSegment(s string) ([]byte, error) {
+	return base64.RawURLEncoding.DecodeString(s)
+}
+
+// RemoteJWKSOption remote jwks option
+type RemoteJWKSOption func(r *RemoteJWKS)
+
+// RemoteJWKS the json web key set fetched from the url,it is refreshed periodically
+// and when the key id is unknown.
+// The cached keys are served while the refresh fails,the failed refresh is retried after 10s.
+type RemoteJWKS struct {
+	url             string
+	client          *http.Client
+	refreshInterval time.Duration
+
+	// the concurrent refreshes are merged into one fetch
+	group singleflight.Group
+
+	mu          sync.RWMutex
+	keys        *JWKS
+	fetchedAt   time.Time // the time of the last successful fetch
+	attemptedAt time.Time // the time of the last fetch,it is recorded even if the fetch fails
+	fetchErr    error     // the error of the last fetch
+}
+
+// WithJWKSHTTPClient returns a RemoteJWKSOption to set the http client.
+func WithJWKSHTTPClient(client *http.Client) RemoteJWKSOption {
+	return func(r *RemoteJWKS) {
+		r.client = client
+	}
+}
+
+// WithJWKSRefreshInterval returns a RemoteJWKSOption to set the refresh interval,default: 10m
+func WithJWKSRefreshInterval(interval time.Duration) RemoteJWKSOption {
+	return func(r *RemoteJWKS) {
+		r.refreshInterval = interval
+	}
+}
+
+// NewRemoteJWKS creates a json web key set which is fetched from the url.
+func NewRemoteJWKS(url string, opts ...RemoteJWKSOption) *RemoteJWKS {
+	r := &RemoteJWKS{
+		url:             url,
+		client:          &http.Client{Timeout: 5 * time.Second},
+		refreshInterval: defaultJWKSRefreshInterval,
+	}
+
+	for _, o := range opts {
+		o(r)
+	}
+
+	return r
+}
+
+// Key returns the key of the key id.
+func (r *RemoteJWKS) Key(ctx context.Context, kid string) (interface{}, error) {
+	r.mu.RLock()
+	keys, fetchedAt, attemptedAt, fetchErr := r.keys, r.fetchedAt, r.attemptedAt, r.fetchErr
+	r.mu.RUnlock()
+
+	canRefresh := time.Since(attemptedAt) > jwksMinRefreshInterval
+	if keys == nil && !canRefresh {
+		return nil, fetchErr
+	}
+
+	if keys == nil || (canRefresh && time.Since(fetchedAt) > r.refreshInterval) {
+		refreshed, err := r.refresh(ctx)
+		if err != nil && keys == nil {
+			return nil, err
+		}
+
+		// keep serving the cached keys when the refresh fails
+		if err == nil {
+			keys = refreshed
+		}
+
+		canRefresh = false
+	}
+
+	key, err := keys.Key(ctx, kid)
+	if errors.Is(err, ErrKeyNotFound) && canRefresh {
+		// the keys may be rotated
+		refreshed, err := r.refresh(ctx)
+		if err != nil {
+			return nil, err
+		}
+
+		return refreshed.Key(ctx, kid)
+	}
+
+	return key, err
+}
+
+// refresh fetches the keys without holding the lock,the concurrent callers share one fetch.
+// The fetch is not canceled with the context of the caller which starts it.
+func (r *RemoteJWKS) refresh(ctx context.Context) (*JWKS, error) {
+	v, err, _ := r.group.Do(r.url, func() (interface{}, error) {
+		keys, err := r.fetch(context.WithoutCancel(ctx))
+
+		r.mu.Lock()
+		defer r.mu.Unlock()
+
+		r.attemptedAt = time.Now()
+		r.fetchErr = err
+		if err != nil {
+			return nil, err
+		}
+
+		r.keys = keys
+		r.fetchedAt = r.attemptedAt
+		return keys, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return v.(*JWKS), nil
+}
+
+func (r *RemoteJWKS) fetch(ctx context.Context) (*JWKS, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, r.url, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := r.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("fetch jwks error:%w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("fetch jwks status code:%d", resp.StatusCode)
+	}
+
+	data, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return nil, err
+	}
+
+	return ParseJWKS(data)
+}
@@ -0,0 +1,176 @@
+package auth
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// JWTOption jwt authenticator option
+type JWTOption func(a *JWTAuthenticator)
+
+// JWTAuthenticator authenticates the requests by the bearer token of the authorization metadata,
+// the HS,RS,PS and ES algorithms are supported.
+type JWTAuthenticator struct {
+	secret     []byte
+	keySet     KeySet
+	algorithms []string
+	issuer     string
+	audience   string
+	leeway     time.Duration
+	rolesClaim string
+}
+
+// WithJWTSecret returns a JWTOption to set the secret of the HS algorithms.
+func WithJWTSecret(secret []byte) JWTOption {
+	return func(a *JWTAuthenticator) {
+		a.secret = secret
+	}
+}
+
+// WithJWTKeySet returns a JWTOption to set the key set,eg: LoadJWKSFile or NewRemoteJWKS
+func WithJWTKeySet(keySet KeySet) JWTOption {
+	return func(a *JWTAuthenticator) {
+		a.keySet = keySet
+	}
+}
+
+// WithJWTAlgorithms returns a JWTOption to set the valid algorithms,
+// default: HS256,HS384,HS512,RS256,RS384,RS512,PS256,PS384,PS512,ES256,ES384,ES512
+func WithJWTAlgorithms(algorithms ...string) JWTOption {
+	return func(a *JWTAuthenticator) {
+		a.algorithms = algorithms
+	}
+}
+
+// WithJWTIssuer returns a JWTOption to validate the iss claim.
+func WithJWTIssuer(issuer string) JWTOption {
+	return func(a *JWTAuthenticator) {
+		a.issuer = issuer
+	}
+}
+
+// WithJWTAudience returns a JWTOption to validate the aud claim.
+func WithJWTAudience(audience string) JWTOption {
+	return func(a *JWTAuthenticator) {
+		a.audience = audience
+	}
+}
+
+// WithJWTLeeway returns a JWTOption to set the leeway of validating the time based claims.
+func WithJWTLeeway(leeway time.Duration) JWTOption {
+	return func(a *JWTAuthenticator) {
+		a.leeway = leeway
+	}
+}
+
+// WithJWTRolesClaim returns a JWTOption to set the claim of the roles,default: roles
+func WithJWTRolesClaim(claim string) JWTOption {
+	return func(a *JWTAuthenticator) {
+		a.rolesClaim = claim
+	}
+}
+
+// NewJWTAuthenticator creates a jwt authenticator.
+func NewJWTAuthenticator(opts ...JWTOption) *JWTAuthenticator {
+	a := &JWTAuthenticator{
+		algorithms: []string{
+			"HS256", "HS384", "HS512", "RS256", "RS384", "RS512",
+			"PS256", "PS384", "PS512", "ES256", "ES384", "ES512",
+		},
+		rolesClaim: "roles",
+	}
+
+	for _, o := range opts {
+		o(a)
+	}
+
+	return a
+}
+
+// Name returns jwt.
+func (a *JWTAuthenticator) Name() string {
+	return "jwt"
+}
+
+// Authenticate returns the principal of the bearer token.
+func (a *JWTAuthenticator) Authenticate(ctx context.Context) (*Principal, error) {
+	scheme, token, ok := strings.Cut(firstMD(ctx, "authorization"), " ")
+	if !ok || !strings.EqualFold(scheme, "bearer") {
+		return nil, ErrNoCredentials
+	}
+
+	options := []jwt.ParserOption{
+		jwt.WithValidMethods(a.algorithms),
+		jwt.WithLeeway(a.leeway),
+	}
+	if a.issuer != "" {
+		options = append(options, jwt.WithIssuer(a.issuer))
+	}
+	if a.audience != "" {
+		options = append(options, jwt.WithAudience(a.audience))
+	}
+
+	claims := jwt.MapClaims{}
+	_, err := jwt.ParseWithClaims(strings.TrimSpace(token), claims, func(t *jwt.Token) (interface{}, error) {
+		return a.key(ctx, t)
+	}, options...)
+	if err != nil {
+		return nil, err
+	}
+
+	subject, _ := claims.GetSubject()
+	return &Principal{
+		Subject: subject,
+		Roles:   claimStrings(claims[a.rolesClaim]),
+		Scopes:  scopes(claims),
+		Claims:  claims,
+	}, nil
+}
+
+// key returns the verification key of the token,the HS algorithms use the secret or the oct keys,
+// the other algorithms use the public keys of the key set.
+func (a *JWTAuthenticator) key(ctx context.Context, t *jwt.Token) (interface{}, error) {
+	alg, _ := t.Header["alg"].(string)
+	if strings.HasPrefix(alg, "HS") && a.secret != nil {
+		return a.secret, nil
+	}
+
+	if a.keySet == nil {
+		return nil, fmt.Errorf("no verification key of the algorithm:%s", alg)
+	}
+
+	kid, _ := t.Header["kid"].(string)
+	return a.keySet.Key(ctx, kid)
+}
+
+// scopes returns the scopes of the scope claim (space separated) or the scp claim.
+func scopes(claims jwt.MapClaims) []string {
+	if scope, ok := claims["scope"].(string); ok {
+		return strings.Fields(scope)
+	}
+
+	return claimStrings(claims["scp"])
+}
+
+// claimStrings returns the strings of the claim,the claim is an array or a space separated string.
+func claimStrings(v interface{}) []string {
+	switch value := v.(type) {
+	case string:
+		return strings.Fields(value)
+	case []interface{}:
+		values := make([]string, 0, len(value))
+		for _, item := range value {
+			if s, ok := item.(string); ok {
+				values = append(values, s)
+			}
+		}
+
+		return values
+	default:
+		return nil
+	}
+}
@@ -0,0 +1,288 @@
+package auth
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+func encodeSegment(b []byte) string {
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+// testJWKS returns the jwks json of the public keys.
+func testJWKS(t *testing.T, keys map[string]crypto.PublicKey) []byte {
+	t.Helper()
+
+	var set struct {
+		Keys []jwk `json:"keys"`
+	}
+	for kid, key := range keys {
+		switch k := key.(type) {
+		case *rsa.PublicKey:
+			set.Keys = append(set.Keys, jwk{Kty: "RSA", Kid: kid,
+				N: encodeSegment(k.N.Bytes()), E: encodeSegment(big.NewInt(int64(k.E)).Bytes())})
+		case *ecdsa.PublicKey:
+			point, err := k.Bytes()
+			if err != nil {
+				t.Fatal(err)
+			}
+			size := (len(point) - 1) / 2
+			set.Keys = append(set.Keys, jwk{Kty: "EC", Kid: kid, Crv: k.Curve.Params().Name,
+				X: encodeSegment(point[1 : 1+size]), Y: encodeSegment(point[1+size:])})
+		}
+	}
+
+	data, err := json.Marshal(set)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return data
+}
+
+func signToken(t *testing.T, method jwt.SigningMethod, kid string, key interface{}, claims jwt.MapClaims) string {
+	t.Helper()
+
+	token := jwt.NewWithClaims(method, claims)
+	if kid != "" {
+		token.Header["kid"] = kid
+	}
+
+	s, err := token.SignedString(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return s
+}
+
+func authenticateToken(a *JWTAuthenticator, token string) (*Principal, error) {
+	ctx := metadata.NewIncomingContext(context.Background(), metadata.Pairs("authorization", "Bearer "+token))
+	return a.Authenticate(ctx)
+}
+
+func testClaims() jwt.MapClaims {
+	return jwt.MapClaims{
+		"sub":   "daheige",
+		"iss":   "hephfx",
+		"aud":   "greeter",
+		"exp":   time.Now().Add(time.Hour).Unix(),
+		"roles": []string{"admin"},
+		"scope": "hello:read hello:write",
+	}
+}
+
+func TestJWTSecret(t *testing.T) {
+	secret := []byte("jwt-secret")
+	a := NewJWTAuthenticator(WithJWTSecret(secret), WithJWTIssuer("hephfx"), WithJWTAudience("greeter"))
+
+	p, err := authenticateToken(a, signToken(t, jwt.SigningMethodHS256, "", secret, testClaims()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.Subject != "daheige" || !p.HasRole("admin") || !p.HasScope("hello:write") {
+		t.Fatalf("unexpected principal:%+v", p)
+	}
+
+	claims := testClaims()
+	claims["exp"] = time.Now().Add(-time.Minute).Unix()
+	if _, err = authenticateToken(a, signToken(t, jwt.SigningMethodHS256, "", secret, claims)); err == nil {
+		t.Fatal("expected the expired token to be rejected")
+	}
+
+	claims = testClaims()
+	claims["aud"] = "other"
+	if _, err = authenticateToken(a, signToken(t, jwt.SigningMethodHS256, "", secret, claims)); err == nil {
+		t.Fatal("expected the audience to be rejected")
+	}
+
+	if _, err = authenticateToken(a, signToken(t, jwt.SigningMethodHS256, "", []byte("other"), testClaims())); err == nil {
+		t.Fatal("expected the signature to be rejected")
+	}
+}
+
+func TestJWKSFile(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	path := filepath.Join(t.TempDir(), "jwks.json")
+	data := testJWKS(t, map[string]crypto.PublicKey{"rsa": &rsaKey.PublicKey, "ec": &ecKey.PublicKey})
+	if err = os.WriteFile(path, data, 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	keySet, err := LoadJWKSFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	a := NewJWTAuthenticator(WithJWTKeySet(keySet), WithJWTRolesClaim("groups"))
+	claims := testClaims()
+	claims["groups"] = "dev ops"
+	for _, token := range []string{
+		signToken(t, jwt.SigningMethodRS256, "rsa", rsaKey, claims),
+		signToken(t, jwt.SigningMethodES256, "ec", ecKey, claims),
+	} {
+		p, err := authenticateToken(a, token)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if p.Subject != "daheige" || !p.HasRole("ops") {
+			t.Fatalf("unexpected principal:%+v", p)
+		}
+	}
+
+	// the key id does not match the key type
+	if _, err = authenticateToken(a, signToken(t, jwt.SigningMethodRS256, "ec", rsaKey, claims)); err == nil {
+		t.Fatal("expected the token to be rejected")
+	}
+
+	// the HS algorithm must not use the public key as the secret
+	if _, err = authenticateToken(a, signToken(t, jwt.SigningMethodHS256, "rsa", []byte("x"), claims)); err == nil {
+		t.Fatal("expected the token to be rejected")
+	}
+}
+
+func TestRemoteJWKS(t *testing.T) {
+	ecKey, err := ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var requests int
+	data := testJWKS(t, map[string]crypto.PublicKey{"ec384": &ecKey.PublicKey})
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write(data)
+	}))
+	defer ts.Close()
+
+	a := NewJWTAuthenticator(WithJWTKeySet(NewRemoteJWKS(ts.URL, WithJWKSHTTPClient(ts.Client()))))
+	for i := 0; i < 3; i++ {
+		if _, err = authenticateToken(a, signToken(t, jwt.SigningMethodES384, "ec384", ecKey, testClaims())); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if requests != 1 {
+		t.Fatalf("expected the jwks to be fetched once,got:%d", requests)
+	}
+}
+
+func TestRemoteJWKSRefresh(t *testing.T) {
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var (
+		requests atomic.Int32
+		failing  atomic.Bool
+	)
+	data := testJWKS(t, map[string]crypto.PublicKey{"ec256": &ecKey.PublicKey})
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		time.Sleep(20 * time.Millisecond)
+		if failing.Load() {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+
+		_, _ = w.Write(data)
+	}))
+	defer ts.Close()
+
+	keySet := NewRemoteJWKS(ts.URL, WithJWKSHTTPClient(ts.Client()), WithJWKSRefreshInterval(time.Minute))
+
+	// the concurrent callers share one fetch
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := keySet.Key(context.Background(), "ec256"); err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+	wg.Wait()
+	if n := requests.Load(); n != 1 {
+		t.Fatalf("expected the jwks to be fetched once,got:%d", n)
+	}
+
+	// the cached keys are served when the refresh fails
+	failing.Store(true)
+	keySet.mu.Lock()
+	keySet.fetchedAt = time.Now().Add(-time.Hour)
+	keySet.attemptedAt = keySet.fetchedAt
+	keySet.mu.Unlock()
+	if _, err = keySet.Key(context.Background(), "ec256"); err != nil {
+		t.Fatalf("expected the cached key,got:%v", err)
+	}
+
+	// the failed refresh is not retried until the min refresh interval
+	_, _ = keySet.Key(context.Background(), "ec256")
+	if _, err = keySet.Key(context.Background(), "unknown"); err != ErrKeyNotFound {
+		t.Fatalf("expected ErrKeyNotFound,got:%v", err)
+	}
+	if n := requests.Load(); n != 2 {
+		t.Fatalf("expected the jwks to be fetched twice,got:%d", n)
+	}
+
+	// the first fetch error is returned until the min refresh interval
+	empty := NewRemoteJWKS(ts.URL, WithJWKSHTTPClient(ts.Client()))
+	for i := 0; i < 3; i++ {
+		if _, err = empty.Key(context.Background(), "ec256"); err == nil {
+			t.Fatal("expected the fetch error")
+		}
+	}
+	if n := requests.Load(); n != 3 {
+		t.Fatalf("expected the failed jwks fetch not to be retried,got:%d requests", n)
+	}
+}
+
+func TestJWTInterceptor(t *testing.T) {
+	secret := []byte("jwt-secret")
+	opts := []Option{WithAuthenticators(NewJWTAuthenticator(WithJWTSecret(secret)), NewAPIKeyAuthenticator())}
+
+	md := metadata.Pairs("authorization", "Bearer "+signToken(t, jwt.SigningMethodHS256, "", secret, testClaims()))
+	p, err := callUnary(context.Background(), "/Hello.Greeter/SayHello", md, opts...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.Authenticator != "jwt" {
+		t.Fatalf("unexpected authenticator:%s", p.Authenticator)
+	}
+
+	_, err = callUnary(context.Background(), "/Hello.Greeter/SayHello", metadata.Pairs("authorization", "Bearer bad"), opts...)
+	if status.Code(err) != codes.Unauthenticated {
+		t.Fatalf("expected Unauthenticated,got:%v", err)
+	}
+}
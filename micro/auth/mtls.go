@@ -0,0 +1,97 @@
+package auth
+
+import (
+	"context"
+	"crypto/x509"
+
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/peer"
+)
+
+// GatewayCertificateURI is the URI SAN of the client certificate presented by the internal gateway dial
+// of micro.Service,the certificate has no identity of the caller,so it is not authenticated by mtls.
+const GatewayCertificateURI = "urn:hephfx:gateway"
+
+// MTLSOption mtls authenticator option
+type MTLSOption func(a *MTLSAuthenticator)
+
+// MTLSAuthenticator authenticates the requests by the verified peer certificates,
+// the tls config of the service must verify the client certificates,eg: micro.WithTLSClientCAFile.
+type MTLSAuthenticator struct {
+	principal func(cert *x509.Certificate) (*Principal, error)
+}
+
+// WithMTLSPrincipal returns an MTLSOption to map the peer certificate to the principal,
+// default: the subject is the common name and the roles are the organizational units.
+func WithMTLSPrincipal(fn func(cert *x509.Certificate) (*Principal, error)) MTLSOption {
+	return func(a *MTLSAuthenticator) {
+		a.principal = fn
+	}
+}
+
+// NewMTLSAuthenticator creates an mtls authenticator.
+func NewMTLSAuthenticator(opts ...MTLSOption) *MTLSAuthenticator {
+	a := &MTLSAuthenticator{
+		principal: certPrincipal,
+	}
+
+	for _, o := range opts {
+		o(a)
+	}
+
+	return a
+}
+
+// Name returns mtls.
+func (a *MTLSAuthenticator) Name() string {
+	return "mtls"
+}
+
+// Authenticate returns the principal of the peer certificate verified by the tls handshake,
+// the unverified peer certificates are not the credentials.
+func (a *MTLSAuthenticator) Authenticate(ctx context.Context) (*Principal, error) {
+	pr, ok := peer.FromContext(ctx)
+	if !ok {
+		return nil, ErrNoCredentials
+	}
+
+	tlsInfo, ok := pr.AuthInfo.(credentials.TLSInfo)
+	if !ok || len(tlsInfo.State.VerifiedChains) == 0 || len(tlsInfo.State.VerifiedChains[0]) == 0 {
+		return nil, ErrNoCredentials
+	}
+
+	cert := tlsInfo.State.VerifiedChains[0][0]
+	if isGatewayCertificate(cert) {
+		return nil, ErrNoCredentials
+	}
+
+	return a.principal(cert)
+}
+
+// isGatewayCertificate reports whether cert is presented by the internal gateway dial.
+func isGatewayCertificate(cert *x509.Certificate) bool {
+	for _, u := range cert.URIs {
+		if u.String() == GatewayCertificateURI {
+			return true
+		}
+	}
+
+	return false
+}
+
+// certPrincipal returns the principal of the certificate.
+func certPrincipal(cert *x509.Certificate) (*Principal, error) {
+	subject := cert.Subject.CommonName
+	if subject == "" && len(cert.DNSNames) > 0 {
+		subject = cert.DNSNames[0]
+	}
+
+	if subject == "" {
+		return nil, ErrInvalidCredentials
+	}
+
+	return &Principal{
+		Subject: subject,
+		Roles:   append([]string(nil), cert.Subject.OrganizationalUnit...),
+	}, nil
+}
@@ -0,0 +1,231 @@
+package auth
+
+import (
+	"context"
+	"sync/atomic"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/daheige/hephfx/logger"
+	"github.com/daheige/hephfx/settings"
+)
+
+// PolicyRule the authorization rule of the methods.
+type PolicyRule struct {
+	// Method the full method,eg: /Hello.Greeter/SayHello
+	// /Hello.Greeter/* matches all methods of the service,* matches all methods.
+	Method string `json:"method" mapstructure:"method"`
+
+	// Roles the principal which has any of the roles is allowed
+	Roles []string `json:"roles" mapstructure:"roles"`
+
+	// Scopes the principal which has any of the scopes is allowed.
+	// Any authenticated principal is allowed when both Roles and Scopes are empty.
+	Scopes []string `json:"scopes" mapstructure:"scopes"`
+
+	// Public allows the requests without the principal
+	Public bool `json:"public" mapstructure:"public"`
+}
+
+// PolicyConfig authorization policy config,it can be read from settings.Config:
+//
+//	authz:
+//	  dry_run: false
+//	  default_allow: false
+//	  rules:
+//	    - method: "/Hello.Greeter/*"
+//	      roles: ["user", "admin"]
+//	    - method: "/Hello.Greeter/SayHello"
+//	      scopes: ["hello:read"]
+//	    - method: "/grpc.health.v1.Health/*"
+//	      public: true
+type PolicyConfig struct {
+	// DryRun logs the decisions without denying the requests
+	DryRun bool `json:"dry_run" mapstructure:"dry_run"`
+
+	// DefaultAllow allows the methods which match no rules,default: false
+	DefaultAllow bool `json:"default_allow" mapstructure:"default_allow"`
+
+	// Rules the authorization rules,the most specific rule of the method is used
+	Rules []PolicyRule `json:"rules" mapstructure:"rules"`
+}
+
+// PolicyOption authorization policy option
+type PolicyOption func(p *Policy)
+
+// Policy authorizes the principals of the requests by the roles and scopes of the methods,
+// the rules can be replaced at runtime by Update.
+type Policy struct {
+	rules  atomic.Pointer[policyRules]
+	logger logger.Logger
+}
+
+// policyRules the rules built from PolicyConfig,it is replaced on update.
+type policyRules struct {
+	dryRun       bool
+	defaultAllow bool
+	rules        map[string]*PolicyRule
+}
+
+// WithPolicyLogger returns a PolicyOption to set the logger of the decisions,default: logger.Default()
+func WithPolicyLogger(l logger.Logger) PolicyOption {
+	return func(p *Policy) {
+		p.logger = l
+	}
+}
+
+// NewPolicy creates an authorization policy with the config.
+func NewPolicy(cfg PolicyConfig, opts ...PolicyOption) *Policy {
+	p := &Policy{}
+	for _, o := range opts {
+		o(p)
+	}
+
+	if p.logger == nil {
+		p.logger = logger.Default()
+	}
+
+	p.Update(cfg)
+	return p
+}
+
+// NewPolicyFromConfig creates an authorization policy from the section of settings.Config,
+// the rules are reloaded when the config file is watched and changed.
+func NewPolicyFromConfig(conf settings.Config, key string, opts ...PolicyOption) (*Policy, error) {
+	var cfg PolicyConfig
+	if err := conf.ReadSection(key, &cfg); err != nil {
+		return nil, err
+	}
+
+	p := NewPolicy(cfg, opts...)
+	if w, ok := conf.(settings.Watcher); ok {
+		w.OnChange(func() {
+			var cfg PolicyConfig
+			if err := conf.ReadSection(key, &cfg); err != nil {
+				p.logger.Error(context.Background(), "reload authorization policy error", "key", key, "error", err)
+				return
+			}
+
+			p.Update(cfg)
+		})
+	}
+
+	return p, nil
+}
+
+// Update replaces the rules with the config,the rules of the same method are merged.
+func (p *Policy) Update(cfg PolicyConfig) {
+	rules := &policyRules{
+		dryRun:       cfg.DryRun,
+		defaultAllow: cfg.DefaultAllow,
+		rules:        make(map[string]*PolicyRule, len(cfg.Rules)),
+	}
+
+	for _, rule := range cfg.Rules {
+		r, ok := rules.rules[rule.Method]
+		if !ok {
+			r = &PolicyRule{Method: rule.Method}
+			rules.rules[rule.Method] = r
+		}
+
+		r.Roles = append(r.Roles, rule.Roles...)
+		r.Scopes = append(r.Scopes, rule.Scopes...)
+		r.Public = r.Public || rule.Public
+	}
+
+	p.rules.Store(rules)
+}
+
+// Authorize returns codes.PermissionDenied error when the principal of the context
+// is not allowed to call the method,it returns nil in the dry run mode.
+func (p *Policy) Authorize(ctx context.Context, method string) error {
+	rules := p.rules.Load()
+	principal, _ := FromContext(ctx)
+
+	pattern, ok := matchPattern(method, func(pattern string) bool {
+		_, ok := rules.rules[pattern]
+		return ok
+	})
+
+	allowed := rules.defaultAllow
+	if ok {
+		allowed = rules.rules[pattern].allow(principal)
+	}
+
+	subject := ""
+	if principal != nil {
+		subject = principal.Subject
+	}
+
+	fields := []interface{}{"method", method, "subject", subject, "rule", pattern, "allowed", allowed, "dry_run", rules.dryRun}
+	if allowed {
+		if rules.dryRun {
+			p.logger.Info(ctx, "authorization decision", fields...)
+		}
+
+		return nil
+	}
+
+	p.logger.Warn(ctx, "authorization decision", fields...)
+	if rules.dryRun {
+		return nil
+	}
+
+	return status.Errorf(codes.PermissionDenied, "permission denied: %s", method)
+}
+
+// allow reports whether the principal matches the rule.
+func (r *PolicyRule) allow(principal *Principal) bool {
+	if r.Public {
+		return true
+	}
+
+	if principal == nil {
+		return false
+	}
+
+	if len(r.Roles) == 0 && len(r.Scopes) == 0 {
+		return true
+	}
+
+	for _, role := range r.Roles {
+		if principal.HasRole(role) {
+			return true
+		}
+	}
+
+	for _, scope := range r.Scopes {
+		if principal.HasScope(scope) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// UnaryServerInterceptor returns a server unary interceptor which authorizes the requests,
+// it must run after the authentication interceptor.
+func (p *Policy) UnaryServerInterceptor() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo,
+		handler grpc.UnaryHandler) (interface{}, error) {
+		if err := p.Authorize(ctx, info.FullMethod); err != nil {
+			return nil, err
+		}
+
+		return handler(ctx, req)
+	}
+}
+
+// StreamServerInterceptor returns a server stream interceptor which authorizes the streams,
+// it must run after the authentication interceptor.
+func (p *Policy) StreamServerInterceptor() grpc.StreamServerInterceptor {
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		if err := p.Authorize(ss.Context(), info.FullMethod); err != nil {
+			return err
+		}
+
+		return handler(srv, ss)
+	}
+}
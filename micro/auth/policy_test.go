@@ -0,0 +1,115 @@
+package auth
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zaptest/observer"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/daheige/hephfx/logger"
+	"github.com/daheige/hephfx/settings"
+)
+
+func TestPolicy(t *testing.T) {
+	p := NewPolicy(PolicyConfig{
+		Rules: []PolicyRule{
+			{Method: "/Hello.Greeter/*", Roles: []string{"admin"}},
+			{Method: "/Hello.Greeter/SayHello", Scopes: []string{"hello:read"}},
+			{Method: "/Hello.Greeter/SayHello", Roles: []string{"user"}},
+			{Method: "/grpc.health.v1.Health/*", Public: true},
+			{Method: "/Hello.Profile/*"},
+		},
+	}, WithPolicyLogger(logger.New(logger.WithStdout(false))))
+
+	reader := NewContext(context.Background(), &Principal{Subject: "a", Scopes: []string{"hello:read"}})
+	user := NewContext(context.Background(), &Principal{Subject: "b", Roles: []string{"user"}})
+	admin := NewContext(context.Background(), &Principal{Subject: "c", Roles: []string{"admin"}})
+	anonymous := context.Background()
+
+	tests := []struct {
+		ctx     context.Context
+		method  string
+		allowed bool
+	}{
+		{reader, "/Hello.Greeter/SayHello", true},
+		{user, "/Hello.Greeter/SayHello", true},
+		{admin, "/Hello.Greeter/SayHello", false}, // the method rule is more specific than the service rule
+		{admin, "/Hello.Greeter/Delete", true},
+		{reader, "/Hello.Greeter/Delete", false},
+		{anonymous, "/grpc.health.v1.Health/Check", true},
+		{anonymous, "/Hello.Profile/Get", false},
+		{reader, "/Hello.Profile/Get", true},
+		{admin, "/Other.Service/Call", false}, // default deny
+	}
+	for _, tt := range tests {
+		err := p.Authorize(tt.ctx, tt.method)
+		if tt.allowed && err != nil || !tt.allowed && status.Code(err) != codes.PermissionDenied {
+			t.Errorf("Authorize(%s) = %v, allowed %v", tt.method, err, tt.allowed)
+		}
+	}
+}
+
+func TestPolicyDryRun(t *testing.T) {
+	core, logs := observer.New(zap.DebugLevel)
+	p := NewPolicy(PolicyConfig{
+		DryRun: true,
+		Rules:  []PolicyRule{{Method: "*", Roles: []string{"admin"}}},
+	}, WithPolicyLogger(logger.New(logger.WithStdout(false), logger.WithCores(core))))
+
+	admin := NewContext(context.Background(), &Principal{Subject: "c", Roles: []string{"admin"}})
+	if err := p.Authorize(admin, "/Hello.Greeter/SayHello"); err != nil {
+		t.Fatal(err)
+	}
+	if err := p.Authorize(context.Background(), "/Hello.Greeter/SayHello"); err != nil {
+		t.Fatalf("dry run must not deny: %v", err)
+	}
+
+	entries := logs.All()
+	if len(entries) != 2 || entries[0].Level != zap.InfoLevel || entries[1].Level != zap.WarnLevel {
+		t.Fatalf("decision logs = %v", entries)
+	}
+}
+
+func TestPolicyFromConfig(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "app.yaml")
+	writeConfig := func(role string) {
+		content := "authz:\n  rules:\n    - method: \"/Hello.Greeter/*\"\n      roles: [\"" + role + "\"]\n"
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	writeConfig("admin")
+	conf, err := settings.Load(path, settings.WithWatchFile())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	p, err := NewPolicyFromConfig(conf, "authz", WithPolicyLogger(logger.New(logger.WithStdout(false))))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	user := NewContext(context.Background(), &Principal{Subject: "b", Roles: []string{"user"}})
+	if err = p.Authorize(user, "/Hello.Greeter/SayHello"); status.Code(err) != codes.PermissionDenied {
+		t.Fatalf("expected PermissionDenied,got:%v", err)
+	}
+
+	// the rules are reloaded when the config file is changed
+	time.Sleep(100 * time.Millisecond)
+	writeConfig("user")
+	deadline := time.Now().Add(5 * time.Second)
+	for p.Authorize(user, "/Hello.Greeter/SayHello") != nil && time.Now().Before(deadline) {
+		time.Sleep(20 * time.Millisecond)
+	}
+
+	if err = p.Authorize(user, "/Hello.Greeter/SayHello"); err != nil {
+		t.Fatalf("authorize after reload: %v", err)
+	}
+}
@@ -0,0 +1,161 @@
+package micro
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+
+	"github.com/daheige/hephfx/example/pb"
+	"github.com/daheige/hephfx/micro/auth"
+)
+
+func TestWithAuth(t *testing.T) {
+	greeter := &testGreeter{}
+	s := newTestService(t, greeter,
+		WithHandlerFromEndpoints(pb.RegisterGreeterHandlerFromEndpoint),
+		WithAuth(auth.WithAuthenticators(
+			auth.NewAPIKeyAuthenticator(auth.WithAPIKey("secret-key", auth.Principal{Subject: "billing"})),
+		)),
+	)
+
+	handler, err := s.HTTPHandler()
+	if err != nil {
+		t.Fatalf("http handler error: %v", err)
+	}
+
+	r := httptest.NewRequest(http.MethodGet, "/v1/say/heige", nil)
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, r)
+	if w.Code != http.StatusUnauthorized {
+		t.Fatalf("gateway response code:%d body:%s", w.Code, w.Body.String())
+	}
+
+	// the api key header is forwarded by the annotator
+	r = httptest.NewRequest(http.MethodGet, "/v1/say/heige", nil)
+	r.Header.Set("X-Api-Key", "secret-key")
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, r)
+	if w.Code != http.StatusOK {
+		t.Fatalf("gateway response code:%d body:%s", w.Code, w.Body.String())
+	}
+
+	p, ok := auth.FromContext(greeter.lastContext())
+	if !ok || p.Subject != "billing" {
+		t.Fatalf("principal = %+v", p)
+	}
+}
+
+func TestWithAuthPolicy(t *testing.T) {
+	l, _ := newObservedLogger()
+	s := newTestService(t, &testGreeter{},
+		WithHandlerFromEndpoints(pb.RegisterGreeterHandlerFromEndpoint),
+		WithAuth(auth.WithAuthenticators(auth.NewAPIKeyAuthenticator(
+			auth.WithAPIKey("user-key", auth.Principal{Subject: "user", Roles: []string{"user"}}),
+			auth.WithAPIKey("admin-key", auth.Principal{Subject: "admin", Roles: []string{"admin"}}),
+		))),
+		WithAuthPolicy(auth.NewPolicy(auth.PolicyConfig{
+			Rules: []auth.PolicyRule{{Method: "/Hello.Greeter/*", Roles: []string{"admin"}}},
+		}, auth.WithPolicyLogger(l))),
+	)
+
+	handler, err := s.HTTPHandler()
+	if err != nil {
+		t.Fatalf("http handler error: %v", err)
+	}
+
+	for key, code := range map[string]int{"user-key": http.StatusForbidden, "admin-key": http.StatusOK} {
+		r := httptest.NewRequest(http.MethodGet, "/v1/say/heige", nil)
+		r.Header.Set("X-Api-Key", key)
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+		if w.Code != code {
+			t.Fatalf("%s response code:%d body:%s", key, w.Code, w.Body.String())
+		}
+	}
+}
+
+func TestWithAuthMTLS(t *testing.T) {
+	ca := newTestCA(t)
+	dir := t.TempDir()
+	certPEM, keyPEM := ca.issue(t, "server", x509.ExtKeyUsageServerAuth)
+	certFile := filepath.Join(dir, "server.crt")
+	keyFile := filepath.Join(dir, "server.key")
+	caFile := filepath.Join(dir, "ca.crt")
+	writeFile(t, certFile, certPEM)
+	writeFile(t, keyFile, keyPEM)
+	writeFile(t, caFile, ca.pem)
+
+	greeter := &testGreeter{}
+	address := freeAddress(t)
+	s := NewService(
+		address,
+		WithEnableGRPCShareAddress(),
+		WithShutdownTimeout(time.Second),
+		WithHandlerFromEndpoints(pb.RegisterGreeterHandlerFromEndpoint),
+		WithTLSCertFile(certFile, keyFile),
+		WithTLSClientCAFile(caFile),
+		WithAuth(auth.WithAuthenticators(
+			auth.NewMTLSAuthenticator(),
+			auth.NewAPIKeyAuthenticator(auth.WithAPIKey("secret-key", auth.Principal{Subject: "billing"})),
+		)),
+	)
+	pb.RegisterGreeterServer(s.GRPCServer, greeter)
+	runService(t, s, address)
+
+	clientCert := ca.keyPair(t, "ops", x509.ExtKeyUsageClientAuth)
+	clientTLS := &tls.Config{RootCAs: ca.pool, Certificates: []tls.Certificate{clientCert}}
+
+	// the gRPC client is authenticated by its certificate
+	conn, err := grpc.NewClient(address, grpc.WithTransportCredentials(credentials.NewTLS(clientTLS)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	_, err = pb.NewGreeterClient(conn).SayHello(context.Background(), &pb.HelloReq{Name: "heige"})
+	if err != nil {
+		t.Fatalf("say hello error: %v", err)
+	}
+
+	p, ok := auth.FromContext(greeter.lastContext())
+	if !ok || p.Subject != "ops" || p.Authenticator != "mtls" {
+		t.Fatalf("principal = %+v", p)
+	}
+
+	// the certificate of the gateway dial is not an identity of the http client
+	client := &http.Client{Transport: &http.Transport{TLSClientConfig: clientTLS, ForceAttemptHTTP2: true}}
+	get := func(apiKey string) int {
+		r, _ := http.NewRequest(http.MethodGet, "https://"+address+"/v1/say/heige", nil)
+		if apiKey != "" {
+			r.Header.Set("X-Api-Key", apiKey)
+		}
+
+		resp, err := client.Do(r)
+		if err != nil {
+			t.Fatalf("gateway request error: %v", err)
+		}
+		resp.Body.Close()
+		return resp.StatusCode
+	}
+
+	if code := get(""); code != http.StatusUnauthorized {
+		t.Fatalf("gateway response code:%d,want %d", code, http.StatusUnauthorized)
+	}
+
+	if code := get("secret-key"); code != http.StatusOK {
+		t.Fatalf("gateway response code:%d,want %d", code, http.StatusOK)
+	}
+
+	p, ok = auth.FromContext(greeter.lastContext())
+	if !ok || p.Subject != "billing" || p.Authenticator != "apikey" {
+		t.Fatalf("principal = %+v", p)
+	}
+}
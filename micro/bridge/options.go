@@ -4,6 +4,8 @@ import (
 	"time"
 
 	"google.golang.org/grpc"
+
+	"github.com/daheige/hephfx/micro/tracing"
 )
 
 // ClientOptions 创建 Client 时的选项。
@@ -59,3 +61,10 @@ func WithServiceDialOpts(name string, opts ...grpc.DialOption) ClientOption {
 		o.serviceDialOpts[name] = append(o.serviceDialOpts[name], opts...)
 	}
 }
+
+// WithTracing 为所有服务安装 OpenTelemetry 客户端拦截器，通过 traceparent 透传 trace 上下文。
+func WithTracing(opts ...tracing.Option) ClientOption {
+	return func(o *ClientOptions) {
+		o.options = append(o.options, tracing.DialOptions(opts...)...)
+	}
+}
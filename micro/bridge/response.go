@@ -3,8 +3,12 @@ package bridge
 import (
 	"errors"
 	"fmt"
+	"time"
 
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
 	"google.golang.org/grpc/status"
+
+	"github.com/daheige/hephfx/micro/gerrors"
 )
 
 // ErrServiceNotFound 服务未找到错误。
@@ -25,6 +29,26 @@ func GRPCError(err error) (*status.Status, bool) {
 	return st, ok
 }
 
+// GRPCErrorDetails 从 gRPC 错误中提取 google.rpc.Status 错误详情。
+func GRPCErrorDetails(err error) (*gerrors.Details, bool) {
+	return gerrors.FromError(err)
+}
+
+// ErrorReason 返回 gRPC 错误中 ErrorInfo 的 reason，没有时返回空字符串。
+func ErrorReason(err error) string {
+	return gerrors.Reason(err)
+}
+
+// FieldViolations 返回 gRPC 错误中 BadRequest 的字段校验错误。
+func FieldViolations(err error) []*errdetails.BadRequest_FieldViolation {
+	return gerrors.FieldViolations(err)
+}
+
+// RetryDelay 返回 gRPC 错误中 RetryInfo 建议的重试间隔。
+func RetryDelay(err error) (time.Duration, bool) {
+	return gerrors.RetryDelay(err)
+}
+
 func serviceNotFound(name string) error {
 	return fmt.Errorf("%s:%w", name, ErrServiceNotFound)
 }
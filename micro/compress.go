@@ -0,0 +1,332 @@
+package micro
+
+import (
+	"compress/gzip"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+const (
+	// encodingGzip the gzip content encoding
+	encodingGzip = "gzip"
+
+	// encodingZstd the zstd content encoding
+	encodingZstd = "zstd"
+
+	// defaultCompressMinSize the default min size of the compressed responses
+	defaultCompressMinSize = 1024
+)
+
+// CompressOption compression middleware option
+type CompressOption func(c *compressor)
+
+// compressor compresses the http gateway responses.
+type compressor struct {
+	minSize      int
+	encodings    []string
+	gzipLevel    int
+	contentTypes []string
+	gzipPool     sync.Pool
+	zstdPool     sync.Pool
+}
+
+// WithCompressMinSize returns a CompressOption to set the min size of the compressed responses,
+// the smaller responses are not compressed,default: 1024
+func WithCompressMinSize(size int) CompressOption {
+	return func(c *compressor) {
+		c.minSize = size
+	}
+}
+
+// WithCompressEncodings returns a CompressOption to set the encodings in preference order,
+// gzip and zstd are supported,default: zstd,gzip
+func WithCompressEncodings(encodings ...string) CompressOption {
+	return func(c *compressor) {
+		c.encodings = encodings
+	}
+}
+
+// WithCompressGzipLevel returns a CompressOption to set the gzip level,default: gzip.DefaultCompression
+// The level out of gzip.HuffmanOnly and gzip.BestCompression falls back to gzip.DefaultCompression.
+func WithCompressGzipLevel(level int) CompressOption {
+	return func(c *compressor) {
+		c.gzipLevel = level
+	}
+}
+
+// WithCompressContentTypes returns a CompressOption to compress the responses of the content types only,
+// eg: application/json,text/
+// The content types are matched by prefix,all the compressible responses are compressed by default.
+func WithCompressContentTypes(contentTypes ...string) CompressOption {
+	return func(c *compressor) {
+		c.contentTypes = contentTypes
+	}
+}
+
+// incompressibleContentTypes the content types which are compressed already or streamed as events
+var incompressibleContentTypes = []string{
+	"image/", "video/", "audio/", "font/woff",
+	"application/zip", "application/gzip", "application/x-gzip", "application/zstd",
+	"application/grpc", "text/event-stream",
+}
+
+// CompressMiddleware returns the http middleware which compresses the responses by gzip or zstd
+// according to the Accept-Encoding header.
+// The responses which are flushed before reaching the min size are sent uncompressed,
+// eg: the streaming responses.
+func CompressMiddleware(opts ...CompressOption) HTTPMiddleware {
+	c := &compressor{
+		minSize:   defaultCompressMinSize,
+		encodings: []string{encodingZstd, encodingGzip},
+		gzipLevel: gzip.DefaultCompression,
+	}
+	for _, o := range opts {
+		o(c)
+	}
+
+	// gzip.NewWriterLevel returns a nil writer for the invalid level
+	if c.gzipLevel < gzip.HuffmanOnly || c.gzipLevel > gzip.BestCompression {
+		c.gzipLevel = gzip.DefaultCompression
+	}
+
+	c.gzipPool.New = func() interface{} {
+		w, _ := gzip.NewWriterLevel(io.Discard, c.gzipLevel)
+		return w
+	}
+	c.zstdPool.New = func() interface{} {
+		w, _ := zstd.NewWriter(nil, zstd.WithEncoderConcurrency(1))
+		return w
+	}
+
+	return func(h http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Add("Vary", "Accept-Encoding")
+			encoding := c.negotiate(r.Header.Get("Accept-Encoding"))
+			if encoding == "" || r.Method == http.MethodHead || r.Header.Get("Upgrade") != "" {
+				h.ServeHTTP(w, r)
+				return
+			}
+
+			cw := &compressResponseWriter{ResponseWriter: w, compressor: c, encoding: encoding}
+			defer cw.Close()
+
+			h.ServeHTTP(cw, r)
+		})
+	}
+}
+
+// negotiate returns the supported encoding with the highest quality of the Accept-Encoding header,
+// the encodings with the same quality are chosen in the preference order.
+func (c *compressor) negotiate(acceptEncoding string) string {
+	if acceptEncoding == "" {
+		return ""
+	}
+
+	qualities := make(map[string]float64)
+	for _, part := range strings.Split(acceptEncoding, ",") {
+		name, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		q := 1.0
+		if value, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+			if f, err := strconv.ParseFloat(value, 64); err == nil {
+				q = f
+			}
+		}
+
+		qualities[strings.ToLower(strings.TrimSpace(name))] = q
+	}
+
+	var (
+		best    string
+		bestQ   float64
+		starQ   = qualities["*"]
+		hasStar = starQ > 0
+	)
+	for _, encoding := range c.encodings {
+		if encoding != encodingGzip && encoding != encodingZstd {
+			continue
+		}
+
+		q, ok := qualities[encoding]
+		if !ok && hasStar {
+			q = starQ
+		}
+
+		if q > bestQ {
+			best, bestQ = encoding, q
+		}
+	}
+
+	return best
+}
+
+// compressible reports whether the response is compressible by the headers.
+func (c *compressor) compressible(header http.Header) bool {
+	if header.Get("Content-Encoding") != "" {
+		return false
+	}
+
+	contentType := strings.ToLower(header.Get("Content-Type"))
+	for _, t := range incompressibleContentTypes {
+		if strings.HasPrefix(contentType, t) {
+			return false
+		}
+	}
+
+	if len(c.contentTypes) == 0 {
+		return true
+	}
+
+	for _, t := range c.contentTypes {
+		if strings.HasPrefix(contentType, t) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// compressResponseWriter buffers the response until the min size is reached,
+// then writes the response compressed or uncompressed.
+type compressResponseWriter struct {
+	http.ResponseWriter
+	compressor  *compressor
+	encoding    string
+	status      int
+	buf         []byte
+	decided     bool
+	wroteHeader bool
+	encoder     io.WriteCloser
+}
+
+// WriteHeader records the status code,it is written when the encoding is decided.
+func (w *compressResponseWriter) WriteHeader(code int) {
+	if w.wroteHeader {
+		return
+	}
+
+	w.wroteHeader = true
+	w.status = code
+	if code < http.StatusOK || code == http.StatusNoContent || code == http.StatusNotModified {
+		// no body
+		_ = w.decide(false)
+	}
+}
+
+// Write buffers or writes the response body.
+func (w *compressResponseWriter) Write(p []byte) (int, error) {
+	if !w.wroteHeader {
+		w.WriteHeader(http.StatusOK)
+	}
+
+	if w.decided {
+		if w.encoder != nil {
+			return w.encoder.Write(p)
+		}
+
+		return w.ResponseWriter.Write(p)
+	}
+
+	w.buf = append(w.buf, p...)
+	if len(w.buf) >= w.compressor.minSize {
+		if err := w.decide(true); err != nil {
+			return 0, err
+		}
+	}
+
+	return len(p), nil
+}
+
+// Flush writes the buffered response and flushes the encoder.
+func (w *compressResponseWriter) Flush() {
+	if !w.wroteHeader {
+		w.WriteHeader(http.StatusOK)
+	}
+
+	if !w.decided {
+		_ = w.decide(false)
+	}
+
+	if f, ok := w.encoder.(interface{ Flush() error }); ok {
+		_ = f.Flush()
+	}
+
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// Unwrap returns the underlying ResponseWriter for http.ResponseController.
+func (w *compressResponseWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// Close writes the buffered response and closes the encoder.
+func (w *compressResponseWriter) Close() error {
+	if !w.wroteHeader {
+		// nothing is written
+		return nil
+	}
+
+	if !w.decided {
+		if err := w.decide(len(w.buf) >= w.compressor.minSize); err != nil {
+			return err
+		}
+	}
+
+	if w.encoder == nil {
+		return nil
+	}
+
+	err := w.encoder.Close()
+	switch e := w.encoder.(type) {
+	case *gzip.Writer:
+		w.compressor.gzipPool.Put(e)
+	case *zstd.Encoder:
+		w.compressor.zstdPool.Put(e)
+	}
+
+	w.encoder = nil
+	return err
+}
+
+// decide writes the header and the buffered body,the body is compressed
+// when compress is true and the response is compressible.
+func (w *compressResponseWriter) decide(compress bool) error {
+	w.decided = true
+	header := w.Header()
+	if compress && w.compressor.compressible(header) {
+		header.Set("Content-Encoding", w.encoding)
+		header.Del("Content-Length")
+		header.Del("Accept-Ranges")
+		switch w.encoding {
+		case encodingGzip:
+			gw := w.compressor.gzipPool.Get().(*gzip.Writer)
+			gw.Reset(w.ResponseWriter)
+			w.encoder = gw
+		case encodingZstd:
+			zw := w.compressor.zstdPool.Get().(*zstd.Encoder)
+			zw.Reset(w.ResponseWriter)
+			w.encoder = zw
+		}
+	}
+
+	w.ResponseWriter.WriteHeader(w.status)
+	if len(w.buf) == 0 {
+		return nil
+	}
+
+	var err error
+	if w.encoder != nil {
+		_, err = w.encoder.Write(w.buf)
+	} else {
+		_, err = w.ResponseWriter.Write(w.buf)
+	}
+
+	w.buf = nil
+	return err
+}
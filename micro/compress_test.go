@@ -0,0 +1,111 @@
+package micro
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+func TestCompressMiddleware(t *testing.T) {
+	body := strings.Repeat(`{"message":"hello"}`, 100)
+	h := CompressMiddleware(WithCompressMinSize(512))(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", r.URL.Query().Get("type"))
+		_, _ = w.Write([]byte(body[:len(body)/2]))
+		_, _ = w.Write([]byte(body[len(body)/2:]))
+	}))
+
+	cases := []struct {
+		acceptEncoding string
+		contentType    string
+		encoding       string
+	}{
+		{"gzip, deflate, br", "application/json", "gzip"},
+		{"gzip, zstd", "application/json", "zstd"},
+		{"gzip;q=1.0, zstd;q=0.5", "application/json", "gzip"},
+		{"*", "application/json", "zstd"},
+		{"zstd;q=0", "application/json", ""},
+		{"", "application/json", ""},
+		{"gzip", "image/png", ""},
+		{"gzip", "text/event-stream", ""},
+	}
+	for _, c := range cases {
+		r := httptest.NewRequest(http.MethodGet, "/?type="+c.contentType, nil)
+		r.Header.Set("Accept-Encoding", c.acceptEncoding)
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, r)
+
+		if got := rec.Header().Get("Content-Encoding"); got != c.encoding {
+			t.Fatalf("%q %s: encoding = %q", c.acceptEncoding, c.contentType, got)
+		}
+
+		if got := decompress(t, c.encoding, rec.Body.Bytes()); got != body {
+			t.Fatalf("%q: body = %s", c.acceptEncoding, got)
+		}
+	}
+}
+
+func TestCompressInvalidGzipLevel(t *testing.T) {
+	body := strings.Repeat("hello,", 500)
+	h := CompressMiddleware(WithCompressGzipLevel(42))(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(body))
+	}))
+
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	r.Header.Set("Accept-Encoding", "gzip")
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, r)
+	if rec.Header().Get("Content-Encoding") != "gzip" || decompress(t, "gzip", rec.Body.Bytes()) != body {
+		t.Fatalf("response headers:%v", rec.Header())
+	}
+}
+
+func TestCompressMinSize(t *testing.T) {
+	h := CompressMiddleware()(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusCreated)
+		_, _ = w.Write([]byte("small"))
+	}))
+
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	r.Header.Set("Accept-Encoding", "gzip")
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, r)
+	if rec.Code != http.StatusCreated || rec.Header().Get("Content-Encoding") != "" || rec.Body.String() != "small" {
+		t.Fatalf("response = %d %v %s", rec.Code, rec.Header(), rec.Body.String())
+	}
+	if rec.Header().Get("Vary") != "Accept-Encoding" {
+		t.Fatalf("vary = %s", rec.Header().Get("Vary"))
+	}
+}
+
+func decompress(t *testing.T, encoding string, b []byte) string {
+	t.Helper()
+
+	var (
+		r   io.Reader
+		err error
+	)
+	switch encoding {
+	case encodingGzip:
+		r, err = gzip.NewReader(bytes.NewReader(b))
+	case encodingZstd:
+		r, err = zstd.NewReader(bytes.NewReader(b))
+	default:
+		return string(b)
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	out, err := io.ReadAll(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return string(out)
+}
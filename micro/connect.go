@@ -0,0 +1,799 @@
+package micro
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+
+	"google.golang.org/genproto/googleapis/rpc/code"
+	spb "google.golang.org/genproto/googleapis/rpc/status"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/reflect/protoregistry"
+	"google.golang.org/protobuf/types/descriptorpb"
+	"google.golang.org/protobuf/types/dynamicpb"
+)
+
+const (
+	// connectStreamContentType the content type prefix of the Connect streaming requests,
+	// eg: application/connect+proto or application/connect+json
+	connectStreamContentType = "application/connect+"
+
+	// connectFlagCompressed the flag of the compressed Connect envelope
+	connectFlagCompressed = 0x01
+
+	// connectFlagEndStream the flag of the Connect end-stream envelope which carries the error and trailers
+	connectFlagEndStream = 0x02
+
+	// connectMaxTimeoutMs the max timeout in milliseconds of the grpc-timeout header,it has 8 digits at most
+	connectMaxTimeoutMs = 99999999
+
+	// connectProtocolVersion the supported version of the Connect-Protocol-Version header
+	connectProtocolVersion = "1"
+
+	// defaultConnectMaxRecvMsgSize the default max size of the request message,
+	// it is the same as the default max receive message size of the gRPC server.
+	defaultConnectMaxRecvMsgSize = 4 << 20
+)
+
+var (
+	// errConnectMessageTooLarge the request message is larger than the max receive message size
+	errConnectMessageTooLarge = errors.New("the message is larger than the max receive message size")
+
+	// errConnectUnsupportedCompression the request message is compressed by the compression other than gzip
+	errConnectUnsupportedCompression = errors.New("unsupported compression")
+)
+
+// connectHTTPStatus the http status codes of the Connect unary errors
+var connectHTTPStatus = map[codes.Code]int{
+	codes.Canceled:           499,
+	codes.Unknown:            http.StatusInternalServerError,
+	codes.InvalidArgument:    http.StatusBadRequest,
+	codes.DeadlineExceeded:   http.StatusGatewayTimeout,
+	codes.NotFound:           http.StatusNotFound,
+	codes.AlreadyExists:      http.StatusConflict,
+	codes.PermissionDenied:   http.StatusForbidden,
+	codes.ResourceExhausted:  http.StatusTooManyRequests,
+	codes.FailedPrecondition: http.StatusBadRequest,
+	codes.Aborted:            http.StatusConflict,
+	codes.OutOfRange:         http.StatusBadRequest,
+	codes.Unimplemented:      http.StatusNotImplemented,
+	codes.Internal:           http.StatusInternalServerError,
+	codes.Unavailable:        http.StatusServiceUnavailable,
+	codes.DataLoss:           http.StatusInternalServerError,
+	codes.Unauthenticated:    http.StatusUnauthorized,
+}
+
+var (
+	// connectMarshalOptions the json marshal options of the Connect json codec
+	connectMarshalOptions = protojson.MarshalOptions{}
+
+	// connectUnmarshalOptions the json unmarshal options of the Connect json codec
+	connectUnmarshalOptions = protojson.UnmarshalOptions{DiscardUnknown: true}
+)
+
+// connectError the error of the Connect protocol,
+// eg: {"code":"not_found","message":"user not found","details":[{"type":"...","value":"..."}]}
+type connectError struct {
+	Code    string               `json:"code"`
+	Message string               `json:"message,omitempty"`
+	Details []connectErrorDetail `json:"details,omitempty"`
+}
+
+// connectErrorDetail the error detail,the value is the base64 encoded protobuf message
+type connectErrorDetail struct {
+	Type  string `json:"type"`
+	Value string `json:"value"`
+}
+
+// connectHandler serves the Connect requests of the methods registered on the gRPC server,
+// the requests are translated into gRPC requests.
+type connectHandler struct {
+	grpcServer     *grpc.Server
+	maxRecvMsgSize int
+	once           sync.Once
+	methods        map[string]bool // the registered methods,the value reports whether the GET requests are allowed
+}
+
+// ConnectOption Connect handler option
+type ConnectOption func(c *connectHandler)
+
+// WithConnectMaxRecvMsgSize returns a ConnectOption to set the max size of the request message,
+// the request body is limited by it before it is decompressed and decoded,default: 4MB.
+// It should be the same as grpc.MaxRecvMsgSize of the gRPC server.
+func WithConnectMaxRecvMsgSize(size int) ConnectOption {
+	return func(c *connectHandler) {
+		c.maxRecvMsgSize = size
+	}
+}
+
+// newConnectHandler returns the Connect handler of grpcServer.
+func newConnectHandler(grpcServer *grpc.Server, opts ...ConnectOption) *connectHandler {
+	c := &connectHandler{grpcServer: grpcServer, maxRecvMsgSize: defaultConnectMaxRecvMsgSize}
+	for _, o := range opts {
+		o(c)
+	}
+
+	return c
+}
+
+// match reports whether the request is a Connect request of the registered methods:
+// the streaming requests of application/connect+proto and application/connect+json,
+// the unary requests of application/proto and application/json,
+// and the unary GET requests with the connect=v1 query.
+// The GET requests of the methods with side effects are matched and rejected by ServeHTTP.
+func (c *connectHandler) match(r *http.Request) bool {
+	contentType := connectMediaType(r.Header.Get("Content-Type"))
+	switch {
+	case r.Method == http.MethodGet:
+		if r.URL.Query().Get("connect") != "v1" {
+			return false
+		}
+	case r.Method != http.MethodPost:
+		return false
+	case strings.HasPrefix(contentType, connectStreamContentType):
+	case contentType != "application/proto" && contentType != "application/json":
+		return false
+	}
+
+	_, ok := c.method(r.URL.Path)
+	return ok
+}
+
+// method reports whether the method is registered on the gRPC server,
+// and whether it allows the GET requests,eg: /Hello.Greeter/SayHello
+func (c *connectHandler) method(path string) (allowGet bool, ok bool) {
+	c.once.Do(func() {
+		c.methods = make(map[string]bool)
+		for service, info := range c.grpcServer.GetServiceInfo() {
+			for _, m := range info.Methods {
+				c.methods["/"+service+"/"+m.Name] = hasNoSideEffects(service, m.Name)
+			}
+		}
+	})
+
+	allowGet, ok = c.methods[path]
+	return allowGet, ok
+}
+
+// hasNoSideEffects reports whether the method is declared without side effects,
+// eg: option idempotency_level = NO_SIDE_EFFECTS;
+func hasNoSideEffects(service string, method string) bool {
+	desc, err := protoregistry.GlobalFiles.FindDescriptorByName(
+		protoreflect.FullName(service).Append(protoreflect.Name(method)))
+	if err != nil {
+		return false
+	}
+
+	md, ok := desc.(protoreflect.MethodDescriptor)
+	if !ok {
+		return false
+	}
+
+	opts, ok := md.Options().(*descriptorpb.MethodOptions)
+	return ok && opts.GetIdempotencyLevel() == descriptorpb.MethodOptions_NO_SIDE_EFFECTS
+}
+
+// ServeHTTP serves the Connect request by the gRPC server.
+func (c *connectHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if v := r.Header.Get("Connect-Protocol-Version"); v != "" && v != connectProtocolVersion {
+		writeConnectError(w, nil, codes.InvalidArgument, "unsupported Connect-Protocol-Version: "+v, nil)
+		return
+	}
+
+	// the GET requests are cached and sent cross-site by browsers,
+	// so they are only allowed for the methods without side effects.
+	if allowGet, _ := c.method(r.URL.Path); r.Method == http.MethodGet && !allowGet {
+		w.Header().Set("Allow", http.MethodPost)
+		writeConnectError(w, nil, codes.Unimplemented, "GET is only allowed for the methods without side effects", nil)
+		return
+	}
+
+	contentType := connectMediaType(r.Header.Get("Content-Type"))
+	if codecName, ok := strings.CutPrefix(contentType, connectStreamContentType); ok {
+		c.serveStream(w, r, codecName)
+		return
+	}
+
+	c.serveUnary(w, r)
+}
+
+// serveUnary serves the unary request,the message is sent without the envelope,
+// the error is written as json with the http status of the code.
+func (c *connectHandler) serveUnary(w http.ResponseWriter, r *http.Request) {
+	codecName, body, err := connectUnaryBody(w, r, c.maxRecvMsgSize)
+	if err != nil {
+		writeConnectError(w, nil, connectErrorCode(err, codes.InvalidArgument), err.Error(), nil)
+		return
+	}
+
+	codec, err := newConnectCodec(r.URL.Path, codecName)
+	if err != nil {
+		writeConnectError(w, nil, codes.Unimplemented, err.Error(), nil)
+		return
+	}
+
+	msg, err := codec.toProto(body)
+	if err != nil {
+		writeConnectError(w, nil, codes.InvalidArgument, err.Error(), nil)
+		return
+	}
+
+	rec := &connectUnaryRecorder{header: make(http.Header)}
+	c.grpcServer.ServeHTTP(rec, connectGRPCRequest(r, bytes.NewReader(grpcFrame(msg))))
+
+	header, trailers := rec.metadata()
+	st, message, details := grpcStatusOf(trailers)
+	for key, values := range trailers {
+		header[http.CanonicalHeaderKey("Trailer-"+key)] = values
+	}
+
+	if st != codes.OK {
+		writeConnectError(w, header, st, message, details)
+		return
+	}
+
+	var reply []byte
+	if len(rec.body) >= 5 {
+		reply = rec.body[5:]
+	}
+
+	b, err := codec.fromProto(reply)
+	if err != nil {
+		writeConnectError(w, header, codes.Internal, err.Error(), nil)
+		return
+	}
+
+	for key, values := range header {
+		w.Header()[key] = values
+	}
+
+	w.Header().Set("Content-Type", "application/"+codecName)
+	w.Header().Set("Content-Length", strconv.Itoa(len(b)))
+	_, _ = w.Write(b)
+}
+
+// serveStream serves the streaming request,the messages are sent in the envelopes,
+// the error and trailers are sent in the end-stream envelope.
+func (c *connectHandler) serveStream(w http.ResponseWriter, r *http.Request, codecName string) {
+	codec, err := newConnectCodec(r.URL.Path, codecName)
+	if err != nil {
+		writeConnectError(w, nil, codes.Unimplemented, err.Error(), nil)
+		return
+	}
+
+	compressed, err := isConnectCompressed(r.Header.Get("Connect-Content-Encoding"))
+	if err != nil {
+		writeConnectError(w, nil, codes.Unimplemented, err.Error(), nil)
+		return
+	}
+
+	body, bodyWriter := io.Pipe()
+	defer body.Close()
+
+	// the copy error is sent before the body is closed,so it is received when the gRPC server fails to read
+	copyErr := make(chan error, 1)
+	go func() {
+		err := copyConnectFrames(bodyWriter, r.Body, codec, compressed, c.maxRecvMsgSize)
+		copyErr <- err
+		_ = bodyWriter.CloseWithError(err)
+	}()
+
+	// the gRPC server reads the request body while writing the response
+	_ = http.NewResponseController(w).EnableFullDuplex()
+
+	sw := &connectStreamWriter{
+		ResponseWriter: w,
+		header:         make(http.Header),
+		codec:          codec,
+		contentType:    connectStreamContentType + codecName,
+	}
+	c.grpcServer.ServeHTTP(sw, connectGRPCRequest(r, body))
+	select {
+	case err := <-copyErr:
+		if errors.Is(err, errConnectMessageTooLarge) {
+			sw.err = err
+		}
+	default:
+	}
+
+	sw.finish()
+}
+
+// connectUnaryBody returns the codec name and the message of the unary request,
+// the GET request carries the message in the query,eg: ?connect=v1&encoding=json&message={}
+// The message is limited to limit bytes before and after it is decompressed.
+func connectUnaryBody(w http.ResponseWriter, r *http.Request, limit int) (string, []byte, error) {
+	var (
+		codecName   string
+		body        []byte
+		compression string
+	)
+	if r.Method == http.MethodGet {
+		query := r.URL.Query()
+		codecName, compression = query.Get("encoding"), query.Get("compression")
+		body = []byte(query.Get("message"))
+		if query.Get("base64") == "1" {
+			b, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(string(body), "="))
+			if err != nil {
+				return "", nil, fmt.Errorf("decode message error: %w", err)
+			}
+
+			body = b
+		}
+	} else {
+		b, err := io.ReadAll(http.MaxBytesReader(w, r.Body, int64(limit)))
+		if err != nil {
+			var maxBytesErr *http.MaxBytesError
+			if errors.As(err, &maxBytesErr) {
+				return "", nil, errConnectMessageTooLarge
+			}
+
+			return "", nil, fmt.Errorf("read request body error: %w", err)
+		}
+
+		codecName = strings.TrimPrefix(connectMediaType(r.Header.Get("Content-Type")), "application/")
+		body, compression = b, r.Header.Get("Content-Encoding")
+	}
+
+	if len(body) > limit {
+		return "", nil, errConnectMessageTooLarge
+	}
+
+	compressed, err := isConnectCompressed(compression)
+	if err != nil {
+		return "", nil, err
+	}
+
+	if compressed {
+		b, err := gunzip(body, limit)
+		if err != nil {
+			return "", nil, err
+		}
+
+		body = b
+	}
+
+	return codecName, body, nil
+}
+
+// isConnectCompressed reports whether the messages are compressed by gzip,
+// the compressions other than gzip and identity are not supported.
+func isConnectCompressed(compression string) (bool, error) {
+	switch compression {
+	case "", "identity":
+		return false, nil
+	case "gzip":
+		return true, nil
+	default:
+		return false, fmt.Errorf("%w: %q", errConnectUnsupportedCompression, compression)
+	}
+}
+
+// connectGRPCRequest returns the gRPC request of the Connect request with the body,
+// the Connect-Timeout-Ms header is converted to the grpc-timeout header.
+func connectGRPCRequest(r *http.Request, body io.Reader) *http.Request {
+	req := r.Clone(r.Context())
+	req.Method = http.MethodPost
+	req.Proto, req.ProtoMajor, req.ProtoMinor = "HTTP/2.0", 2, 0
+	req.Body = io.NopCloser(body)
+	req.ContentLength = -1
+	req.Header.Set("Content-Type", "application/grpc+proto")
+	req.Header.Set("Te", "trailers")
+	if ms, err := strconv.ParseInt(r.Header.Get("Connect-Timeout-Ms"), 10, 64); err == nil && ms > 0 {
+		if ms > connectMaxTimeoutMs {
+			req.Header.Set("Grpc-Timeout", strconv.FormatInt(ms/1000, 10)+"S")
+		} else {
+			req.Header.Set("Grpc-Timeout", strconv.FormatInt(ms, 10)+"m")
+		}
+	}
+
+	for _, key := range []string{
+		"Connect-Protocol-Version", "Connect-Timeout-Ms", "Connect-Content-Encoding", "Connect-Accept-Encoding",
+		"Content-Encoding", "Accept-Encoding", "Content-Length",
+	} {
+		req.Header.Del(key)
+	}
+
+	return req
+}
+
+// copyConnectFrames translates the Connect envelopes of the request body into the gRPC frames,
+// the messages are limited to limit bytes before and after they are decompressed.
+func copyConnectFrames(dst io.Writer, src io.Reader, codec *connectCodec, compressed bool, limit int) error {
+	prefix := make([]byte, 5)
+	for {
+		if _, err := io.ReadFull(src, prefix); err != nil {
+			if errors.Is(err, io.EOF) {
+				return nil
+			}
+
+			return err
+		}
+
+		n := binary.BigEndian.Uint32(prefix[1:])
+		if int64(n) > int64(limit) {
+			return errConnectMessageTooLarge
+		}
+
+		data := make([]byte, n)
+		if _, err := io.ReadFull(src, data); err != nil {
+			return err
+		}
+
+		if prefix[0]&connectFlagCompressed != 0 {
+			if !compressed {
+				return errors.New("the compressed message without Connect-Content-Encoding")
+			}
+
+			b, err := gunzip(data, limit)
+			if err != nil {
+				return err
+			}
+
+			data = b
+		}
+
+		msg, err := codec.toProto(data)
+		if err != nil {
+			return err
+		}
+
+		if _, err = dst.Write(grpcFrame(msg)); err != nil {
+			return err
+		}
+	}
+}
+
+// connectCodec converts the Connect messages to the gRPC protobuf messages,
+// the json messages are converted by the method descriptors of the global registry.
+type connectCodec struct {
+	json   bool
+	input  protoreflect.MessageDescriptor
+	output protoreflect.MessageDescriptor
+}
+
+// newConnectCodec returns the codec of the method,eg: /Hello.Greeter/SayHello
+func newConnectCodec(method string, name string) (*connectCodec, error) {
+	switch name {
+	case "proto":
+		return &connectCodec{}, nil
+	case "json":
+	default:
+		return nil, fmt.Errorf("unsupported codec: %q", name)
+	}
+
+	service, methodName, _ := strings.Cut(strings.TrimPrefix(method, "/"), "/")
+	desc, err := protoregistry.GlobalFiles.FindDescriptorByName(protoreflect.FullName(service))
+	if err != nil {
+		return nil, fmt.Errorf("find service %s descriptor error: %w", service, err)
+	}
+
+	sd, ok := desc.(protoreflect.ServiceDescriptor)
+	if !ok {
+		return nil, fmt.Errorf("%s is not a service", service)
+	}
+
+	md := sd.Methods().ByName(protoreflect.Name(methodName))
+	if md == nil {
+		return nil, fmt.Errorf("method %s not found", method)
+	}
+
+	return &connectCodec{json: true, input: md.Input(), output: md.Output()}, nil
+}
+
+// toProto converts the request message to protobuf.
+func (c *connectCodec) toProto(b []byte) ([]byte, error) {
+	if !c.json {
+		return b, nil
+	}
+
+	msg := newMessage(c.input)
+	if err := connectUnmarshalOptions.Unmarshal(b, msg); err != nil {
+		return nil, err
+	}
+
+	return proto.Marshal(msg)
+}
+
+// fromProto converts the protobuf response message.
+func (c *connectCodec) fromProto(b []byte) ([]byte, error) {
+	if !c.json {
+		return b, nil
+	}
+
+	msg := newMessage(c.output)
+	if err := proto.Unmarshal(b, msg); err != nil {
+		return nil, err
+	}
+
+	return connectMarshalOptions.Marshal(msg)
+}
+
+// newMessage returns the message of the descriptor,
+// the dynamic message is used when the message type is not registered.
+func newMessage(desc protoreflect.MessageDescriptor) proto.Message {
+	if mt, err := protoregistry.GlobalTypes.FindMessageByName(desc.FullName()); err == nil {
+		return mt.New().Interface()
+	}
+
+	return dynamicpb.NewMessage(desc)
+}
+
+// connectUnaryRecorder records the gRPC response of the unary request.
+type connectUnaryRecorder struct {
+	header http.Header
+	body   []byte
+}
+
+// Header returns the gRPC response headers and trailers.
+func (r *connectUnaryRecorder) Header() http.Header {
+	return r.header
+}
+
+// WriteHeader does nothing,the status is read from the trailers.
+func (r *connectUnaryRecorder) WriteHeader(int) {}
+
+// Write records the response body.
+func (r *connectUnaryRecorder) Write(p []byte) (int, error) {
+	r.body = append(r.body, p...)
+	return len(p), nil
+}
+
+// Flush does nothing,the response is written when the gRPC call finishes.
+func (r *connectUnaryRecorder) Flush() {}
+
+// metadata returns the response headers and the trailers of the gRPC response.
+func (r *connectUnaryRecorder) metadata() (http.Header, map[string][]string) {
+	declared := declaredTrailers(r.header)
+	trailers := grpcTrailers(r.header, declared)
+	return grpcMetadataHeaders(r.header, declared), trailers
+}
+
+// connectStreamWriter writes the gRPC frames as the Connect envelopes.
+type connectStreamWriter struct {
+	http.ResponseWriter
+	header       http.Header
+	codec        *connectCodec
+	contentType  string
+	wroteHeader  bool
+	trailerNames []string
+	buf          []byte
+	err          error
+}
+
+// Header returns the gRPC response headers,the trailers are kept until the response finishes.
+func (w *connectStreamWriter) Header() http.Header {
+	return w.header
+}
+
+// WriteHeader writes the response metadata,the status code is always 200.
+func (w *connectStreamWriter) WriteHeader(int) {
+	if w.wroteHeader {
+		return
+	}
+
+	w.wroteHeader = true
+	w.trailerNames = declaredTrailers(w.header)
+	for key, values := range grpcMetadataHeaders(w.header, w.trailerNames) {
+		w.ResponseWriter.Header()[key] = values
+	}
+
+	w.ResponseWriter.Header().Set("Content-Type", w.contentType)
+	w.ResponseWriter.WriteHeader(http.StatusOK)
+}
+
+// Write writes an envelope for each complete gRPC frame.
+func (w *connectStreamWriter) Write(p []byte) (int, error) {
+	if !w.wroteHeader {
+		w.WriteHeader(http.StatusOK)
+	}
+
+	w.buf = append(w.buf, p...)
+	for len(w.buf) >= 5 {
+		n := 5 + int(binary.BigEndian.Uint32(w.buf[1:5]))
+		if len(w.buf) < n {
+			break
+		}
+
+		msg, err := w.codec.fromProto(w.buf[5:n])
+		w.buf = append(w.buf[:0], w.buf[n:]...)
+		if err != nil {
+			w.err = err
+			return 0, err
+		}
+
+		if _, err = w.ResponseWriter.Write(connectFrame(0, msg)); err != nil {
+			return 0, err
+		}
+	}
+
+	return len(p), nil
+}
+
+// Flush flushes the envelopes to the client.
+func (w *connectStreamWriter) Flush() {
+	if !w.wroteHeader {
+		w.WriteHeader(http.StatusOK)
+	}
+
+	_ = http.NewResponseController(w.ResponseWriter).Flush()
+}
+
+// finish writes the end-stream envelope,eg: {"error":{"code":"aborted"},"metadata":{"x-trace-id":["1"]}}
+func (w *connectStreamWriter) finish() {
+	if !w.wroteHeader {
+		w.WriteHeader(http.StatusOK)
+	}
+
+	trailers := grpcTrailers(w.header, w.trailerNames)
+	st, message, details := grpcStatusOf(trailers)
+	if w.err != nil {
+		st, message, details = connectErrorCode(w.err, codes.Internal), w.err.Error(), nil
+	}
+
+	end := struct {
+		Error    *connectError       `json:"error,omitempty"`
+		Metadata map[string][]string `json:"metadata,omitempty"`
+	}{Metadata: trailers}
+	if st != codes.OK {
+		end.Error = newConnectError(st, message, details)
+	}
+
+	b, _ := json.Marshal(end)
+	_, _ = w.ResponseWriter.Write(connectFrame(connectFlagEndStream, b))
+	_ = http.NewResponseController(w.ResponseWriter).Flush()
+}
+
+// grpcMetadataHeaders returns the custom metadata of the gRPC response headers.
+func grpcMetadataHeaders(header http.Header, trailerNames []string) http.Header {
+	md := make(http.Header)
+	for key, values := range header {
+		switch {
+		case key == "Trailer" || key == "Content-Type" || key == "Date" || len(values) == 0:
+		case strings.HasPrefix(key, http.TrailerPrefix) || strings.HasPrefix(key, "Grpc-"):
+		default:
+			md[key] = values
+		}
+	}
+
+	for _, name := range trailerNames {
+		md.Del(name)
+	}
+
+	return md
+}
+
+// grpcStatusOf returns the status code,message and details of the gRPC trailers,
+// the grpc-status,grpc-message and grpc-status-details-bin trailers are removed.
+func grpcStatusOf(trailers map[string][]string) (codes.Code, string, []connectErrorDetail) {
+	get := func(key string) string {
+		values := trailers[key]
+		delete(trailers, key)
+		if len(values) == 0 {
+			return ""
+		}
+
+		return values[0]
+	}
+
+	st, message, detailsBin := get("grpc-status"), get("grpc-message"), get("grpc-status-details-bin")
+	c, err := strconv.Atoi(st)
+	if err != nil {
+		return codes.Unknown, "missing grpc-status", nil
+	}
+
+	if m, err := url.PathUnescape(message); err == nil {
+		message = m
+	}
+
+	var details []connectErrorDetail
+	if b, err := base64.RawStdEncoding.DecodeString(strings.TrimRight(detailsBin, "=")); err == nil && len(b) > 0 {
+		s := &spb.Status{}
+		if proto.Unmarshal(b, s) == nil {
+			for _, d := range s.Details {
+				details = append(details, connectErrorDetail{
+					Type:  strings.TrimPrefix(d.TypeUrl, "type.googleapis.com/"),
+					Value: base64.RawStdEncoding.EncodeToString(d.Value),
+				})
+			}
+		}
+	}
+
+	return codes.Code(c), message, details
+}
+
+// newConnectError returns the Connect error of the gRPC status,eg: codes.NotFound => not_found
+func newConnectError(c codes.Code, message string, details []connectErrorDetail) *connectError {
+	name := strings.ToLower(code.Code(c).String())
+	if c == codes.Canceled {
+		name = "canceled"
+	}
+
+	return &connectError{Code: name, Message: message, Details: details}
+}
+
+// writeConnectError writes the unary error as json with the http status of the code.
+func writeConnectError(w http.ResponseWriter, header http.Header, c codes.Code, message string,
+	details []connectErrorDetail) {
+	for key, values := range header {
+		w.Header()[key] = values
+	}
+
+	status, ok := connectHTTPStatus[c]
+	if !ok {
+		status = http.StatusInternalServerError
+	}
+
+	b, _ := json.Marshal(newConnectError(c, message, details))
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_, _ = w.Write(b)
+}
+
+// grpcFrame returns the gRPC length-prefixed message.
+func grpcFrame(msg []byte) []byte {
+	return connectFrame(0, msg)
+}
+
+// connectFrame returns the envelope of the flags and the message,
+// it has the same layout as the gRPC length-prefixed message.
+func connectFrame(flags byte, msg []byte) []byte {
+	frame := make([]byte, 5, 5+len(msg))
+	frame[0] = flags
+	binary.BigEndian.PutUint32(frame[1:], uint32(len(msg)))
+	return append(frame, msg...)
+}
+
+// connectMediaType returns the media type without the parameters,eg: application/json; charset=utf-8
+func connectMediaType(contentType string) string {
+	mediaType, _, _ := strings.Cut(contentType, ";")
+	return strings.ToLower(strings.TrimSpace(mediaType))
+}
+
+// gunzip decompresses b,the decompressed message is limited to limit bytes.
+func gunzip(b []byte, limit int) ([]byte, error) {
+	zr, err := gzip.NewReader(bytes.NewReader(b))
+	if err != nil {
+		return nil, fmt.Errorf("gzip decompress error: %w", err)
+	}
+
+	defer zr.Close()
+
+	data, err := io.ReadAll(io.LimitReader(zr, int64(limit)+1))
+	if err != nil {
+		return nil, fmt.Errorf("gzip decompress error: %w", err)
+	}
+
+	if len(data) > limit {
+		return nil, errConnectMessageTooLarge
+	}
+
+	return data, nil
+}
+
+// connectErrorCode returns codes.ResourceExhausted for the too large messages,
+// codes.Unimplemented for the unsupported compressions,otherwise c.
+func connectErrorCode(err error, c codes.Code) codes.Code {
+	switch {
+	case errors.Is(err, errConnectMessageTooLarge):
+		return codes.ResourceExhausted
+	case errors.Is(err, errConnectUnsupportedCompression):
+		return codes.Unimplemented
+	default:
+		return c
+	}
+}
@@ -0,0 +1,405 @@
+package micro
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protodesc"
+	"google.golang.org/protobuf/reflect/protoregistry"
+	"google.golang.org/protobuf/types/descriptorpb"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+
+	"github.com/daheige/hephfx/example/pb"
+)
+
+// echoServiceDesc the bidi streaming service which echoes the messages,
+// it is not registered in the global registry,so only the proto codec is supported.
+var echoServiceDesc = grpc.ServiceDesc{
+	ServiceName: "micro.test.Echo",
+	HandlerType: (*interface{})(nil),
+	Streams: []grpc.StreamDesc{{
+		StreamName:    "Echo",
+		ServerStreams: true,
+		ClientStreams: true,
+		Handler: func(_ interface{}, stream grpc.ServerStream) error {
+			for {
+				msg := &wrapperspb.StringValue{}
+				if err := stream.RecvMsg(msg); err != nil {
+					if err == io.EOF {
+						stream.SetTrailer(metadata.Pairs("x-echo", "done"))
+						return status.Error(codes.Aborted, "echo finished")
+					}
+
+					return err
+				}
+
+				if err := stream.SendMsg(msg); err != nil {
+					return err
+				}
+			}
+		},
+	}},
+}
+
+// lookupServiceDesc the unary service without side effects,it is registered in the global registry
+// by registerLookupService,so the GET requests are allowed.
+var lookupServiceDesc = grpc.ServiceDesc{
+	ServiceName: "micro.test.Lookup",
+	HandlerType: (*interface{})(nil),
+	Methods: []grpc.MethodDesc{{
+		MethodName: "Get",
+		Handler: func(_ interface{}, _ context.Context, dec func(interface{}) error,
+			_ grpc.UnaryServerInterceptor) (interface{}, error) {
+			in := &wrapperspb.StringValue{}
+			if err := dec(in); err != nil {
+				return nil, err
+			}
+
+			return wrapperspb.String("got," + in.Value), nil
+		},
+	}},
+}
+
+var registerLookupOnce sync.Once
+
+// registerLookupService registers the descriptor of lookupServiceDesc with idempotency_level = NO_SIDE_EFFECTS.
+func registerLookupService(t *testing.T) {
+	t.Helper()
+
+	registerLookupOnce.Do(func() {
+		fd, err := protodesc.NewFile(&descriptorpb.FileDescriptorProto{
+			Name:       proto.String("micro/test/lookup.proto"),
+			Package:    proto.String("micro.test"),
+			Dependency: []string{"google/protobuf/wrappers.proto"},
+			Syntax:     proto.String("proto3"),
+			Service: []*descriptorpb.ServiceDescriptorProto{{
+				Name: proto.String("Lookup"),
+				Method: []*descriptorpb.MethodDescriptorProto{{
+					Name:       proto.String("Get"),
+					InputType:  proto.String(".google.protobuf.StringValue"),
+					OutputType: proto.String(".google.protobuf.StringValue"),
+					Options: &descriptorpb.MethodOptions{
+						IdempotencyLevel: descriptorpb.MethodOptions_NO_SIDE_EFFECTS.Enum(),
+					},
+				}},
+			}},
+		}, protoregistry.GlobalFiles)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if err = protoregistry.GlobalFiles.RegisterFile(fd); err != nil {
+			t.Fatal(err)
+		}
+	})
+}
+
+func newConnectServer(t *testing.T, opts ...ConnectOption) *httptest.Server {
+	registerLookupService(t)
+	grpcServer := grpc.NewServer()
+	pb.RegisterGreeterServer(grpcServer, &testGreeter{})
+	healthServer := health.NewServer()
+	healthpb.RegisterHealthServer(grpcServer, healthServer)
+	grpcServer.RegisterService(&echoServiceDesc, struct{}{})
+	grpcServer.RegisterService(&lookupServiceDesc, struct{}{})
+
+	other := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("gateway"))
+	})
+
+	srv := httptest.NewServer(h2cHandler(grpcHandler(grpcServer, other, newConnectHandler(grpcServer, opts...))))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func TestConnectUnary(t *testing.T) {
+	srv := newConnectServer(t)
+	req, _ := proto.Marshal(&pb.HelloReq{Name: "proto"})
+	cases := []struct {
+		method      string
+		path        string
+		contentType string
+		body        []byte
+		status      int
+		want        string
+	}{
+		{
+			http.MethodPost, "/Hello.Greeter/SayHello", "application/json",
+			[]byte(`{"name":"heige","unknown":1}`), http.StatusOK, `{"message":"hello,heige"}`,
+		},
+		{
+			http.MethodGet, "/micro.test.Lookup/Get?connect=v1&encoding=json&message=" +
+				url.QueryEscape(`"get"`), "", nil, http.StatusOK, `"got,get"`,
+		},
+		// the GET requests of the methods with side effects are rejected
+		{
+			http.MethodGet, "/Hello.Greeter/SayHello?connect=v1&encoding=json&message=" +
+				url.QueryEscape(`{"name":"get"}`), "", nil, http.StatusNotImplemented, "",
+		},
+		{
+			http.MethodPost, "/grpc.health.v1.Health/Check", "application/json; charset=utf-8",
+			[]byte(`{"service":"unknown"}`), http.StatusNotFound, `{"code":"not_found","message":"unknown service"}`,
+		},
+		{
+			http.MethodPost, "/Hello.Greeter/SayHello", "application/json",
+			[]byte(`{"name":1}`), http.StatusBadRequest, "",
+		},
+		// the json requests of the other paths are served by the gateway
+		{http.MethodPost, "/v1/say", "application/json", []byte(`{"name":"heige"}`), http.StatusOK, "gateway"},
+	}
+	for _, c := range cases {
+		r, _ := http.NewRequest(c.method, srv.URL+c.path, bytes.NewReader(c.body))
+		if c.contentType != "" {
+			r.Header.Set("Content-Type", c.contentType)
+		}
+
+		r.Header.Set("Connect-Protocol-Version", "1")
+		resp, err := http.DefaultClient.Do(r)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		b, _ := io.ReadAll(resp.Body)
+		_ = resp.Body.Close()
+		if resp.StatusCode != c.status || (c.want != "" && string(b) != c.want) {
+			t.Fatalf("%s %s = %d %s", c.method, c.path, resp.StatusCode, b)
+		}
+	}
+
+	resp, err := http.Post(srv.URL+"/Hello.Greeter/SayHello", "application/proto", bytes.NewReader(req))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	b, _ := io.ReadAll(resp.Body)
+	_ = resp.Body.Close()
+	reply := &pb.HelloReply{}
+	if err = proto.Unmarshal(b, reply); err != nil || reply.Message != "hello,proto" ||
+		resp.Header.Get("Content-Type") != "application/proto" {
+		t.Fatalf("proto reply = %v,content type: %s,err: %v", reply, resp.Header.Get("Content-Type"), err)
+	}
+}
+
+// readConnectFrames reads the envelopes of the Connect streaming response.
+func readConnectFrames(t *testing.T, body []byte) ([][]byte, []byte) {
+	t.Helper()
+
+	var (
+		messages [][]byte
+		end      []byte
+	)
+	for len(body) >= 5 {
+		flags, n := body[0], binary.BigEndian.Uint32(body[1:5])
+		data := body[5 : 5+n]
+		body = body[5+n:]
+		if flags&connectFlagEndStream != 0 {
+			end = data
+			continue
+		}
+
+		messages = append(messages, data)
+	}
+
+	return messages, end
+}
+
+func TestConnectStream(t *testing.T) {
+	srv := newConnectServer(t)
+
+	var body []byte
+	for _, s := range []string{"a", "b"} {
+		b, _ := proto.Marshal(wrapperspb.String(s))
+		body = append(body, connectFrame(0, b)...)
+	}
+
+	resp, err := http.Post(srv.URL+"/micro.test.Echo/Echo", "application/connect+proto", bytes.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	b, _ := io.ReadAll(resp.Body)
+	_ = resp.Body.Close()
+	if resp.StatusCode != http.StatusOK || resp.Header.Get("Content-Type") != "application/connect+proto" {
+		t.Fatalf("stream = %d %s", resp.StatusCode, resp.Header.Get("Content-Type"))
+	}
+
+	messages, end := readConnectFrames(t, b)
+	if len(messages) != 2 {
+		t.Fatalf("messages = %d", len(messages))
+	}
+
+	for i, s := range []string{"a", "b"} {
+		msg := &wrapperspb.StringValue{}
+		if err = proto.Unmarshal(messages[i], msg); err != nil || msg.Value != s {
+			t.Fatalf("message %d = %v,err: %v", i, msg, err)
+		}
+	}
+
+	var endStream struct {
+		Error    connectError        `json:"error"`
+		Metadata map[string][]string `json:"metadata"`
+	}
+	if err = json.Unmarshal(end, &endStream); err != nil {
+		t.Fatal(err)
+	}
+
+	if endStream.Error.Code != "aborted" || endStream.Error.Message != "echo finished" ||
+		strings.Join(endStream.Metadata["x-echo"], ",") != "done" {
+		t.Fatalf("end stream = %s", end)
+	}
+
+	// server streaming with the json codec
+	b, _ = json.Marshal(map[string]string{"service": ""})
+	r, _ := http.NewRequest(http.MethodPost, srv.URL+"/grpc.health.v1.Health/Watch",
+		bytes.NewReader(connectFrame(0, b)))
+	r.Header.Set("Content-Type", "application/connect+json")
+	resp, err = http.DefaultClient.Do(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer resp.Body.Close()
+
+	prefix := make([]byte, 5)
+	if _, err = io.ReadFull(resp.Body, prefix); err != nil {
+		t.Fatal(err)
+	}
+
+	data := make([]byte, binary.BigEndian.Uint32(prefix[1:]))
+	if _, err = io.ReadFull(resp.Body, data); err != nil || string(data) != `{"status":"SERVING"}` {
+		t.Fatalf("watch message = %s,err: %v", data, err)
+	}
+}
+
+func TestConnectLimits(t *testing.T) {
+	srv := newConnectServer(t, WithConnectMaxRecvMsgSize(64))
+	large := `{"name":"` + strings.Repeat("a", 100) + `"}`
+
+	var compressed bytes.Buffer
+	zw := gzip.NewWriter(&compressed)
+	_, _ = zw.Write([]byte(large))
+	_ = zw.Close()
+
+	for _, c := range []struct {
+		name    string
+		body    []byte
+		header  map[string]string
+		status  int
+		errCode string
+	}{
+		{"large body", []byte(large), nil, http.StatusTooManyRequests, "resource_exhausted"},
+		{
+			"large decompressed body", compressed.Bytes(), map[string]string{"Content-Encoding": "gzip"},
+			http.StatusTooManyRequests, "resource_exhausted",
+		},
+		{
+			"unsupported version", []byte(`{"name":"heige"}`), map[string]string{"Connect-Protocol-Version": "2"},
+			http.StatusBadRequest, "invalid_argument",
+		},
+		{
+			"unsupported compression", []byte(`{"name":"heige"}`), map[string]string{"Content-Encoding": "br"},
+			http.StatusNotImplemented, "unimplemented",
+		},
+		{
+			"identity compression", []byte(`{"name":"heige"}`), map[string]string{"Content-Encoding": "identity"},
+			http.StatusOK, "",
+		},
+	} {
+		r, _ := http.NewRequest(http.MethodPost, srv.URL+"/Hello.Greeter/SayHello", bytes.NewReader(c.body))
+		r.Header.Set("Content-Type", "application/json")
+		for key, value := range c.header {
+			r.Header.Set(key, value)
+		}
+
+		resp, err := http.DefaultClient.Do(r)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		var e connectError
+		_ = json.NewDecoder(resp.Body).Decode(&e)
+		_ = resp.Body.Close()
+		if resp.StatusCode != c.status || e.Code != c.errCode {
+			t.Fatalf("%s = %d %s, want %d %s", c.name, resp.StatusCode, e.Code, c.status, c.errCode)
+		}
+	}
+
+	// the streaming message is limited too
+	b, _ := proto.Marshal(wrapperspb.String(strings.Repeat("a", 100)))
+	resp, err := http.Post(srv.URL+"/micro.test.Echo/Echo", "application/connect+proto",
+		bytes.NewReader(connectFrame(0, b)))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	body, _ := io.ReadAll(resp.Body)
+	_ = resp.Body.Close()
+	_, end := readConnectFrames(t, body)
+	var endStream struct {
+		Error connectError `json:"error"`
+	}
+	if err = json.Unmarshal(end, &endStream); err != nil || endStream.Error.Code != "resource_exhausted" {
+		t.Fatalf("end stream = %s,err: %v", end, err)
+	}
+
+	// the streaming compression other than gzip and identity is rejected
+	r, _ := http.NewRequest(http.MethodPost, srv.URL+"/micro.test.Echo/Echo", bytes.NewReader(connectFrame(0, nil)))
+	r.Header.Set("Content-Type", "application/connect+proto")
+	r.Header.Set("Connect-Content-Encoding", "br")
+	resp, err = http.DefaultClient.Do(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var e connectError
+	_ = json.NewDecoder(resp.Body).Decode(&e)
+	_ = resp.Body.Close()
+	if resp.StatusCode != http.StatusNotImplemented || e.Code != "unimplemented" {
+		t.Fatalf("unsupported stream compression = %d %s", resp.StatusCode, e.Code)
+	}
+}
+
+func TestConnectDisabled(t *testing.T) {
+	grpcServer := grpc.NewServer()
+	pb.RegisterGreeterServer(grpcServer, &testGreeter{})
+	other := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("gateway"))
+	})
+
+	// the Connect requests are served by the other handler without WithConnect
+	srv := httptest.NewServer(GRPCHandlerFunc(grpcServer, other))
+	defer srv.Close()
+
+	resp, err := http.Post(srv.URL+"/Hello.Greeter/SayHello", "application/json", strings.NewReader(`{"name":"heige"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	b, _ := io.ReadAll(resp.Body)
+	_ = resp.Body.Close()
+	if string(b) != "gateway" {
+		t.Fatalf("Connect request body = %s, want gateway", b)
+	}
+
+	protocols := NewService("", WithConnect(WithConnectMaxRecvMsgSize(1024))).protocolHandlers()
+	if len(protocols) != 1 || protocols[0].(*connectHandler).maxRecvMsgSize != 1024 {
+		t.Fatalf("protocol handlers = %v with WithConnect", protocols)
+	}
+}
@@ -0,0 +1,193 @@
+package micro
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// CORSOption cors middleware option
+type CORSOption func(c *cors)
+
+// cors handles the cross-origin requests of the http gateway.
+type cors struct {
+	allowOrigins     []string
+	allowOriginFunc  func(origin string) bool
+	allowMethods     []string
+	allowHeaders     []string
+	exposeHeaders    []string
+	allowCredentials bool
+	maxAge           time.Duration
+}
+
+// WithCORSAllowOrigins returns a CORSOption to set the allowed origins,default: *
+// The wildcard subdomain is supported,eg: https://*.example.com
+func WithCORSAllowOrigins(origins ...string) CORSOption {
+	return func(c *cors) {
+		c.allowOrigins = origins
+	}
+}
+
+// WithCORSAllowOriginFunc returns a CORSOption to check the origin by fn,
+// it takes precedence over the allowed origins.
+func WithCORSAllowOriginFunc(fn func(origin string) bool) CORSOption {
+	return func(c *cors) {
+		c.allowOriginFunc = fn
+	}
+}
+
+// WithCORSAllowMethods returns a CORSOption to set the allowed methods,
+// default: GET,POST,PUT,PATCH,DELETE,HEAD
+func WithCORSAllowMethods(methods ...string) CORSOption {
+	return func(c *cors) {
+		c.allowMethods = methods
+	}
+}
+
+// WithCORSAllowHeaders returns a CORSOption to set the allowed request headers,
+// the requested headers of the preflight request are allowed by default.
+func WithCORSAllowHeaders(headers ...string) CORSOption {
+	return func(c *cors) {
+		c.allowHeaders = headers
+	}
+}
+
+// WithCORSExposeHeaders returns a CORSOption to set the response headers exposed to the browser,
+// eg: X-Request-Id
+func WithCORSExposeHeaders(headers ...string) CORSOption {
+	return func(c *cors) {
+		c.exposeHeaders = headers
+	}
+}
+
+// WithCORSAllowCredentials returns a CORSOption to allow the cookies and the authorization headers,
+// the request origin is returned instead of * when it is enabled.
+// The credentials are only allowed for the origins set by WithCORSAllowOrigins other than *,
+// or allowed by WithCORSAllowOriginFunc.
+func WithCORSAllowCredentials() CORSOption {
+	return func(c *cors) {
+		c.allowCredentials = true
+	}
+}
+
+// WithCORSMaxAge returns a CORSOption to set how long the preflight results can be cached,eg: 10 * time.Minute
+func WithCORSMaxAge(maxAge time.Duration) CORSOption {
+	return func(c *cors) {
+		c.maxAge = maxAge
+	}
+}
+
+// CORSMiddleware returns the http middleware which handles the cors preflight requests
+// and sets the cors headers of the cross-origin requests.
+// The preflight requests with the disallowed origins are rejected with 403.
+func CORSMiddleware(opts ...CORSOption) HTTPMiddleware {
+	c := &cors{
+		allowOrigins: []string{"*"},
+		allowMethods: []string{
+			http.MethodGet, http.MethodPost, http.MethodPut, http.MethodPatch, http.MethodDelete, http.MethodHead,
+		},
+	}
+	for _, o := range opts {
+		o(c)
+	}
+
+	return func(h http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			origin := r.Header.Get("Origin")
+			preflight := r.Method == http.MethodOptions && r.Header.Get("Access-Control-Request-Method") != ""
+			w.Header().Add("Vary", "Origin")
+			if preflight {
+				w.Header().Add("Vary", "Access-Control-Request-Method")
+				w.Header().Add("Vary", "Access-Control-Request-Headers")
+			}
+
+			if origin == "" {
+				h.ServeHTTP(w, r)
+				return
+			}
+
+			allowed, explicit := c.allowed(origin)
+			if !allowed {
+				if preflight {
+					w.WriteHeader(http.StatusForbidden)
+					return
+				}
+
+				h.ServeHTTP(w, r)
+				return
+			}
+
+			c.setOrigin(w, origin, explicit)
+			if preflight {
+				c.preflight(w, r)
+				return
+			}
+
+			if len(c.exposeHeaders) > 0 {
+				w.Header().Set("Access-Control-Expose-Headers", strings.Join(c.exposeHeaders, ", "))
+			}
+
+			h.ServeHTTP(w, r)
+		})
+	}
+}
+
+// allowed reports whether the origin is allowed,
+// explicit reports whether it is allowed by the origin func or an allowed origin other than *.
+func (c *cors) allowed(origin string) (allowed bool, explicit bool) {
+	if c.allowOriginFunc != nil {
+		allowed = c.allowOriginFunc(origin)
+		return allowed, allowed
+	}
+
+	for _, o := range c.allowOrigins {
+		if o == "*" {
+			allowed = true
+			continue
+		}
+
+		if strings.EqualFold(o, origin) {
+			return true, true
+		}
+
+		// wildcard subdomain,eg: https://*.example.com
+		if prefix, suffix, ok := strings.Cut(o, "*"); ok && len(origin) > len(prefix)+len(suffix) &&
+			strings.HasPrefix(origin, prefix) && strings.HasSuffix(origin, suffix) {
+			return true, true
+		}
+	}
+
+	return allowed, false
+}
+
+// setOrigin sets the allowed origin and credentials headers.
+// The origins allowed by * get * without the credentials,
+// otherwise any site could send the credentialed requests.
+func (c *cors) setOrigin(w http.ResponseWriter, origin string, explicit bool) {
+	if !explicit {
+		w.Header().Set("Access-Control-Allow-Origin", "*")
+		return
+	}
+
+	w.Header().Set("Access-Control-Allow-Origin", origin)
+	if c.allowCredentials {
+		w.Header().Set("Access-Control-Allow-Credentials", "true")
+	}
+}
+
+// preflight responds the preflight request with 204.
+func (c *cors) preflight(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Access-Control-Allow-Methods", strings.Join(c.allowMethods, ", "))
+	if len(c.allowHeaders) > 0 {
+		w.Header().Set("Access-Control-Allow-Headers", strings.Join(c.allowHeaders, ", "))
+	} else if headers := r.Header.Get("Access-Control-Request-Headers"); headers != "" {
+		w.Header().Set("Access-Control-Allow-Headers", headers)
+	}
+
+	if c.maxAge > 0 {
+		w.Header().Set("Access-Control-Max-Age", strconv.Itoa(int(c.maxAge.Seconds())))
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
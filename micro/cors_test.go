@@ -0,0 +1,120 @@
+package micro
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	gRuntime "github.com/grpc-ecosystem/grpc-gateway/v2/runtime"
+)
+
+func TestCORSMiddleware(t *testing.T) {
+	h := CORSMiddleware(
+		WithCORSAllowOrigins("https://app.example.com", "https://*.example.org"),
+		WithCORSExposeHeaders("X-Request-Id"),
+		WithCORSAllowCredentials(),
+		WithCORSMaxAge(10*time.Minute),
+	)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("ok"))
+	}))
+
+	cases := []struct {
+		name   string
+		method string
+		origin string
+		code   int
+		allow  string
+		maxAge string
+	}{
+		{"simple", http.MethodGet, "https://app.example.com", http.StatusOK, "https://app.example.com", ""},
+		{"wildcard", http.MethodGet, "https://a.example.org", http.StatusOK, "https://a.example.org", ""},
+		{"not allowed", http.MethodGet, "https://evil.com", http.StatusOK, "", ""},
+		{"preflight", http.MethodOptions, "https://app.example.com", http.StatusNoContent, "https://app.example.com", "600"},
+		{"preflight not allowed", http.MethodOptions, "https://example.org", http.StatusForbidden, "", ""},
+	}
+	for _, c := range cases {
+		r := httptest.NewRequest(c.method, "/v1/say/heige", nil)
+		r.Header.Set("Origin", c.origin)
+		if c.method == http.MethodOptions {
+			r.Header.Set("Access-Control-Request-Method", http.MethodPost)
+			r.Header.Set("Access-Control-Request-Headers", "content-type,authorization")
+		}
+
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, r)
+		if rec.Code != c.code {
+			t.Fatalf("%s: code = %d", c.name, rec.Code)
+		}
+		if got := rec.Header().Get("Access-Control-Allow-Origin"); got != c.allow {
+			t.Fatalf("%s: allow origin = %s", c.name, got)
+		}
+		if got := rec.Header().Get("Access-Control-Max-Age"); got != c.maxAge {
+			t.Fatalf("%s: max age = %s", c.name, got)
+		}
+	}
+
+	r := httptest.NewRequest(http.MethodOptions, "/v1/say/heige", nil)
+	r.Header.Set("Origin", "https://app.example.com")
+	r.Header.Set("Access-Control-Request-Method", http.MethodPost)
+	r.Header.Set("Access-Control-Request-Headers", "content-type")
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, r)
+	if rec.Header().Get("Access-Control-Allow-Headers") != "content-type" ||
+		rec.Header().Get("Access-Control-Allow-Credentials") != "true" {
+		t.Fatalf("preflight headers = %v", rec.Header())
+	}
+}
+
+func TestCORSCredentialsWithAnyOrigin(t *testing.T) {
+	h := CORSMiddleware(WithCORSAllowCredentials())(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("ok"))
+	}))
+
+	// the credentials are not allowed for the origins allowed by the default *
+	r := httptest.NewRequest(http.MethodGet, "/v1/say/heige", nil)
+	r.Header.Set("Origin", "https://evil.com")
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, r)
+	if rec.Header().Get("Access-Control-Allow-Origin") != "*" ||
+		rec.Header().Get("Access-Control-Allow-Credentials") != "" {
+		t.Fatalf("cors headers = %v", rec.Header())
+	}
+
+	// the origin func allows the credentials explicitly
+	h = CORSMiddleware(
+		WithCORSAllowCredentials(),
+		WithCORSAllowOriginFunc(func(origin string) bool { return origin == "https://app.example.com" }),
+	)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	r = httptest.NewRequest(http.MethodGet, "/v1/say/heige", nil)
+	r.Header.Set("Origin", "https://app.example.com")
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, r)
+	if rec.Header().Get("Access-Control-Allow-Origin") != "https://app.example.com" ||
+		rec.Header().Get("Access-Control-Allow-Credentials") != "true" {
+		t.Fatalf("cors headers = %v", rec.Header())
+	}
+}
+
+func TestWithCORS(t *testing.T) {
+	// the gateway middlewares wrap the custom http handler
+	s := NewService("", WithEnableInProcessGateway(), WithCORS(), WithSecurityHeaders(),
+		WithGRPCHTTPHandler(func(mux *gRuntime.ServeMux) http.Handler {
+			return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				_, _ = w.Write([]byte("custom"))
+			})
+		}))
+	h, err := s.HTTPHandler()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	r.Header.Set("Origin", "https://app.example.com")
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, r)
+	if rec.Body.String() != "custom" || rec.Header().Get("Access-Control-Allow-Origin") != "*" ||
+		rec.Header().Get("X-Content-Type-Options") != "nosniff" {
+		t.Fatalf("response = %v %s", rec.Header(), rec.Body.String())
+	}
+}
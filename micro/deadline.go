@@ -0,0 +1,191 @@
+package micro
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/daheige/hephfx/monitor"
+)
+
+const (
+	// grpcTimeoutHeader the http header of the gRPC timeout,it is parsed by the grpc-gateway.
+	grpcTimeoutHeader = "Grpc-Timeout"
+
+	// xTimeoutHeader the http header of the timeout,eg: 1.5s,500ms or 3 (seconds)
+	xTimeoutHeader = "X-Timeout"
+)
+
+// DeadlineOption deadline interceptor option
+type DeadlineOption func(d *deadline)
+
+// deadline applies the default deadline and caps the incoming deadline.
+type deadline struct {
+	defaultTimeout time.Duration            // the timeout of the requests without a deadline,0 means no timeout
+	methodTimeouts map[string]time.Duration // the timeouts of the full methods,override the default timeout
+	maxTimeout     time.Duration            // the max timeout of all the requests,0 means no limit
+}
+
+// WithDefaultTimeout returns a DeadlineOption to set the timeout of the requests without a deadline.
+func WithDefaultTimeout(timeout time.Duration) DeadlineOption {
+	return func(d *deadline) {
+		d.defaultTimeout = timeout
+	}
+}
+
+// WithMethodTimeout returns a DeadlineOption to set the timeout of the full method,
+// it overrides the default timeout,eg: /Hello.Greeter/SayHello
+func WithMethodTimeout(method string, timeout time.Duration) DeadlineOption {
+	return func(d *deadline) {
+		d.methodTimeouts[method] = timeout
+	}
+}
+
+// WithMaxTimeout returns a DeadlineOption to cap the incoming deadlines at the max timeout.
+func WithMaxTimeout(timeout time.Duration) DeadlineOption {
+	return func(d *deadline) {
+		d.maxTimeout = timeout
+	}
+}
+
+func newDeadline(opts ...DeadlineOption) *deadline {
+	d := &deadline{
+		methodTimeouts: make(map[string]time.Duration),
+	}
+
+	for _, o := range opts {
+		o(d)
+	}
+
+	return d
+}
+
+// DeadlineUnaryInterceptor returns a server unary interceptor which applies the default
+// or method deadline to the requests without a deadline,and caps the incoming deadlines.
+// The requests which exceed the deadline are counted by monitor.GRPCDeadlineExceededTotal.
+func DeadlineUnaryInterceptor(opts ...DeadlineOption) grpc.UnaryServerInterceptor {
+	d := newDeadline(opts...)
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo,
+		handler grpc.UnaryHandler) (interface{}, error) {
+		ctx, cancel := d.withDeadline(ctx, info.FullMethod)
+		defer cancel()
+
+		reply, err := handler(ctx, req)
+		if err = d.check(ctx, info.FullMethod, err); err != nil {
+			return nil, err
+		}
+
+		return reply, nil
+	}
+}
+
+// DeadlineStreamInterceptor returns a server stream interceptor which applies the default
+// or method deadline to the streams without a deadline,and caps the incoming deadlines.
+func DeadlineStreamInterceptor(opts ...DeadlineOption) grpc.StreamServerInterceptor {
+	d := newDeadline(opts...)
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		ctx, cancel := d.withDeadline(ss.Context(), info.FullMethod)
+		defer cancel()
+
+		err := handler(srv, &contextServerStream{ServerStream: ss, ctx: ctx})
+		return d.check(ctx, info.FullMethod, err)
+	}
+}
+
+// withDeadline returns the context with the deadline of the method.
+func (d *deadline) withDeadline(ctx context.Context, method string) (context.Context, context.CancelFunc) {
+	timeout, ok := d.methodTimeouts[method]
+	if !ok {
+		timeout = d.defaultTimeout
+	}
+
+	if dl, ok := ctx.Deadline(); ok {
+		// the incoming deadline is kept unless it exceeds the max timeout
+		if d.maxTimeout > 0 && time.Until(dl) > d.maxTimeout {
+			return context.WithTimeout(ctx, d.maxTimeout)
+		}
+
+		return ctx, func() {}
+	}
+
+	if d.maxTimeout > 0 && (timeout <= 0 || timeout > d.maxTimeout) {
+		timeout = d.maxTimeout
+	}
+
+	if timeout <= 0 {
+		return ctx, func() {}
+	}
+
+	return context.WithTimeout(ctx, timeout)
+}
+
+// check returns codes.DeadlineExceeded when the handler returns after the deadline,
+// and counts the requests which exceed the deadline.
+func (d *deadline) check(ctx context.Context, method string, err error) error {
+	if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		if status.Code(err) != codes.DeadlineExceeded {
+			err = status.Errorf(codes.DeadlineExceeded, "method %s deadline exceeded", method)
+		}
+	}
+
+	if status.Code(err) == codes.DeadlineExceeded {
+		monitor.GRPCDeadlineExceededTotal.WithLabelValues(method).Inc()
+	}
+
+	return err
+}
+
+// gatewayTimeoutMiddleware converts the X-Timeout header into the Grpc-Timeout header,
+// the grpc-gateway applies it as the deadline of the gRPC request.
+func gatewayTimeoutMiddleware(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get(grpcTimeoutHeader) == "" {
+			if timeout, ok := parseTimeout(r.Header.Get(xTimeoutHeader)); ok {
+				r.Header.Set(grpcTimeoutHeader, encodeTimeout(timeout))
+			}
+		}
+
+		h.ServeHTTP(w, r)
+	})
+}
+
+// parseTimeout parses the timeout,eg: 1.5s,500ms or 3 (seconds)
+func parseTimeout(value string) (time.Duration, bool) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return 0, false
+	}
+
+	timeout, err := time.ParseDuration(value)
+	if err != nil {
+		seconds, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return 0, false
+		}
+
+		timeout = time.Duration(seconds * float64(time.Second))
+	}
+
+	// the grpc timeout is in milliseconds at least
+	if timeout < time.Millisecond {
+		return 0, false
+	}
+
+	return timeout, true
+}
+
+// encodeTimeout encodes the timeout as the gRPC timeout,the value has 8 digits at most.
+func encodeTimeout(timeout time.Duration) string {
+	if ms := timeout.Milliseconds(); ms < 1e8 {
+		return strconv.FormatInt(ms, 10) + "m"
+	}
+
+	return strconv.FormatInt(int64(timeout.Seconds()), 10) + "S"
+}
@@ -0,0 +1,121 @@
+package micro
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/daheige/hephfx/example/pb"
+	"github.com/daheige/hephfx/monitor"
+)
+
+func TestDeadline(t *testing.T) {
+	const method = "/Hello.Greeter/SayHello"
+	cases := []struct {
+		name    string
+		opts    []DeadlineOption
+		timeout time.Duration // the client timeout,0 means no deadline
+		code    codes.Code
+	}{
+		{name: "default timeout", opts: []DeadlineOption{WithDefaultTimeout(50 * time.Millisecond)},
+			code: codes.DeadlineExceeded},
+		{name: "method timeout", opts: []DeadlineOption{WithDefaultTimeout(50 * time.Millisecond),
+			WithMethodTimeout(method, time.Second)}, code: codes.OK},
+		{name: "incoming deadline", opts: []DeadlineOption{WithDefaultTimeout(50 * time.Millisecond)},
+			timeout: time.Second, code: codes.OK},
+		{name: "max timeout", opts: []DeadlineOption{WithMaxTimeout(50 * time.Millisecond)},
+			timeout: time.Second, code: codes.DeadlineExceeded},
+		{name: "no deadline", code: codes.OK},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			s := newTestService(t, &testGreeter{delay: 200 * time.Millisecond}, WithDeadline(c.opts...))
+			conn := dialService(t, s)
+
+			ctx := context.Background()
+			if c.timeout > 0 {
+				var cancel context.CancelFunc
+				ctx, cancel = context.WithTimeout(ctx, c.timeout)
+				defer cancel()
+			}
+
+			exceeded := testutil.ToFloat64(monitor.GRPCDeadlineExceededTotal.WithLabelValues(method))
+			_, err := pb.NewGreeterClient(conn).SayHello(ctx, &pb.HelloReq{Name: "heige"})
+			if status.Code(err) != c.code {
+				t.Fatalf("say hello error = %v, want %s", err, c.code)
+			}
+
+			if c.code == codes.DeadlineExceeded {
+				exceeded++
+			}
+			if n := testutil.ToFloat64(monitor.GRPCDeadlineExceededTotal.WithLabelValues(method)); n != exceeded {
+				t.Fatalf("deadline exceeded count = %v, want %v", n, exceeded)
+			}
+		})
+	}
+}
+
+func TestDeadlineGateway(t *testing.T) {
+	s := newTestService(t, &testGreeter{delay: 200 * time.Millisecond},
+		WithHandlerFromEndpoints(pb.RegisterGreeterHandlerFromEndpoint),
+		WithDeadline(),
+	)
+
+	handler, err := s.HTTPHandler()
+	if err != nil {
+		t.Fatalf("http handler error: %v", err)
+	}
+
+	for header, code := range map[string]int{
+		"":                http.StatusOK,
+		"X-Timeout":       http.StatusGatewayTimeout,
+		grpcTimeoutHeader: http.StatusGatewayTimeout,
+	} {
+		r := httptest.NewRequest(http.MethodGet, "/v1/say/heige", nil)
+		switch header {
+		case "X-Timeout":
+			r.Header.Set(header, "50ms")
+		case grpcTimeoutHeader:
+			r.Header.Set(header, "50m")
+		}
+
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+		if w.Code != code {
+			t.Fatalf("header %q response code = %d, want %d body:%s", header, w.Code, code, w.Body.String())
+		}
+	}
+}
+
+func TestParseTimeout(t *testing.T) {
+	cases := map[string]time.Duration{
+		"1.5s":  1500 * time.Millisecond,
+		"500ms": 500 * time.Millisecond,
+		"3":     3 * time.Second,
+		"0.25":  250 * time.Millisecond,
+		"":      0,
+		"abc":   0,
+		"1us":   0,
+	}
+
+	for value, want := range cases {
+		timeout, ok := parseTimeout(value)
+		if timeout != want || ok != (want > 0) {
+			t.Fatalf("parse timeout %q = %s %v, want %s", value, timeout, ok, want)
+		}
+	}
+
+	if got := encodeTimeout(1500 * time.Millisecond); got != "1500m" {
+		t.Fatalf("encode timeout = %s, want 1500m", got)
+	}
+	if got := encodeTimeout(30 * time.Hour); got != "108000S" {
+		t.Fatalf("encode timeout = %s, want 108000S", got)
+	}
+}
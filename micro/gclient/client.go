@@ -80,6 +80,8 @@ func CloseGRPCConnections() {
 // InitGRPCClient creates a gRPC connection and generates any pb.XXXClient through a factory,
 // T represents the client interface type, such as pb.GreeterClient, pb.OrderClient, etc.
 // target represents the endpoint connection address, which can be either a host:port or a k8s named service address.
+// The client calls are traced by OpenTelemetry when the tracing dial options are passed,
+// eg: gclient.InitGRPCClient(target, pb.NewGreeterClient, tracing.DialOptions(tracing.WithTracerProvider(tp))...)
 func InitGRPCClient[T any](target string, factory func(grpc.ClientConnInterface) T,
 	opts ...grpc.DialOption) (T, error) {
 	var zero T
@@ -0,0 +1,72 @@
+package gclient
+
+import (
+	"context"
+	"net"
+	"testing"
+
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/test/bufconn"
+
+	"github.com/daheige/hephfx/example/pb"
+	"github.com/daheige/hephfx/micro/tracing"
+)
+
+type greeter struct {
+	pb.UnimplementedGreeterServer
+}
+
+func (s *greeter) SayHello(_ context.Context, req *pb.HelloReq) (*pb.HelloReply, error) {
+	return &pb.HelloReply{Message: "hello," + req.Name}, nil
+}
+
+func TestInitGRPCClientTracing(t *testing.T) {
+	exporter := tracetest.NewInMemoryExporter()
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter))
+
+	lis := bufconn.Listen(1 << 20)
+	server := grpc.NewServer(grpc.ChainUnaryInterceptor(tracing.UnaryServerInterceptor(tracing.WithTracerProvider(tp))))
+	pb.RegisterGreeterServer(server, &greeter{})
+	go func() {
+		_ = server.Serve(lis)
+	}()
+	defer server.Stop()
+
+	target := "passthrough:///gclient-tracing"
+	opts := append(tracing.DialOptions(tracing.WithTracerProvider(tp)),
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+			return lis.DialContext(ctx)
+		}))
+	client, err := InitGRPCClient(target, pb.NewGreeterClient, opts...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer Close(target)
+
+	if _, err = client.SayHello(context.Background(), &pb.HelloReq{Name: "heige"}); err != nil {
+		t.Fatal(err)
+	}
+
+	var clientSpan, serverSpan tracetest.SpanStub
+	for _, span := range exporter.GetSpans() {
+		switch span.SpanKind {
+		case trace.SpanKindClient:
+			clientSpan = span
+		case trace.SpanKindServer:
+			serverSpan = span
+		}
+	}
+
+	if !clientSpan.SpanContext.IsValid() || clientSpan.Name != "Hello.Greeter/SayHello" {
+		t.Fatalf("client span = %+v", clientSpan)
+	}
+
+	// the trace context is propagated to the server
+	if serverSpan.Parent.SpanID() != clientSpan.SpanContext.SpanID() ||
+		serverSpan.SpanContext.TraceID() != clientSpan.SpanContext.TraceID() {
+		t.Fatalf("server span parent = %v,client span = %v", serverSpan.Parent, clientSpan.SpanContext)
+	}
+}
@@ -0,0 +1,216 @@
+// Package gerrors provides the rich error model of gRPC services,
+// the errors carry google.rpc.Status details (BadRequest,ErrorInfo,RetryInfo,LocalizedMessage)
+// from the handlers to the gRPC clients and the http gateway clients.
+package gerrors
+
+import (
+	"fmt"
+	"time"
+
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	spb "google.golang.org/genproto/googleapis/rpc/status"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/anypb"
+	"google.golang.org/protobuf/types/known/durationpb"
+)
+
+// Error the gRPC error with the status details,it is converted to the status by grpc-go.
+type Error struct {
+	code    codes.Code
+	message string
+	cause   error
+
+	badRequest *errdetails.BadRequest
+	details    []proto.Message
+}
+
+// New creates an error with the code and the message.
+func New(code codes.Code, message string) *Error {
+	return &Error{code: code, message: message}
+}
+
+// Newf creates an error with the code and the formatted message.
+func Newf(code codes.Code, format string, args ...interface{}) *Error {
+	return New(code, fmt.Sprintf(format, args...))
+}
+
+// Wrap creates an error with the code and the message which wraps the cause,
+// the cause is not sent to the clients.
+func Wrap(cause error, code codes.Code, message string) *Error {
+	return &Error{code: code, message: message, cause: cause}
+}
+
+// Error returns the error message.
+func (e *Error) Error() string {
+	return fmt.Sprintf("rpc error: code = %s desc = %s", e.code, e.message)
+}
+
+// Unwrap returns the cause of the error.
+func (e *Error) Unwrap() error {
+	return e.cause
+}
+
+// Code returns the code of the error.
+func (e *Error) Code() codes.Code {
+	return e.code
+}
+
+// Message returns the message of the error.
+func (e *Error) Message() string {
+	return e.message
+}
+
+// WithFieldViolation adds a field violation to the BadRequest detail.
+func (e *Error) WithFieldViolation(field string, description string) *Error {
+	if e.badRequest == nil {
+		e.badRequest = &errdetails.BadRequest{}
+		e.details = append(e.details, e.badRequest)
+	}
+
+	e.badRequest.FieldViolations = append(e.badRequest.FieldViolations, &errdetails.BadRequest_FieldViolation{
+		Field:       field,
+		Description: description,
+	})
+	return e
+}
+
+// WithErrorInfo adds the ErrorInfo detail,the reason is a constant in UPPER_SNAKE_CASE
+// and the domain is the service name,eg: USER_NOT_FOUND,user.example.com
+func (e *Error) WithErrorInfo(reason string, domain string, metadata map[string]string) *Error {
+	e.details = append(e.details, &errdetails.ErrorInfo{Reason: reason, Domain: domain, Metadata: metadata})
+	return e
+}
+
+// WithRetryInfo adds the RetryInfo detail,the client should retry after the delay.
+func (e *Error) WithRetryInfo(delay time.Duration) *Error {
+	e.details = append(e.details, &errdetails.RetryInfo{RetryDelay: durationpb.New(delay)})
+	return e
+}
+
+// WithLocalizedMessage adds the LocalizedMessage detail,the locale is a BCP 47 tag,eg: zh-CN
+func (e *Error) WithLocalizedMessage(locale string, message string) *Error {
+	e.details = append(e.details, &errdetails.LocalizedMessage{Locale: locale, Message: message})
+	return e
+}
+
+// WithDetails adds the other details.
+func (e *Error) WithDetails(details ...proto.Message) *Error {
+	e.details = append(e.details, details...)
+	return e
+}
+
+// GRPCStatus returns the status with the details,it is used by status.FromError.
+func (e *Error) GRPCStatus() *status.Status {
+	s := &spb.Status{Code: int32(e.code), Message: e.message}
+	for _, detail := range e.details {
+		a, err := anypb.New(detail)
+		if err != nil {
+			continue
+		}
+
+		s.Details = append(s.Details, a)
+	}
+
+	return status.FromProto(s)
+}
+
+// Details the status details of the error.
+type Details struct {
+	Code             codes.Code
+	Message          string
+	BadRequest       *errdetails.BadRequest
+	ErrorInfo        *errdetails.ErrorInfo
+	RetryInfo        *errdetails.RetryInfo
+	LocalizedMessage *errdetails.LocalizedMessage
+	Others           []proto.Message // the details of the other types
+}
+
+// FromError returns the status details of the error,
+// it returns false when the error is not a gRPC status error.
+func FromError(err error) (*Details, bool) {
+	if err == nil {
+		return nil, false
+	}
+
+	st, ok := status.FromError(err)
+	if !ok {
+		return nil, false
+	}
+
+	return FromStatus(st), true
+}
+
+// FromStatus returns the details of the status.
+func FromStatus(st *status.Status) *Details {
+	d := &Details{Code: st.Code(), Message: st.Message()}
+	for _, a := range st.Proto().GetDetails() {
+		detail, err := a.UnmarshalNew()
+		if err != nil {
+			continue
+		}
+
+		switch v := detail.(type) {
+		case *errdetails.BadRequest:
+			if d.BadRequest == nil {
+				d.BadRequest = v
+				continue
+			}
+
+			d.BadRequest.FieldViolations = append(d.BadRequest.FieldViolations, v.FieldViolations...)
+		case *errdetails.ErrorInfo:
+			d.ErrorInfo = v
+		case *errdetails.RetryInfo:
+			d.RetryInfo = v
+		case *errdetails.LocalizedMessage:
+			d.LocalizedMessage = v
+		default:
+			d.Others = append(d.Others, detail)
+		}
+	}
+
+	return d
+}
+
+// Reason returns the reason of the ErrorInfo detail of the error.
+func Reason(err error) string {
+	d, ok := FromError(err)
+	if !ok {
+		return ""
+	}
+
+	return d.ErrorInfo.GetReason()
+}
+
+// FieldViolations returns the field violations of the BadRequest detail of the error.
+func FieldViolations(err error) []*errdetails.BadRequest_FieldViolation {
+	d, ok := FromError(err)
+	if !ok {
+		return nil
+	}
+
+	return d.BadRequest.GetFieldViolations()
+}
+
+// RetryDelay returns the delay of the RetryInfo detail of the error.
+func RetryDelay(err error) (time.Duration, bool) {
+	d, ok := FromError(err)
+	if !ok || d.RetryInfo == nil {
+		return 0, false
+	}
+
+	return d.RetryInfo.GetRetryDelay().AsDuration(), true
+}
+
+// IsServerErrorCode reports whether the code means a server side error,
+// the access log and the tracing spans classify the errors by it.
+func IsServerErrorCode(code codes.Code) bool {
+	switch code {
+	case codes.Unknown, codes.DeadlineExceeded, codes.Unimplemented, codes.Internal,
+		codes.Unavailable, codes.DataLoss:
+		return true
+	default:
+		return false
+	}
+}
@@ -0,0 +1,148 @@
+package gerrors
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	gRuntime "github.com/grpc-ecosystem/grpc-gateway/v2/runtime"
+	spb "google.golang.org/genproto/googleapis/rpc/status"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+)
+
+// roundTrip marshals the status of the error like the gRPC transport.
+func roundTrip(t *testing.T, err error) error {
+	t.Helper()
+
+	data, e := proto.Marshal(status.Convert(err).Proto())
+	if e != nil {
+		t.Fatal(e)
+	}
+
+	st := &spb.Status{}
+	if e = proto.Unmarshal(data, st); e != nil {
+		t.Fatal(e)
+	}
+
+	return status.FromProto(st).Err()
+}
+
+func TestDetails(t *testing.T) {
+	err := roundTrip(t, New(codes.InvalidArgument, "invalid request").
+		WithFieldViolation("name", "name is required").
+		WithFieldViolation("age", "age must be greater than 0").
+		WithErrorInfo("INVALID_USER", "user.example.com", map[string]string{"id": "1"}).
+		WithRetryInfo(1500*time.Millisecond).
+		WithLocalizedMessage("zh-CN", "请求参数错误"))
+
+	d, ok := FromError(err)
+	if !ok {
+		t.Fatalf("not a status error: %v", err)
+	}
+
+	if d.Code != codes.InvalidArgument || d.Message != "invalid request" {
+		t.Fatalf("unexpected status: %v %s", d.Code, d.Message)
+	}
+	if len(d.BadRequest.GetFieldViolations()) != 2 || FieldViolations(err)[1].GetField() != "age" {
+		t.Fatalf("unexpected field violations: %v", d.BadRequest)
+	}
+	if Reason(err) != "INVALID_USER" || d.ErrorInfo.GetMetadata()["id"] != "1" {
+		t.Fatalf("unexpected error info: %v", d.ErrorInfo)
+	}
+	if delay, ok := RetryDelay(err); !ok || delay != 1500*time.Millisecond {
+		t.Fatalf("retry delay = %v", delay)
+	}
+	if d.LocalizedMessage.GetMessage() != "请求参数错误" {
+		t.Fatalf("unexpected localized message: %v", d.LocalizedMessage)
+	}
+}
+
+func TestRegistry(t *testing.T) {
+	errNotFound := errors.New("user not found")
+	r := NewRegistry("user.example.com")
+	r.Register(errNotFound, codes.NotFound, "USER_NOT_FOUND")
+
+	err := r.Convert(fmt.Errorf("query user 1: %w", errNotFound))
+	if status.Code(err) != codes.NotFound || Reason(err) != "USER_NOT_FOUND" || !errors.Is(err, errNotFound) {
+		t.Fatalf("unexpected converted error: %v", err)
+	}
+
+	d, _ := FromError(err)
+	if d.ErrorInfo.GetDomain() != "user.example.com" || d.Message != "query user 1: user not found" {
+		t.Fatalf("unexpected details: %+v", d)
+	}
+
+	if err = r.Convert(context.DeadlineExceeded); status.Code(err) != codes.DeadlineExceeded {
+		t.Fatalf("unexpected converted error: %v", err)
+	}
+
+	st := status.Error(codes.Aborted, "aborted")
+	if err = r.Convert(st); err != st {
+		t.Fatalf("the status error must be unchanged: %v", err)
+	}
+
+	if err = r.Convert(errors.New("other")); status.Code(err) != codes.Unknown {
+		t.Fatalf("unexpected converted error: %v", err)
+	}
+}
+
+func TestHTTPErrorHandler(t *testing.T) {
+	err := New(codes.InvalidArgument, "invalid request").
+		WithFieldViolation("name", "name is required").
+		WithErrorInfo("INVALID_USER", "user.example.com", nil).
+		WithRetryInfo(1500 * time.Millisecond)
+
+	w := httptest.NewRecorder()
+	HTTPErrorHandler(context.Background(), nil, nil, w, httptest.NewRequest(http.MethodGet, "/", nil), err)
+	if w.Code != http.StatusBadRequest || w.Header().Get("Retry-After") != "2" {
+		t.Fatalf("response code:%d headers:%v", w.Code, w.Header())
+	}
+
+	var body HTTPError
+	if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+		t.Fatal(err)
+	}
+
+	if body.Code != 3 || body.Status != "INVALID_ARGUMENT" || body.Reason != "INVALID_USER" ||
+		body.RetryDelay != "1.5s" || len(body.FieldViolations) != 1 || body.FieldViolations[0].Field != "name" {
+		t.Fatalf("unexpected body: %s", w.Body.String())
+	}
+}
+
+func TestHTTPErrorHandlerMetadata(t *testing.T) {
+	ctx := gRuntime.NewServerMetadataContext(context.Background(), gRuntime.ServerMetadata{
+		HeaderMD:  metadata.Pairs("x-user-id", "1"),
+		TrailerMD: metadata.Pairs("x-trace-id", "abc"),
+	})
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	r.Header.Set("TE", "trailers")
+	w := httptest.NewRecorder()
+	HTTPErrorHandler(ctx, gRuntime.NewServeMux(), &gRuntime.JSONPb{}, w, r,
+		status.Error(codes.Unauthenticated, `Bearer realm="hephfx"`))
+
+	resp := w.Result()
+	if resp.StatusCode != http.StatusUnauthorized || resp.Header.Get("Content-Type") != "application/json" {
+		t.Fatalf("response code:%d headers:%v", resp.StatusCode, resp.Header)
+	}
+
+	if resp.Header.Get("Grpc-Metadata-X-User-Id") != "1" || resp.Header.Get("WWW-Authenticate") != `Bearer realm="hephfx"` {
+		t.Fatalf("response headers:%v", resp.Header)
+	}
+
+	if resp.Trailer.Get("Grpc-Trailer-X-Trace-Id") != "abc" {
+		t.Fatalf("response trailers:%v", resp.Trailer)
+	}
+
+	var body HTTPError
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil || body.Status != "UNAUTHENTICATED" {
+		t.Fatalf("unexpected body: %s,err: %v", w.Body.String(), err)
+	}
+}
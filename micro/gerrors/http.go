@@ -0,0 +1,119 @@
+package gerrors
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"math"
+	"net/http"
+	"strconv"
+
+	gRuntime "github.com/grpc-ecosystem/grpc-gateway/v2/runtime"
+	"google.golang.org/genproto/googleapis/rpc/code"
+	"google.golang.org/grpc/status"
+)
+
+// HTTPError the json body of the http gateway error response.
+type HTTPError struct {
+	Code             int               `json:"code"`   // the gRPC code,eg: 3
+	Status           string            `json:"status"` // the gRPC code name,eg: INVALID_ARGUMENT
+	Message          string            `json:"message"`
+	Reason           string            `json:"reason,omitempty"`
+	Domain           string            `json:"domain,omitempty"`
+	Metadata         map[string]string `json:"metadata,omitempty"`
+	FieldViolations  []FieldViolation  `json:"field_violations,omitempty"`
+	RetryDelay       string            `json:"retry_delay,omitempty"` // eg: 1.5s
+	LocalizedMessage *LocalizedMessage `json:"localized_message,omitempty"`
+}
+
+// FieldViolation the field violation of the request.
+type FieldViolation struct {
+	Field       string `json:"field"`
+	Description string `json:"description"`
+}
+
+// LocalizedMessage the localized error message.
+type LocalizedMessage struct {
+	Locale  string `json:"locale"`
+	Message string `json:"message"`
+}
+
+// NewHTTPError returns the json body of the status details.
+func NewHTTPError(d *Details) *HTTPError {
+	e := &HTTPError{
+		Code:    int(d.Code),
+		Status:  code.Code(d.Code).String(),
+		Message: d.Message,
+	}
+
+	if d.ErrorInfo != nil {
+		e.Reason = d.ErrorInfo.GetReason()
+		e.Domain = d.ErrorInfo.GetDomain()
+		e.Metadata = d.ErrorInfo.GetMetadata()
+	}
+
+	for _, v := range d.BadRequest.GetFieldViolations() {
+		e.FieldViolations = append(e.FieldViolations, FieldViolation{Field: v.GetField(), Description: v.GetDescription()})
+	}
+
+	if d.RetryInfo != nil {
+		e.RetryDelay = d.RetryInfo.GetRetryDelay().AsDuration().String()
+	}
+
+	if d.LocalizedMessage != nil {
+		e.LocalizedMessage = &LocalizedMessage{
+			Locale:  d.LocalizedMessage.GetLocale(),
+			Message: d.LocalizedMessage.GetMessage(),
+		}
+	}
+
+	return e
+}
+
+// defaultServeMux provides the default header matchers when the mux of HTTPErrorHandler is nil.
+var defaultServeMux = gRuntime.NewServeMux()
+
+// httpErrorMarshaler writes the prepared HTTPError json for gRuntime.DefaultHTTPErrorHandler.
+type httpErrorMarshaler struct {
+	gRuntime.Marshaler
+	body []byte
+	err  error
+}
+
+// ContentType returns application/json.
+func (m *httpErrorMarshaler) ContentType(_ interface{}) string {
+	return "application/json"
+}
+
+// Marshal returns the HTTPError json.
+func (m *httpErrorMarshaler) Marshal(_ interface{}) ([]byte, error) {
+	return m.body, m.err
+}
+
+// HTTPErrorHandler renders the gRPC error as HTTPError json,the http status is mapped
+// from the gRPC code and the Retry-After header is set by the RetryInfo detail.
+// The server metadata and trailers are forwarded by the header matchers of mux,
+// and the WWW-Authenticate header is set for Unauthenticated as gRuntime.DefaultHTTPErrorHandler does.
+// It is the default error handler of the micro http gateway.
+func HTTPErrorHandler(ctx context.Context, mux *gRuntime.ServeMux, marshaler gRuntime.Marshaler,
+	w http.ResponseWriter, r *http.Request, err error) {
+	statusErr := err
+	var httpStatusErr *gRuntime.HTTPStatusError
+	if errors.As(err, &httpStatusErr) {
+		statusErr = httpStatusErr.Err
+	}
+
+	d := FromStatus(status.Convert(statusErr))
+	if d.RetryInfo != nil {
+		seconds := math.Ceil(d.RetryInfo.GetRetryDelay().AsDuration().Seconds())
+		w.Header().Set("Retry-After", strconv.Itoa(int(seconds)))
+	}
+
+	if mux == nil {
+		mux = defaultServeMux
+	}
+
+	body, marshalErr := json.Marshal(NewHTTPError(d))
+	gRuntime.DefaultHTTPErrorHandler(ctx, mux, &httpErrorMarshaler{Marshaler: marshaler, body: body, err: marshalErr},
+		w, r, err)
+}
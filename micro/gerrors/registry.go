@@ -0,0 +1,114 @@
+package gerrors
+
+import (
+	"context"
+	"errors"
+	"sync"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// defaultRegistry the default registry of the domain errors.
+var defaultRegistry = NewRegistry("")
+
+// Registry maps the domain errors to the gRPC codes and the ErrorInfo reasons.
+type Registry struct {
+	domain string
+
+	mu       sync.RWMutex
+	mappings []mapping
+}
+
+// mapping the code and the reason of the target error.
+type mapping struct {
+	target error
+	code   codes.Code
+	reason string
+}
+
+// NewRegistry creates a registry,the domain is set to the ErrorInfo of the converted errors.
+func NewRegistry(domain string) *Registry {
+	return &Registry{domain: domain}
+}
+
+// Register maps the target error to the code and the reason,
+// the errors which match the target by errors.Is are converted.
+func (r *Registry) Register(target error, code codes.Code, reason string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.mappings = append(r.mappings, mapping{target: target, code: code, reason: reason})
+}
+
+// Convert converts the error to the gRPC status error:
+// the status errors are returned unchanged,the registered errors are converted
+// with the code and the ErrorInfo,context.Canceled and context.DeadlineExceeded
+// are converted to codes.Canceled and codes.DeadlineExceeded.
+// The other errors are returned unchanged and grpc-go reports them as codes.Unknown.
+func (r *Registry) Convert(err error) error {
+	if err == nil {
+		return nil
+	}
+
+	if _, ok := err.(interface{ GRPCStatus() *status.Status }); ok {
+		return err
+	}
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	// the later registered mappings take precedence
+	for i := len(r.mappings) - 1; i >= 0; i-- {
+		m := r.mappings[i]
+		if errors.Is(err, m.target) {
+			e := Wrap(err, m.code, err.Error())
+			if m.reason != "" {
+				e.WithErrorInfo(m.reason, r.domain, nil)
+			}
+
+			return e
+		}
+	}
+
+	switch {
+	case errors.Is(err, context.Canceled):
+		return Wrap(err, codes.Canceled, err.Error())
+	case errors.Is(err, context.DeadlineExceeded):
+		return Wrap(err, codes.DeadlineExceeded, err.Error())
+	}
+
+	return err
+}
+
+// UnaryServerInterceptor returns a server unary interceptor which converts the handler errors.
+func (r *Registry) UnaryServerInterceptor() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo,
+		handler grpc.UnaryHandler) (interface{}, error) {
+		resp, err := handler(ctx, req)
+		return resp, r.Convert(err)
+	}
+}
+
+// StreamServerInterceptor returns a server stream interceptor which converts the handler errors.
+func (r *Registry) StreamServerInterceptor() grpc.StreamServerInterceptor {
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		return r.Convert(handler(srv, ss))
+	}
+}
+
+// Register maps the target error to the code and the reason in the default registry.
+func Register(target error, code codes.Code, reason string) {
+	defaultRegistry.Register(target, code, reason)
+}
+
+// Convert converts the error to the gRPC status error by the default registry.
+func Convert(err error) error {
+	return defaultRegistry.Convert(err)
+}
+
+// DefaultRegistry returns the default registry.
+func DefaultRegistry() *Registry {
+	return defaultRegistry
+}
@@ -0,0 +1,53 @@
+package micro
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"google.golang.org/grpc/codes"
+
+	"github.com/daheige/hephfx/example/pb"
+	"github.com/daheige/hephfx/micro/bridge"
+	"github.com/daheige/hephfx/micro/gerrors"
+)
+
+var errUserNotFound = errors.New("user not found")
+
+func TestWithErrorRegistry(t *testing.T) {
+	r := gerrors.NewRegistry("greeter.example.com")
+	r.Register(errUserNotFound, codes.NotFound, "USER_NOT_FOUND")
+
+	s := newTestService(t, &testGreeter{err: errUserNotFound},
+		WithHandlerFromEndpoints(pb.RegisterGreeterHandlerFromEndpoint),
+		WithErrorRegistry(r),
+	)
+
+	// the gRPC clients extract the details by bridge helpers
+	conn := dialService(t, s)
+	_, err := pb.NewGreeterClient(conn).SayHello(context.Background(), &pb.HelloReq{Name: "heige"})
+	if d, ok := bridge.GRPCErrorDetails(err); !ok || d.Code != codes.NotFound || bridge.ErrorReason(err) != "USER_NOT_FOUND" {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	// the gateway clients receive the json details
+	handler, err := s.HTTPHandler()
+	if err != nil {
+		t.Fatalf("http handler error: %v", err)
+	}
+
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/v1/say/heige", nil))
+	var body gerrors.HTTPError
+	if err = json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+		t.Fatal(err)
+	}
+
+	if w.Code != http.StatusNotFound || body.Status != "NOT_FOUND" || body.Reason != "USER_NOT_FOUND" ||
+		body.Domain != "greeter.example.com" {
+		t.Fatalf("gateway response code:%d body:%s", w.Code, w.Body.String())
+	}
+}
@@ -0,0 +1,258 @@
+package micro
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/binary"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+
+	"google.golang.org/grpc"
+)
+
+const (
+	// grpcWebContentType the content type of the gRPC-Web binary requests,eg: application/grpc-web+proto
+	grpcWebContentType = "application/grpc-web"
+
+	// grpcWebTextContentType the content type of the gRPC-Web text requests,the body is base64 encoded
+	grpcWebTextContentType = "application/grpc-web-text"
+
+	// grpcWebTrailerFlag the flag of the frame which carries the trailers in the body
+	grpcWebTrailerFlag = 0x80
+)
+
+// grpcWebExposeHeaders the response headers read by the gRPC-Web clients
+var grpcWebExposeHeaders = []string{"Grpc-Status", "Grpc-Message", "Grpc-Status-Details-Bin"}
+
+// grpcWebAllowHeaders the request headers sent by the gRPC-Web clients
+var grpcWebAllowHeaders = []string{"Content-Type", "X-Grpc-Web", "X-User-Agent", "Grpc-Timeout"}
+
+// GRPCWebOption gRPC-Web option
+type GRPCWebOption func(g *grpcWeb)
+
+// grpcWeb serves the gRPC-Web requests by the gRPC server.
+type grpcWeb struct {
+	cors        bool
+	corsOptions []CORSOption
+	handler     http.Handler
+}
+
+// WithGRPCWebCORS returns a GRPCWebOption to handle the cross-origin gRPC-Web requests,
+// the gRPC-Web request headers are allowed and the grpc-status,grpc-message headers are exposed.
+func WithGRPCWebCORS(opts ...CORSOption) GRPCWebOption {
+	return func(g *grpcWeb) {
+		g.cors = true
+		g.corsOptions = append(g.corsOptions, opts...)
+	}
+}
+
+// GRPCWebHandler returns the handler which serves the gRPC-Web requests (binary and text) by grpcServer.
+// The requests are translated into gRPC requests,and the trailers are encoded in the response body,
+// so that the browser clients can call the gRPC services without a proxy,eg: envoy.
+func GRPCWebHandler(grpcServer *grpc.Server, opts ...GRPCWebOption) http.Handler {
+	return newGRPCWeb(grpcServer, opts...)
+}
+
+func newGRPCWeb(grpcServer *grpc.Server, opts ...GRPCWebOption) *grpcWeb {
+	g := &grpcWeb{}
+	for _, o := range opts {
+		o(g)
+	}
+
+	g.handler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		serveGRPCWeb(grpcServer, w, r)
+	})
+	if g.cors {
+		g.handler = CORSMiddleware(g.corsOptionsWithDefaults()...)(g.handler)
+	}
+
+	return g
+}
+
+// ServeHTTP serves the gRPC-Web request by the gRPC server.
+func (g *grpcWeb) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	g.handler.ServeHTTP(w, r)
+}
+
+// corsOptionsWithDefaults appends the gRPC-Web headers to the cors options.
+func (g *grpcWeb) corsOptionsWithDefaults() []CORSOption {
+	return append(append([]CORSOption{}, g.corsOptions...), func(c *cors) {
+		c.allowMethods = []string{http.MethodPost}
+		if len(c.allowHeaders) > 0 {
+			c.allowHeaders = append(c.allowHeaders, grpcWebAllowHeaders...)
+		}
+
+		c.exposeHeaders = append(c.exposeHeaders, grpcWebExposeHeaders...)
+	})
+}
+
+// match reports whether the request is a gRPC-Web request,
+// the cors preflight requests of gRPC-Web are included when cors is enabled.
+func (g *grpcWeb) match(r *http.Request) bool {
+	if strings.HasPrefix(r.Header.Get("Content-Type"), grpcWebContentType) {
+		return true
+	}
+
+	return g.cors && r.Method == http.MethodOptions &&
+		strings.Contains(strings.ToLower(r.Header.Get("Access-Control-Request-Headers")), "x-grpc-web")
+}
+
+// serveGRPCWeb translates the gRPC-Web request into the gRPC request served by grpcServer.
+func serveGRPCWeb(grpcServer *grpc.Server, w http.ResponseWriter, r *http.Request) {
+	contentType, _, _ := strings.Cut(r.Header.Get("Content-Type"), ";")
+	text := strings.HasPrefix(contentType, grpcWebTextContentType)
+	subtype := strings.TrimPrefix(contentType, grpcWebContentType)
+	if text {
+		subtype = strings.TrimPrefix(contentType, grpcWebTextContentType)
+	}
+
+	req := r.Clone(r.Context())
+	req.Proto, req.ProtoMajor, req.ProtoMinor = "HTTP/2.0", 2, 0
+	req.Header.Set("Content-Type", "application/grpc"+subtype)
+	req.Header.Del("Content-Length")
+	req.Header.Set("Te", "trailers")
+	if text {
+		req.Body = struct {
+			io.Reader
+			io.Closer
+		}{base64.NewDecoder(base64.StdEncoding, r.Body), r.Body}
+		req.ContentLength = -1
+	}
+
+	// the gRPC server reads the request body while writing the response
+	_ = http.NewResponseController(w).EnableFullDuplex()
+
+	gw := &grpcWebResponseWriter{
+		ResponseWriter: w,
+		header:         make(http.Header),
+		contentType:    contentType,
+		text:           text,
+	}
+	grpcServer.ServeHTTP(gw, req)
+	gw.finish()
+}
+
+// grpcWebResponseWriter writes the gRPC response as the gRPC-Web response,
+// the trailers are written as the last frame of the body.
+type grpcWebResponseWriter struct {
+	http.ResponseWriter
+	header       http.Header
+	contentType  string
+	text         bool
+	wroteHeader  bool
+	trailerNames []string
+	encoder      io.WriteCloser
+}
+
+// Header returns the gRPC response headers,the trailers are kept until the response finishes.
+func (w *grpcWebResponseWriter) Header() http.Header {
+	return w.header
+}
+
+// WriteHeader writes the headers except the trailers.
+func (w *grpcWebResponseWriter) WriteHeader(code int) {
+	if w.wroteHeader {
+		return
+	}
+
+	w.wroteHeader = true
+	w.trailerNames = declaredTrailers(w.header)
+	header := w.ResponseWriter.Header()
+	for key, values := range w.header {
+		if key != "Trailer" && !strings.HasPrefix(key, http.TrailerPrefix) {
+			header[key] = values
+		}
+	}
+
+	header.Set("Content-Type", w.contentType)
+	header.Del("Content-Length")
+	w.ResponseWriter.WriteHeader(code)
+}
+
+// Write writes the response body,it is base64 encoded in the text mode.
+func (w *grpcWebResponseWriter) Write(p []byte) (int, error) {
+	if !w.wroteHeader {
+		w.WriteHeader(http.StatusOK)
+	}
+
+	if !w.text {
+		return w.ResponseWriter.Write(p)
+	}
+
+	if w.encoder == nil {
+		w.encoder = base64.NewEncoder(base64.StdEncoding, w.ResponseWriter)
+	}
+
+	return w.encoder.Write(p)
+}
+
+// Flush flushes the response,the base64 chunk is padded in the text mode.
+func (w *grpcWebResponseWriter) Flush() {
+	if !w.wroteHeader {
+		w.WriteHeader(http.StatusOK)
+	}
+
+	if w.encoder != nil {
+		_ = w.encoder.Close()
+		w.encoder = nil
+	}
+
+	_ = http.NewResponseController(w.ResponseWriter).Flush()
+}
+
+// finish writes the trailers frame:
+// the flag 0x80,the 4 bytes length and the trailers in the http/1 header format.
+func (w *grpcWebResponseWriter) finish() {
+	trailers := grpcTrailers(w.header, w.trailerNames)
+	names := make([]string, 0, len(trailers))
+	for name := range trailers {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+	var b bytes.Buffer
+	for _, name := range names {
+		for _, v := range trailers[name] {
+			b.WriteString(name + ": " + v + "\r\n")
+		}
+	}
+
+	frame := make([]byte, 5, 5+b.Len())
+	frame[0] = grpcWebTrailerFlag
+	binary.BigEndian.PutUint32(frame[1:], uint32(b.Len()))
+	_, _ = w.Write(append(frame, b.Bytes()...))
+	w.Flush()
+}
+
+// declaredTrailers returns the trailer names declared by the Trailer header of the gRPC response.
+func declaredTrailers(header http.Header) []string {
+	var names []string
+	for _, v := range header.Values("Trailer") {
+		for _, name := range strings.Split(v, ",") {
+			names = append(names, strings.TrimSpace(name))
+		}
+	}
+
+	return names
+}
+
+// grpcTrailers returns the trailers of the gRPC response with the lower case names,
+// they are declared by the Trailer header or prefixed by http.TrailerPrefix.
+func grpcTrailers(header http.Header, declared []string) map[string][]string {
+	trailers := make(map[string][]string)
+	for _, name := range declared {
+		if values := header.Values(name); len(values) > 0 {
+			trailers[strings.ToLower(name)] = values
+		}
+	}
+
+	for key, values := range header {
+		if name, ok := strings.CutPrefix(key, http.TrailerPrefix); ok {
+			trailers[strings.ToLower(name)] = values
+		}
+	}
+
+	return trailers
+}
@@ -0,0 +1,205 @@
+package micro
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/binary"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/protobuf/proto"
+
+	"github.com/daheige/hephfx/example/pb"
+)
+
+// grpcWebResponse the decoded gRPC-Web response
+type grpcWebResponse struct {
+	header   http.Header
+	messages [][]byte
+	trailers map[string]string
+}
+
+// grpcWebCall calls the method like the browser gRPC-Web clients.
+func grpcWebCall(t *testing.T, url string, contentType string, req proto.Message) *grpcWebResponse {
+	t.Helper()
+
+	b, err := proto.Marshal(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	body := append([]byte{0, 0, 0, 0, 0}, b...)
+	binary.BigEndian.PutUint32(body[1:], uint32(len(b)))
+	text := strings.HasPrefix(contentType, grpcWebTextContentType)
+	if text {
+		body = []byte(base64.StdEncoding.EncodeToString(body))
+	}
+
+	r, _ := http.NewRequest(http.MethodPost, url, bytes.NewReader(body))
+	r.Header.Set("Content-Type", contentType)
+	r.Header.Set("X-Grpc-Web", "1")
+	r.Header.Set("Origin", "https://app.example.com")
+	resp, err := http.DefaultClient.Do(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer resp.Body.Close()
+
+	data, _ := io.ReadAll(resp.Body)
+	if text {
+		// the base64 chunks are padded separately
+		var decoded []byte
+		for i := 0; i+4 <= len(data); i += 4 {
+			chunk, err := base64.StdEncoding.DecodeString(string(data[i : i+4]))
+			if err != nil {
+				t.Fatalf("decode body %q error: %v", data, err)
+			}
+
+			decoded = append(decoded, chunk...)
+		}
+
+		data = decoded
+	}
+
+	res := &grpcWebResponse{header: resp.Header, trailers: make(map[string]string)}
+	for len(data) >= 5 {
+		flag, n := data[0], binary.BigEndian.Uint32(data[1:5])
+		frame := data[5 : 5+n]
+		data = data[5+n:]
+		if flag&grpcWebTrailerFlag == 0 {
+			res.messages = append(res.messages, frame)
+			continue
+		}
+
+		for _, line := range strings.Split(strings.TrimSpace(string(frame)), "\r\n") {
+			key, value, _ := strings.Cut(line, ": ")
+			res.trailers[key] = value
+		}
+	}
+
+	return res
+}
+
+func TestGRPCWeb(t *testing.T) {
+	grpcServer := grpc.NewServer()
+	pb.RegisterGreeterServer(grpcServer, &testGreeter{})
+	other := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("gateway"))
+	})
+
+	srv := httptest.NewServer(h2cHandler(grpcHandler(grpcServer, other,
+		newGRPCWeb(grpcServer, WithGRPCWebCORS(WithCORSAllowOrigins("https://app.example.com"))))))
+	defer srv.Close()
+
+	for _, contentType := range []string{
+		"application/grpc-web", "application/grpc-web+proto", "application/grpc-web-text+proto",
+	} {
+		res := grpcWebCall(t, srv.URL+"/Hello.Greeter/SayHello", contentType, &pb.HelloReq{Name: "heige"})
+		if got := res.header.Get("Content-Type"); got != contentType {
+			t.Fatalf("%s: content type = %s", contentType, got)
+		}
+
+		if res.header.Get("Grpc-Status") != "" {
+			t.Fatalf("%s: the trailers are written in the headers", contentType)
+		}
+
+		if !strings.Contains(res.header.Get("Access-Control-Expose-Headers"), "Grpc-Status") {
+			t.Fatalf("%s: expose headers = %s", contentType, res.header.Get("Access-Control-Expose-Headers"))
+		}
+
+		if len(res.messages) != 1 || res.trailers["grpc-status"] != "0" {
+			t.Fatalf("%s: messages = %d,trailers = %v", contentType, len(res.messages), res.trailers)
+		}
+
+		reply := &pb.HelloReply{}
+		if err := proto.Unmarshal(res.messages[0], reply); err != nil || reply.Message != "hello,heige" {
+			t.Fatalf("%s: reply = %v,err: %v", contentType, reply, err)
+		}
+	}
+
+	// trailers-only response
+	res := grpcWebCall(t, srv.URL+"/Hello.Greeter/Unknown", "application/grpc-web+proto", &pb.HelloReq{})
+	if len(res.messages) != 0 || res.trailers["grpc-status"] != "12" || res.trailers["grpc-message"] == "" {
+		t.Fatalf("unknown method: messages = %d,trailers = %v", len(res.messages), res.trailers)
+	}
+
+	// cors preflight
+	r, _ := http.NewRequest(http.MethodOptions, srv.URL+"/Hello.Greeter/SayHello", nil)
+	r.Header.Set("Origin", "https://app.example.com")
+	r.Header.Set("Access-Control-Request-Method", http.MethodPost)
+	r.Header.Set("Access-Control-Request-Headers", "content-type,x-grpc-web,x-user-agent")
+	resp, err := http.DefaultClient.Do(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_ = resp.Body.Close()
+	if resp.StatusCode != http.StatusNoContent ||
+		resp.Header.Get("Access-Control-Allow-Origin") != "https://app.example.com" ||
+		!strings.Contains(resp.Header.Get("Access-Control-Allow-Headers"), "x-grpc-web") {
+		t.Fatalf("preflight = %d %v", resp.StatusCode, resp.Header)
+	}
+
+	// the other requests are served by the other handler
+	resp, err = http.Get(srv.URL + "/v1/say")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	b, _ := io.ReadAll(resp.Body)
+	_ = resp.Body.Close()
+	if string(b) != "gateway" {
+		t.Fatalf("other handler body = %s", b)
+	}
+
+	// the native gRPC clients over h2c are not affected,eg: the Node.js client
+	conn, err := grpc.NewClient(srv.Listener.Addr().String(), grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer conn.Close()
+
+	reply, err := pb.NewGreeterClient(conn).SayHello(context.Background(), &pb.HelloReq{Name: "node"})
+	if err != nil || reply.Message != "hello,node" {
+		t.Fatalf("gRPC reply = %v,err: %v", reply, err)
+	}
+}
+
+func TestGRPCWebDisabled(t *testing.T) {
+	grpcServer := grpc.NewServer()
+	pb.RegisterGreeterServer(grpcServer, &testGreeter{})
+	other := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("gateway"))
+	})
+
+	// the gRPC-Web requests are served by the other handler without WithGRPCWeb
+	srv := httptest.NewServer(GRPCHandlerFunc(grpcServer, other))
+	defer srv.Close()
+
+	resp, err := http.Post(srv.URL+"/Hello.Greeter/SayHello", "application/grpc-web+proto", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	b, _ := io.ReadAll(resp.Body)
+	_ = resp.Body.Close()
+	if string(b) != "gateway" {
+		t.Fatalf("gRPC-Web request body = %s, want gateway", b)
+	}
+
+	if n := len(NewService("").protocolHandlers()); n != 0 {
+		t.Fatalf("protocol handlers = %d without WithGRPCWeb, want 0", n)
+	}
+
+	if n := len(NewService("", WithGRPCWeb()).protocolHandlers()); n != 1 {
+		t.Fatalf("protocol handlers = %d with WithGRPCWeb, want 1", n)
+	}
+}
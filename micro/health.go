@@ -0,0 +1,89 @@
+package micro
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	// healthzPath the http gateway liveness probe path
+	healthzPath = "/healthz"
+
+	// readyzPath the http gateway readiness probe path
+	readyzPath = "/readyz"
+)
+
+// SetServingStatus sets the serving status of the service in the gRPC health server.
+// service is the gRPC full service name,eg: Hello.Greeter,
+// the empty string means the overall health of the server.
+// It does nothing when the health check is not enabled by WithEnableHealthCheck.
+func (s *Service) SetServingStatus(service string, servingStatus healthpb.HealthCheckResponse_ServingStatus) {
+	if s.healthServer == nil {
+		return
+	}
+
+	s.healthServer.SetServingStatus(service, servingStatus)
+}
+
+// registerHealthServer registers the standard grpc.health.v1.Health service on gRPC server.
+func (s *Service) registerHealthServer() {
+	s.healthServer = health.NewServer()
+	healthpb.RegisterHealthServer(s.GRPCServer, s.healthServer)
+}
+
+// shutdownHealthServer sets all serving status to NOT_SERVING at the start of shutdown,
+// so that the load balancers can drain the traffic first.
+func (s *Service) shutdownHealthServer() {
+	if s.healthServer == nil {
+		return
+	}
+
+	s.logger.Printf("set all gRPC health serving status to NOT_SERVING\n")
+	s.healthServer.Shutdown()
+}
+
+// registerHealthRoutes mirrors the gRPC health server on gRPC http gateway mux.
+// /healthz is the liveness probe,it responds ok as long as the process is able to serve http requests.
+// /readyz is the readiness probe,it responds 503 when the service is NOT_SERVING,
+// the service query param is the gRPC full service name,eg: /readyz?service=Hello.Greeter
+func (s *Service) registerHealthRoutes() error {
+	err := s.handlePath(http.MethodGet, healthzPath, "health.liveness", http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			writeHealthStatus(w, http.StatusOK, "OK")
+		}))
+	if err != nil {
+		return err
+	}
+
+	return s.handlePath(http.MethodGet, readyzPath, "health.readiness", http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			reply, err := s.healthServer.Check(r.Context(), &healthpb.HealthCheckRequest{
+				Service: r.URL.Query().Get("service"),
+			})
+			if err != nil {
+				writeHealthStatus(w, http.StatusServiceUnavailable, status.Convert(err).Message())
+				return
+			}
+
+			if reply.GetStatus() != healthpb.HealthCheckResponse_SERVING {
+				writeHealthStatus(w, http.StatusServiceUnavailable, reply.GetStatus().String())
+				return
+			}
+
+			writeHealthStatus(w, http.StatusOK, reply.GetStatus().String())
+		}))
+}
+
+func writeHealthStatus(w http.ResponseWriter, code int, msg string) {
+	b, _ := json.Marshal(map[string]interface{}{
+		"status": msg,
+	})
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	_, _ = w.Write(b)
+}
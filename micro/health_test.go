@@ -0,0 +1,88 @@
+package micro
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+)
+
+func TestHealthCheck(t *testing.T) {
+	address := freeAddress(t)
+	drainStatus := make(chan healthpb.HealthCheckResponse_ServingStatus, 1)
+
+	var s *Service
+	s = NewService(
+		address,
+		WithEnableGRPCShareAddress(),
+		WithEnableHealthCheck(),
+		WithShutdownTimeout(time.Second),
+		WithShutdownHook(PhasePreDrain, "health-status", func(ctx context.Context) error {
+			reply, err := s.healthServer.Check(ctx, &healthpb.HealthCheckRequest{})
+			if err != nil {
+				return err
+			}
+
+			drainStatus <- reply.GetStatus()
+			return nil
+		}),
+	)
+	s.SetServingStatus("Hello.Greeter", healthpb.HealthCheckResponse_NOT_SERVING)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	errChan := make(chan error, 1)
+	go func() {
+		errChan <- s.RunContext(ctx)
+	}()
+	waitListening(t, address)
+
+	conn, err := grpc.NewClient(address, grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	reply, err := healthpb.NewHealthClient(conn).Check(ctx, &healthpb.HealthCheckRequest{})
+	if err != nil {
+		t.Fatalf("health check error: %v", err)
+	}
+	if reply.GetStatus() != healthpb.HealthCheckResponse_SERVING {
+		t.Fatalf("health status = %v, want SERVING", reply.GetStatus())
+	}
+
+	cases := []struct {
+		path string
+		code int
+	}{
+		{path: "/healthz", code: http.StatusOK},
+		{path: "/readyz", code: http.StatusOK},
+		{path: "/readyz?service=Hello.Greeter", code: http.StatusServiceUnavailable},
+		{path: "/readyz?service=not-exist", code: http.StatusServiceUnavailable},
+	}
+	for _, c := range cases {
+		resp, err := http.Get("http://" + address + c.path)
+		if err != nil {
+			t.Fatalf("get %s error: %v", c.path, err)
+		}
+		resp.Body.Close()
+
+		if resp.StatusCode != c.code {
+			t.Fatalf("get %s status code = %d, want %d", c.path, resp.StatusCode, c.code)
+		}
+	}
+
+	cancel()
+	if err := <-errChan; err != nil {
+		t.Fatalf("run context error: %v", err)
+	}
+
+	if got := <-drainStatus; got != healthpb.HealthCheckResponse_NOT_SERVING {
+		t.Fatalf("health status on shutdown = %v, want NOT_SERVING", got)
+	}
+}
@@ -0,0 +1,108 @@
+package micro
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zaptest/observer"
+	"google.golang.org/grpc"
+
+	"github.com/daheige/hephfx/example/pb"
+	"github.com/daheige/hephfx/logger"
+)
+
+// testGreeter replies hello,<name> and records the context of the last call.
+// It sleeps delay before replying,returns err instead of the reply when err is set,
+// and appends suffix to the reply message,eg: the process id.
+type testGreeter struct {
+	pb.UnimplementedGreeterServer
+	delay  time.Duration
+	err    error
+	suffix string
+	mu     sync.Mutex
+	ctx    context.Context
+}
+
+func (s *testGreeter) SayHello(ctx context.Context, req *pb.HelloReq) (*pb.HelloReply, error) {
+	s.mu.Lock()
+	s.ctx = ctx
+	s.mu.Unlock()
+
+	time.Sleep(s.delay)
+	if s.err != nil {
+		return nil, s.err
+	}
+
+	return &pb.HelloReply{Message: "hello," + req.Name + s.suffix}, nil
+}
+
+// lastContext returns the context of the last call.
+func (s *testGreeter) lastContext() context.Context {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.ctx
+}
+
+// newObservedLogger returns a logger.Logger which records the entries of all levels,
+// the entries are read from the observed logs.
+func newObservedLogger() (logger.Logger, *observer.ObservedLogs) {
+	core, logs := observer.New(zap.DebugLevel)
+	return logger.New(logger.WithStdout(false), logger.WithCores(core)), logs
+}
+
+// newTestService creates the service with the in-process gateway and registers the greeter,
+// the service is shut down when the test ends.
+func newTestService(t *testing.T, greeter pb.GreeterServer, opts ...Option) *Service {
+	t.Helper()
+
+	s := NewService("", append([]Option{WithEnableInProcessGateway()}, opts...)...)
+	pb.RegisterGreeterServer(s.GRPCServer, greeter)
+	t.Cleanup(func() {
+		_ = s.Shutdown(context.Background())
+	})
+
+	return s
+}
+
+// dialService returns a client connection to the service over the in-process listener.
+func dialService(t *testing.T, s *Service) *grpc.ClientConn {
+	t.Helper()
+
+	conn, err := s.DialInProcess()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Cleanup(func() {
+		conn.Close()
+		_ = s.Shutdown(context.Background())
+	})
+
+	return conn
+}
+
+// runService runs the service until the test ends.
+func runService(t *testing.T, s *Service, addresses ...string) {
+	t.Helper()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	errChan := make(chan error, 1)
+	go func() {
+		errChan <- s.RunContext(ctx)
+	}()
+
+	for _, address := range addresses {
+		waitListening(t, address)
+	}
+
+	t.Cleanup(func() {
+		cancel()
+		if err := <-errChan; err != nil {
+			t.Errorf("run context error: %v", err)
+		}
+	})
+}
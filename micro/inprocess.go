@@ -0,0 +1,120 @@
+package micro
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+
+	gRuntime "github.com/grpc-ecosystem/grpc-gateway/v2/runtime"
+	"google.golang.org/grpc"
+)
+
+// defaultInProcessBufferSize the buffer size of the in-process listener.
+const defaultInProcessBufferSize = 1 << 20
+
+// inProcessEndpoint is the target of the in-process connections,
+// the passthrough resolver hands it to the in-process dialer as it is.
+const inProcessEndpoint = "passthrough:///bufconn"
+
+var (
+	// ErrHTTPGatewayDisabled is returned when the http gateway is not enabled.
+	ErrHTTPGatewayDisabled = errors.New("http gateway is not enabled")
+
+	// ErrInProcessGatewayDisabled is returned when the in-process gateway is not enabled.
+	ErrInProcessGatewayDisabled = errors.New("in-process gateway is not enabled")
+)
+
+// HandlerServer is the callback that registers the http gateway handlers which call
+// the gRPC service implementation directly,eg:
+//
+//	func(ctx context.Context, mux *runtime.ServeMux) error {
+//		return pb.RegisterGreeterHandlerServer(ctx, mux, &GreeterServer{})
+//	}
+//
+// Note: the requests do not go through the gRPC server,so the gRPC interceptors are not executed.
+type HandlerServer func(ctx context.Context, mux *gRuntime.ServeMux) error
+
+// HTTPHandler returns the gRPC http gateway handler,the handlers are registered on the first call.
+// With WithEnableInProcessGateway, the handler does not depend on any port,
+// it can be mounted on other http servers or tested by httptest without calling Run.
+func (s *Service) HTTPHandler() (http.Handler, error) {
+	if s.mux == nil {
+		return nil, ErrHTTPGatewayDisabled
+	}
+
+	err := s.initGateway()
+	if err != nil {
+		return nil, err
+	}
+
+	return s.gatewayHandler, nil
+}
+
+// DialInProcess creates a client connection to the gRPC server over the in-process listener.
+// The connection uses the same credentials as the gateway,opts are appended to them.
+// The caller should close the connection when it is no longer used.
+func (s *Service) DialInProcess(opts ...grpc.DialOption) (*grpc.ClientConn, error) {
+	if s.inProcessListener == nil {
+		return nil, ErrInProcessGatewayDisabled
+	}
+
+	s.serveInProcess()
+	return grpc.NewClient(inProcessEndpoint, append(s.inProcessDialOptions(), opts...)...)
+}
+
+// initGateway registers the gRPC http gateway handlers and routes once,
+// and creates the gateway handler which is shared by all the http listeners.
+func (s *Service) initGateway() error {
+	s.gatewayOnce.Do(func() {
+		s.gatewayErr = s.registerGRPCHTTPEndpoints()
+		if s.gatewayErr == nil {
+			s.gatewayHandler = s.gRPCHTTPHandler(s.mux)
+			for i := len(s.gatewayMiddlewares) - 1; i >= 0; i-- {
+				s.gatewayHandler = s.gatewayMiddlewares[i](s.gatewayHandler)
+			}
+		}
+	})
+
+	return s.gatewayErr
+}
+
+// serveInProcess starts serving the gRPC server on the in-process listener once.
+func (s *Service) serveInProcess() {
+	if s.inProcessListener == nil {
+		return
+	}
+
+	s.inProcessOnce.Do(func() {
+		s.inProcessServing.Store(true)
+		go func() {
+			defer s.recovery()
+
+			err := s.GRPCServer.Serve(s.inProcessListener)
+			if err != nil {
+				s.logger.Printf("gRPC in-process server serve error: %v\n", err)
+			}
+		}()
+	})
+}
+
+// inProcessDialOptions returns the gateway dial options with the in-process dialer.
+func (s *Service) inProcessDialOptions() []grpc.DialOption {
+	opts := make([]grpc.DialOption, 0, len(s.gRPCEndpointDialOptions)+1)
+	opts = append(opts, s.gRPCEndpointDialOptions...)
+	opts = append(opts, grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+		return s.inProcessListener.DialContext(ctx)
+	}))
+
+	return opts
+}
+
+// gatewayEndpoint returns the endpoint and dial options that the gateway handlers dial to.
+func (s *Service) gatewayEndpoint() (string, []grpc.DialOption) {
+	if s.inProcessListener == nil {
+		return s.gRPCAddress, s.gRPCEndpointDialOptions
+	}
+
+	s.serveInProcess()
+	return inProcessEndpoint, s.inProcessDialOptions()
+}
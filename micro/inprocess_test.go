@@ -0,0 +1,133 @@
+package micro
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	gRuntime "github.com/grpc-ecosystem/grpc-gateway/v2/runtime"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+
+	"github.com/daheige/hephfx/example/pb"
+)
+
+func TestInProcessGateway(t *testing.T) {
+	var intercepted bool
+	countInterceptor := func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo,
+		handler grpc.UnaryHandler) (interface{}, error) {
+		intercepted = true
+		return handler(ctx, req)
+	}
+
+	cases := []struct {
+		name        string
+		opts        []Option
+		intercepted bool
+	}{
+		{
+			name:        "handler from endpoint",
+			opts:        []Option{WithHandlerFromEndpoints(pb.RegisterGreeterHandlerFromEndpoint)},
+			intercepted: true,
+		},
+		{
+			name: "handler server",
+			opts: []Option{WithHandlerServers(func(ctx context.Context, mux *gRuntime.ServeMux) error {
+				return pb.RegisterGreeterHandlerServer(ctx, mux, &testGreeter{})
+			})},
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			intercepted = false
+
+			// no address is bound,the gateway dials to the gRPC server in memory
+			opts := append([]Option{
+				WithEnableInProcessGateway(),
+				WithShutdownTimeout(time.Second),
+				WithUnaryInterceptor(countInterceptor),
+			}, c.opts...)
+			s := NewService("", opts...)
+			pb.RegisterGreeterServer(s.GRPCServer, &testGreeter{})
+			defer func() {
+				if err := s.Shutdown(context.Background()); err != nil {
+					t.Errorf("shutdown error: %v", err)
+				}
+			}()
+
+			handler, err := s.HTTPHandler()
+			if err != nil {
+				t.Fatalf("http handler error: %v", err)
+			}
+
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/v1/say/heige", nil))
+			if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), "hello,heige") {
+				t.Fatalf("gateway response code:%d body:%s", w.Code, w.Body.String())
+			}
+
+			if intercepted != c.intercepted {
+				t.Fatalf("intercepted = %v, want %v", intercepted, c.intercepted)
+			}
+		})
+	}
+}
+
+func TestDialInProcess(t *testing.T) {
+	s := NewService("", WithEnableInProcessGateway(), WithShutdownTimeout(time.Second))
+	pb.RegisterGreeterServer(s.GRPCServer, &testGreeter{})
+	defer s.Shutdown(context.Background())
+
+	conn, err := s.DialInProcess()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	ctx := metadata.AppendToOutgoingContext(context.Background(), "x-request-id", "in-process")
+	reply, err := pb.NewGreeterClient(conn).SayHello(ctx, &pb.HelloReq{Name: "heige"})
+	if err != nil {
+		t.Fatalf("say hello error: %v", err)
+	}
+	if reply.GetMessage() != "hello,heige" {
+		t.Fatalf("reply message = %s, want hello,heige", reply.GetMessage())
+	}
+}
+
+func TestInProcessGatewayRun(t *testing.T) {
+	address := freeAddress(t)
+	httpAddress := freeAddress(t)
+	s := NewService(
+		address,
+		WithGRPCHTTPAddress(httpAddress),
+		WithEnableInProcessGateway(),
+		WithShutdownTimeout(time.Second),
+		WithHandlerFromEndpoints(pb.RegisterGreeterHandlerFromEndpoint),
+	)
+	pb.RegisterGreeterServer(s.GRPCServer, &testGreeter{})
+	runService(t, s, address, httpAddress)
+
+	resp, err := http.Get("http://" + httpAddress + "/v1/say/heige")
+	if err != nil {
+		t.Fatalf("gateway request error: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("gateway response code = %d, want 200", resp.StatusCode)
+	}
+}
+
+func TestInProcessDisabled(t *testing.T) {
+	s := NewService("")
+	if _, err := s.HTTPHandler(); !errors.Is(err, ErrHTTPGatewayDisabled) {
+		t.Fatalf("http handler error = %v, want %v", err, ErrHTTPGatewayDisabled)
+	}
+	if _, err := s.DialInProcess(); !errors.Is(err, ErrInProcessGatewayDisabled) {
+		t.Fatalf("dial in-process error = %v, want %v", err, ErrInProcessGatewayDisabled)
+	}
+}
@@ -0,0 +1,356 @@
+package micro
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"google.golang.org/grpc"
+)
+
+// ListenerKind is the kind of server which serves a listener.
+type ListenerKind int
+
+const (
+	// ListenerGRPC the listener is served by the gRPC server.
+	ListenerGRPC ListenerKind = iota
+	// ListenerHTTP the listener is served by the gRPC http gateway handler.
+	ListenerHTTP
+	// ListenerShared the listener is served by both the gRPC server and the gRPC http gateway,
+	// just like the shared address.
+	ListenerShared
+)
+
+// String returns the listener kind name.
+func (k ListenerKind) String() string {
+	switch k {
+	case ListenerGRPC:
+		return "gRPC"
+	case ListenerHTTP:
+		return "http"
+	case ListenerShared:
+		return "shared"
+	default:
+		return "unknown"
+	}
+}
+
+// Listener is an extra listener served by the service,
+// eg: a unix socket for sidecars, an ipv6 listener or a listener from systemd socket activation.
+type Listener struct {
+	// Name is used in logs,default: network://address
+	Name string
+
+	// Kind is the kind of server which serves the listener,default: ListenerGRPC
+	Kind ListenerKind
+
+	// Network must be "tcp", "tcp4", "tcp6" or "unix",default: tcp
+	Network string
+
+	// Address eg: [::1]:50051, /var/run/hephfx.sock
+	Address string
+
+	// FileMode is the file mode of the unix socket,0 means the default mode
+	FileMode os.FileMode
+
+	// Listener is a pre-created listener,Network and Address are ignored when it is set
+	Listener net.Listener
+
+	// ShutdownTimeout the graceful shutdown timeout of the listener,default: the service shutdown timeout
+	ShutdownTimeout time.Duration
+}
+
+// extraListener is a bound extra listener.
+type extraListener struct {
+	Listener
+	httpServer *http.Server // the http server of ListenerHTTP and ListenerShared
+}
+
+// SystemdListeners returns the listeners passed by systemd socket activation,
+// the listener names are from LISTEN_FDNAMES.
+// The environment variables are unset,so the listeners are returned only once.
+// It returns nil when the process is not socket activated.
+//
+//	listeners, err := micro.SystemdListeners()
+//	if err != nil {
+//		log.Fatal(err)
+//	}
+//
+//	s := micro.NewService(address, micro.WithListeners(listeners...))
+func SystemdListeners() ([]Listener, error) {
+	defer func() {
+		_ = os.Unsetenv("LISTEN_PID")
+		_ = os.Unsetenv("LISTEN_FDS")
+		_ = os.Unsetenv("LISTEN_FDNAMES")
+	}()
+
+	pid, err := strconv.Atoi(os.Getenv("LISTEN_PID"))
+	if err != nil || pid != os.Getpid() {
+		return nil, nil
+	}
+
+	nfds, err := strconv.Atoi(os.Getenv("LISTEN_FDS"))
+	if err != nil || nfds <= 0 {
+		return nil, nil
+	}
+
+	var names []string
+	if fdNames := os.Getenv("LISTEN_FDNAMES"); fdNames != "" {
+		names = strings.Split(fdNames, ":")
+	}
+
+	return filesListeners(systemdListenFdsStart, nfds, names)
+}
+
+// systemdListenFdsStart the first file descriptor passed by systemd.
+const systemdListenFdsStart = 3
+
+// filesListeners returns the listeners of the inherited file descriptors.
+func filesListeners(start, n int, names []string) ([]Listener, error) {
+	listeners := make([]Listener, 0, n)
+	for i := 0; i < n; i++ {
+		fd := start + i
+		syscall.CloseOnExec(fd)
+
+		name := "fd:" + strconv.Itoa(fd)
+		if i < len(names) && names[i] != "" {
+			name = names[i]
+		}
+
+		f := os.NewFile(uintptr(fd), name)
+		ln, err := net.FileListener(f)
+		_ = f.Close()
+		if err != nil {
+			for _, l := range listeners {
+				_ = l.Listener.Close()
+			}
+
+			return nil, fmt.Errorf("listener %s from fd %d error: %w", name, fd, err)
+		}
+
+		listeners = append(listeners, Listener{Name: name, Listener: ln})
+	}
+
+	return listeners, nil
+}
+
+// startListeners binds and serves the extra listeners.
+// All the listeners are bound before serving,so a bind error does not leave any listener running.
+func (s *Service) startListeners() error {
+	if len(s.listeners) == 0 {
+		return nil
+	}
+
+	for _, l := range s.listeners {
+		if l.Kind != ListenerGRPC && s.mux == nil {
+			return fmt.Errorf("listener %s kind %s: %w", l.Name, l.Kind, ErrHTTPGatewayDisabled)
+		}
+	}
+
+	for _, l := range s.listeners {
+		el, err := s.listen(l)
+		if err != nil {
+			s.closeListeners()
+			return err
+		}
+
+		s.extraListeners = append(s.extraListeners, el)
+	}
+
+	for _, el := range s.extraListeners {
+		if el.Kind == ListenerGRPC {
+			continue
+		}
+
+		err := s.initGateway()
+		if err != nil {
+			s.closeListeners()
+			return err
+		}
+
+		el.httpServer = s.newListenerHTTPServer(el.Kind)
+	}
+
+	s.listenerErrChan = make(chan error, len(s.extraListeners))
+	for _, el := range s.extraListeners {
+		go func(el *extraListener) {
+			defer s.recovery()
+
+			s.logger.Printf("Starting %s listener %s\n", el.Kind, el.Name)
+			err := s.serveListener(el)
+			if err != nil && !errors.Is(err, http.ErrServerClosed) && !errors.Is(err, net.ErrClosed) {
+				s.listenerErrChan <- fmt.Errorf("serve %s listener %s error: %w", el.Kind, el.Name, err)
+			}
+		}(el)
+	}
+
+	return nil
+}
+
+// listen binds the extra listener.
+func (s *Service) listen(l Listener) (*extraListener, error) {
+	if l.Listener == nil {
+		if l.Network == "" {
+			l.Network = "tcp"
+		}
+
+		ln, err := s.bind(l.Network, l.Address)
+		if err != nil {
+			return nil, fmt.Errorf("listen %s %s error: %w", l.Network, l.Address, err)
+		}
+
+		if l.Network == "unix" && l.FileMode != 0 {
+			err = os.Chmod(l.Address, l.FileMode)
+			if err != nil {
+				_ = ln.Close()
+				return nil, fmt.Errorf("chmod unix socket %s error: %w", l.Address, err)
+			}
+		}
+
+		l.Listener = ln
+	}
+
+	if l.Name == "" {
+		l.Name = l.Listener.Addr().Network() + "://" + l.Listener.Addr().String()
+	}
+
+	return &extraListener{Listener: l}, nil
+}
+
+// removeStaleSocket removes the unix socket file left by a crashed process,
+// the socket which is still being listened on is kept.
+func removeStaleSocket(path string) {
+	info, err := os.Stat(path)
+	if err != nil || info.Mode()&os.ModeSocket == 0 {
+		return
+	}
+
+	conn, err := net.DialTimeout("unix", path, time.Second)
+	if err == nil {
+		_ = conn.Close()
+		return
+	}
+
+	_ = os.Remove(path)
+}
+
+// newListenerHTTPServer returns a http server for the extra listener,
+// it has the same timeouts as the gRPC http server.
+func (s *Service) newListenerHTTPServer(kind ListenerKind) *http.Server {
+	server := &http.Server{
+		ReadHeaderTimeout: 5 * time.Second,  // read header timeout
+		ReadTimeout:       5 * time.Second,  // read request timeout
+		WriteTimeout:      10 * time.Second, // write timeout
+		IdleTimeout:       20 * time.Second, // tcp idle time
+	}
+	if s.gRPCHTTPServer != nil {
+		server.ReadHeaderTimeout = s.gRPCHTTPServer.ReadHeaderTimeout
+		server.ReadTimeout = s.gRPCHTTPServer.ReadTimeout
+		server.WriteTimeout = s.gRPCHTTPServer.WriteTimeout
+		server.IdleTimeout = s.gRPCHTTPServer.IdleTimeout
+		server.MaxHeaderBytes = s.gRPCHTTPServer.MaxHeaderBytes
+	}
+
+	server.Handler = s.gatewayHandler
+	if kind == ListenerShared {
+		if s.tlsConfig != nil {
+			server.Handler = grpcHandler(s.GRPCServer, s.gatewayHandler, s.protocolHandlers()...)
+		} else {
+			server.Handler = h2cHandler(grpcHandler(s.GRPCServer, s.gatewayHandler, s.protocolHandlers()...))
+		}
+	}
+
+	if s.tlsConfig != nil {
+		server.TLSConfig = s.serverTLSConfig()
+	}
+
+	return server
+}
+
+// serveListener serves the extra listener until it is closed.
+// The gRPC listener is served by the gRPC server with its credentials.
+func (s *Service) serveListener(el *extraListener) error {
+	if el.httpServer == nil {
+		err := s.GRPCServer.Serve(el.Listener.Listener)
+		if errors.Is(err, grpc.ErrServerStopped) {
+			return nil
+		}
+
+		return err
+	}
+
+	if s.tlsConfig != nil {
+		return el.httpServer.ServeTLS(el.Listener.Listener, "", "")
+	}
+
+	return el.httpServer.Serve(el.Listener.Listener)
+}
+
+// shutdownListeners stops the extra listeners gracefully,each listener has its own shutdown timeout.
+// The gRPC listeners stop accepting new connections,
+// and the existing connections are drained by the gRPC server graceful stop.
+func (s *Service) shutdownListeners() {
+	var wg sync.WaitGroup
+	for _, el := range s.extraListeners {
+		wg.Add(1)
+		go func(el *extraListener) {
+			defer wg.Done()
+			defer s.recovery()
+
+			s.shutdownListener(el)
+		}(el)
+	}
+
+	wg.Wait()
+}
+
+func (s *Service) shutdownListener(el *extraListener) {
+	if el.httpServer == nil {
+		err := el.Listener.Listener.Close()
+		if err != nil && !errors.Is(err, net.ErrClosed) {
+			s.logger.Printf("close %s listener %s error: %v\n", el.Kind, el.Name, err)
+			return
+		}
+
+		s.logger.Printf("%s listener %s closed\n", el.Kind, el.Name)
+		return
+	}
+
+	timeout := el.ShutdownTimeout
+	if timeout <= 0 {
+		timeout = s.shutdownTimeout
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	// disable keep-alive on existing connections
+	el.httpServer.SetKeepAlivesEnabled(false)
+	err := el.httpServer.Shutdown(ctx)
+	if err != nil {
+		s.logger.Printf("%s listener %s shutdown error: %v\n", el.Kind, el.Name, err)
+		_ = el.httpServer.Close()
+		return
+	}
+
+	s.logger.Printf("%s listener %s shutdown success\n", el.Kind, el.Name)
+}
+
+// closeListeners closes the extra listeners immediately.
+func (s *Service) closeListeners() {
+	for _, el := range s.extraListeners {
+		if el.httpServer != nil {
+			_ = el.httpServer.Close()
+		}
+
+		_ = el.Listener.Listener.Close()
+	}
+}
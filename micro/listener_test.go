@@ -0,0 +1,200 @@
+package micro
+
+import (
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+
+	"github.com/daheige/hephfx/example/pb"
+)
+
+func unixHTTPClient(path string) *http.Client {
+	return &http.Client{Transport: &http.Transport{
+		DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+			var d net.Dialer
+			return d.DialContext(ctx, "unix", path)
+		},
+	}}
+}
+
+func sayHello(t *testing.T, target string) {
+	t.Helper()
+
+	conn, err := grpc.NewClient(target, grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	reply, err := pb.NewGreeterClient(conn).SayHello(context.Background(), &pb.HelloReq{Name: "heige"})
+	if err != nil {
+		t.Fatalf("%s say hello error: %v", target, err)
+	}
+	if reply.GetMessage() != "hello,heige" {
+		t.Fatalf("%s reply message = %s, want hello,heige", target, reply.GetMessage())
+	}
+}
+
+func httpSayHello(t *testing.T, client *http.Client, address string) {
+	t.Helper()
+
+	resp, err := client.Get("http://" + address + "/v1/say/heige")
+	if err != nil {
+		t.Fatalf("%s gateway request error: %v", address, err)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK || !strings.Contains(string(body), "hello,heige") {
+		t.Fatalf("%s gateway response code:%d body:%s", address, resp.StatusCode, body)
+	}
+}
+
+func TestWithListeners(t *testing.T) {
+	dir := t.TempDir()
+	grpcSock := filepath.Join(dir, "grpc.sock")
+	httpSock := filepath.Join(dir, "http.sock")
+
+	// a pre-created listener,eg: from systemd socket activation
+	sharedListener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	sharedAddress := sharedListener.Addr().String()
+
+	address := freeAddress(t)
+	httpAddress := freeAddress(t)
+	s := NewService(
+		address,
+		WithGRPCHTTPAddress(httpAddress),
+		WithShutdownTimeout(time.Second),
+		WithHandlerFromEndpoints(pb.RegisterGreeterHandlerFromEndpoint),
+		WithListeners(
+			Listener{Network: "unix", Address: grpcSock, FileMode: 0600},
+			Listener{Kind: ListenerHTTP, Network: "unix", Address: httpSock},
+			Listener{Name: "shared", Kind: ListenerShared, Listener: sharedListener},
+		),
+	)
+	pb.RegisterGreeterServer(s.GRPCServer, &testGreeter{})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	errChan := make(chan error, 1)
+	go func() {
+		errChan <- s.RunContext(ctx)
+	}()
+	waitListening(t, address)
+	waitListening(t, httpAddress)
+
+	info, err := os.Stat(grpcSock)
+	if err != nil {
+		t.Fatalf("stat unix socket error: %v", err)
+	}
+	if info.Mode().Perm() != 0600 {
+		t.Fatalf("unix socket file mode = %v, want 0600", info.Mode().Perm())
+	}
+
+	sayHello(t, "unix://"+grpcSock)
+	sayHello(t, sharedAddress)
+	httpSayHello(t, unixHTTPClient(httpSock), "unix")
+	httpSayHello(t, http.DefaultClient, sharedAddress)
+
+	cancel()
+	if err := <-errChan; err != nil {
+		t.Fatalf("run context error: %v", err)
+	}
+
+	for _, path := range []string{grpcSock, httpSock} {
+		if _, err := os.Stat(path); !os.IsNotExist(err) {
+			t.Fatalf("unix socket %s is not removed after shutdown", path)
+		}
+	}
+	if conn, err := net.Dial("tcp", sharedAddress); err == nil {
+		conn.Close()
+		t.Fatalf("shared listener is not closed after shutdown")
+	}
+}
+
+func TestWithListenersError(t *testing.T) {
+	// the http listener requires the http gateway
+	s := NewService(freeAddress(t), WithListeners(Listener{Kind: ListenerHTTP, Address: "127.0.0.1:0"}))
+	if err := s.RunContext(context.Background()); err == nil {
+		t.Fatalf("expected http gateway disabled error")
+	}
+
+	// a stale unix socket file is removed before listening
+	sock := filepath.Join(t.TempDir(), "stale.sock")
+	ln, err := net.Listen("unix", sock)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ln.(*net.UnixListener).SetUnlinkOnClose(false)
+	ln.Close()
+
+	address := freeAddress(t)
+	s = NewService(address, WithShutdownTimeout(time.Second), WithListeners(Listener{Network: "unix", Address: sock}))
+	pb.RegisterGreeterServer(s.GRPCServer, &testGreeter{})
+	runService(t, s, address)
+	sayHello(t, "unix://"+sock)
+
+	// the extra listeners are closed when the gRPC address is in use
+	busy, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer busy.Close()
+
+	sock = filepath.Join(t.TempDir(), "extra.sock")
+	s = NewService(busy.Addr().String(), WithListeners(Listener{Network: "unix", Address: sock}))
+	pb.RegisterGreeterServer(s.GRPCServer, &testGreeter{})
+	if err := s.RunContext(context.Background()); err == nil {
+		t.Fatalf("expected gRPC address in use error")
+	}
+	if conn, err := net.Dial("unix", sock); err == nil {
+		conn.Close()
+		t.Fatalf("extra listener %s is still serving after the gRPC bind error", sock)
+	}
+}
+
+func TestFilesListeners(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+
+	// the inherited file descriptor is a duplicate of the listener,
+	// it is owned by filesListeners,so f must not close it again.
+	f, err := ln.(*net.TCPListener).File()
+	if err != nil {
+		t.Fatal(err)
+	}
+	fd, err := syscall.Dup(int(f.Fd()))
+	f.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	listeners, err := filesListeners(fd, 1, []string{"grpc"})
+	if err != nil {
+		t.Fatalf("files listeners error: %v", err)
+	}
+	if len(listeners) != 1 || listeners[0].Name != "grpc" {
+		t.Fatalf("unexpected listeners: %v", listeners)
+	}
+	defer listeners[0].Listener.Close()
+
+	if got := listeners[0].Listener.Addr().String(); got != ln.Addr().String() {
+		t.Fatalf("listener address = %s, want %s", got, ln.Addr().String())
+	}
+}
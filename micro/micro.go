@@ -2,6 +2,7 @@ package micro
 
 import (
 	"context"
+	"crypto/tls"
 	"errors"
 	"fmt"
 	"net"
@@ -10,27 +11,36 @@ import (
 	"os/signal"
 	"runtime/debug"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	gPrometheus "github.com/grpc-ecosystem/go-grpc-middleware/providers/prometheus"
 	gRecovery "github.com/grpc-ecosystem/go-grpc-middleware/v2/interceptors/recovery"
-	gValidator "github.com/grpc-ecosystem/go-grpc-middleware/v2/interceptors/validator"
 	gRuntime "github.com/grpc-ecosystem/grpc-gateway/v2/runtime"
 	"github.com/prometheus/client_golang/prometheus"
 	"golang.org/x/net/http2"
 	"golang.org/x/net/http2/h2c"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
 	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/health"
 	"google.golang.org/grpc/metadata"
 	"google.golang.org/grpc/reflection"
 	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
 	"google.golang.org/protobuf/encoding/protojson"
 
 	"github.com/daheige/hephfx/ctxkeys"
 	"github.com/daheige/hephfx/gutils"
+	"github.com/daheige/hephfx/hestia"
+	"github.com/daheige/hephfx/micro/gerrors"
 )
 
+// ErrServiceRunning is returned when the service is already running.
+var ErrServiceRunning = errors.New("service is already running")
+
 // HandlerFromEndpoint is the callback that the caller should implement
 // to steps to reverse-proxy the HTTP/1 requests to gRPC
 // handlerFromEndpoint http gw endPoint
@@ -41,6 +51,9 @@ type HandlerFromEndpoint func(ctx context.Context, mux *gRuntime.ServeMux,
 // HTTPHandlerFunc is the http middleware handler function.
 type HTTPHandlerFunc func(*gRuntime.ServeMux) http.Handler
 
+// HTTPMiddleware wraps the http handler.
+type HTTPMiddleware func(http.Handler) http.Handler
+
 // AnnotatorFunc is the annotator function is for injecting metadata from http request into gRPC context
 type AnnotatorFunc func(context.Context, *http.Request) metadata.MD
 
@@ -51,7 +64,7 @@ type Service struct {
 	gRPCAddress         string                         // grpc service address,eg:ip:port
 	gRPCNetwork         string                         // the gRPC network must be "tcp", "tcp4", "tcp6"
 	recovery            func()                         // goroutine exec recover catch stack
-	shutdownFunc        func()                         // exec shutdown func after service exit
+	shutdownHooks       []ShutdownHook                 // exec shutdown hooks in phases when service exit
 	shutdownTimeout     time.Duration                  // shutdown wait time,default:5s
 	interruptSignals    []os.Signal                    // interrupt signal
 	streamInterceptors  []grpc.StreamServerInterceptor // gRPC steam interceptor
@@ -63,28 +76,88 @@ type Service struct {
 	// note: it needs to be used with the validator-gen plugin,
 	// for specific usage, refer to the example.
 	enableRequestValidator bool
+	validatorOptions       []ValidatorOption
 
 	enablePrometheus     bool // gRPC prometheus monitor
 	serverMetricsOptions []gPrometheus.ServerMetricsOption
 
+	enableHealthCheck bool           // register grpc.health.v1.Health service
+	healthServer      *health.Server // gRPC health server
+
+	// service registry
+	registry            hestia.Registry   // register service instances when listeners are bound
+	registryService     *hestia.Service   // the service instance template
+	registeredInstances []*hestia.Service // registered service instances
+	registryMu          sync.Mutex
+
 	logger Logger // logger interface entry
 
 	// gRPC HTTP gateway settings
 	enableHTTPGateway bool // enable http gateway,default:false
 	// note:If you need to start the HTTP gateway service, you must set this parameter
 	handlerFromEndpoints []HandlerFromEndpoint // http gw endpoint
+	handlerServers       []HandlerServer       // http gw handlers which call the gRPC service directly
 
 	mux                     *gRuntime.ServeMux        // gRPC http gateway runtime ServeMux
 	muxOptions              []gRuntime.ServeMuxOption // gRPC HTTP server mux options
 	enableDefaultProtoJSON  bool                      // gRPC HTTP proto to json mux option,default:true
 	gRPCEndpointDialOptions []grpc.DialOption         // gRPC http gateway DialOption
 	routes                  []Route                   // gRPC http custom router rules
+	routeInfos              []RouteInfo               // the registered routes shown in the route list
+	enableDebugRoutes       bool                      // list the registered routes on /debug/routes
+	openAPI                 *openAPI                  // serve the merged OpenAPI documents
 	gRPCHTTPServer          *http.Server              // gRPC http server
 	gRPCHTTPAddress         string                    // gRPC http gateway address,eg:0.0.0.0:8080
 	gRPCHTTPHandler         HTTPHandlerFunc
 	gRPCHTTPErrorHandler    gRuntime.ErrorHandlerFunc // gRPC http gateway error handler
 	enableGRPCShareAddress  bool                      // gRPC server and gRPC http gateway start on one port
+	enableGRPCWeb           bool                      // serve the gRPC-Web requests on the shared port
+	grpcWebOptions          []GRPCWebOption           // the gRPC-Web options of the shared port
+	enableConnect           bool                      // serve the Connect requests on the shared port
+	connectOptions          []ConnectOption           // the Connect options of the shared port
 	annotators              []AnnotatorFunc           // for injecting metadata from http request into gRPC context
+	gatewayOnce             sync.Once                 // register the gateway handlers once
+	gatewayErr              error                     // the error of registering the gateway handlers
+	gatewayHandler          http.Handler              // the gateway handler which serves all the http listeners
+	gatewayMiddlewares      []HTTPMiddleware          // wrap the gateway handler,the first one is the outermost
+
+	// extra listeners
+	listeners       []Listener       // extra listeners served by the gRPC server or gateway handler
+	extraListeners  []*extraListener // bound extra listeners
+	listenerErrChan chan error       // receive the serve errors of extra listeners
+	boundListeners  []boundListener  // the listeners bound by the service
+	boundMu         sync.Mutex
+
+	// graceful restart settings
+	enableGracefulRestart bool          // restart gracefully when the restart signal is received
+	restartTimeout        time.Duration // the timeout of waiting for the new process to be ready
+	restartArgs           []string      // the arguments of the new process,default: os.Args[1:]
+	restarting            atomic.Bool   // whether the service is restarting
+	restarted             atomic.Bool   // whether the new process is ready
+
+	// in-process gateway settings
+	enableInProcessGateway bool              // the gateway dials to gRPC server over an in-memory listener
+	inProcessListener      *bufconn.Listener // in-memory listener of gRPC server
+	inProcessOnce          sync.Once
+	inProcessServing       atomic.Bool // whether the gRPC server serves on the in-process listener
+
+	// service lifecycle
+	running     atomic.Bool   // whether the service is running
+	quit        chan struct{} // closed when Shutdown is called
+	quitOnce    sync.Once
+	stopped     chan struct{} // closed when the service has stopped
+	stoppedOnce sync.Once
+	stopErr     error // the error returned by RunContext
+	initErr     error // the error of init service,eg: load tls certificate error
+
+	// tls settings
+	tlsConfig         *tls.Config      // tls config of gRPC server and gRPC http gateway
+	tlsCertFile       string           // server certificate file
+	tlsKeyFile        string           // server private key file
+	tlsClientCAFile   string           // client CA file,mTLS is enabled when it is set
+	tlsReloadInterval time.Duration    // the interval of checking certificate files change
+	gatewayCert       *tls.Certificate // client certificate of the internal gateway dial when mTLS is enabled
+	certReloader      *certReloader    // certificate files reloader
 }
 
 // NewService create a grpc service instance
@@ -99,8 +172,8 @@ func NewService(address string, opts ...Option) *Service {
 
 	// install validator interceptor.
 	if s.enableRequestValidator {
-		s.streamInterceptors = append(s.streamInterceptors, gValidator.StreamServerInterceptor())
-		s.unaryInterceptors = append(s.unaryInterceptors, gValidator.UnaryServerInterceptor())
+		s.streamInterceptors = append(s.streamInterceptors, ValidatorStreamInterceptor(s.validatorOptions...))
+		s.unaryInterceptors = append(s.unaryInterceptors, ValidatorUnaryInterceptor(s.validatorOptions...))
 	}
 
 	// install request interceptor
@@ -120,19 +193,6 @@ func NewService(address string, opts ...Option) *Service {
 		s.unaryInterceptors = append(s.unaryInterceptors, serverMetrics.UnaryServerInterceptor())
 	}
 
-	// gRPC server options
-	s.serverOptions = append(s.serverOptions,
-		grpc.ChainStreamInterceptor(s.streamInterceptors...),
-		grpc.ChainUnaryInterceptor(s.unaryInterceptors...),
-	)
-
-	s.GRPCServer = grpc.NewServer(
-		s.serverOptions...,
-	)
-
-	// register reflection service on gRPC server.
-	reflection.Register(s.GRPCServer)
-
 	// check if the service is started at the same address
 	if s.gRPCHTTPAddress != "" {
 		if s.gRPCAddress == s.gRPCHTTPAddress {
@@ -160,8 +220,55 @@ func NewService(address string, opts ...Option) *Service {
 		}
 	}
 
+	// init tls config,the gRPC server uses tls credentials when it has its own listener,
+	// otherwise the tls is handled by the http server on the shared port.
+	s.initErr = s.initTLSConfig()
+	if s.tlsConfig != nil && !s.enableGRPCShareAddress {
+		s.serverOptions = append(s.serverOptions, grpc.Creds(credentials.NewTLS(s.withReloadedClientCAs(s.tlsConfig.Clone()))))
+	}
+
+	// gRPC server options
+	s.serverOptions = append(s.serverOptions,
+		grpc.ChainStreamInterceptor(s.streamInterceptors...),
+		grpc.ChainUnaryInterceptor(s.unaryInterceptors...),
+	)
+
+	s.GRPCServer = grpc.NewServer(
+		s.serverOptions...,
+	)
+
+	// register reflection service on gRPC server.
+	reflection.Register(s.GRPCServer)
+
+	// register health service on gRPC server.
+	if s.enableHealthCheck {
+		s.registerHealthServer()
+	}
+
+	// deregister the service instances before the servers stop accepting requests
+	if s.registry != nil {
+		s.shutdownHooks = append([]ShutdownHook{{
+			Name:  "registry-deregister",
+			Phase: PhasePreDrain,
+			Fn:    s.deregisterInstances,
+		}}, s.shutdownHooks...)
+	}
+
+	// the in-process gateway can be used without http gateway address by HTTPHandler
+	if s.enableInProcessGateway {
+		s.inProcessListener = bufconn.Listen(defaultInProcessBufferSize)
+	}
+
 	// grpc http gateway default config
-	if s.enableHTTPGateway {
+	if s.enableHTTPGateway || s.enableInProcessGateway {
+		// the gateway dials to the gRPC server with matching tls credentials
+		if s.tlsConfig != nil && s.gatewayUsesTLS() {
+			s.gRPCEndpointDialOptions = append(
+				s.gRPCEndpointDialOptions,
+				grpc.WithTransportCredentials(credentials.NewTLS(s.gatewayTLSConfig())),
+			)
+		}
+
 		// default dial option is using insecure connection
 		if len(s.gRPCEndpointDialOptions) == 0 {
 			// Deprecated: use WithTransportCredentials and insecure.NewCredentials()
@@ -174,9 +281,9 @@ func NewService(address string, opts ...Option) *Service {
 			)
 		}
 
-		// default grpc http gateway handler error
+		// default grpc http gateway handler error,the status details are rendered as json
 		if s.gRPCHTTPErrorHandler == nil {
-			s.gRPCHTTPErrorHandler = gRuntime.DefaultHTTPErrorHandler
+			s.gRPCHTTPErrorHandler = gerrors.HTTPErrorHandler
 		}
 
 		// init gateway mux
@@ -204,27 +311,139 @@ func NewService(address string, opts ...Option) *Service {
 	return s
 }
 
-// Run start service
+// Run starts the service and blocks until one of the interrupt signals is received
+// or Shutdown is called.
 func (s *Service) Run() error {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	// the nil channels block forever when the signals are not intercepted
+	var sigChan, restartChan chan os.Signal
+	if len(s.interruptSignals) > 0 {
+		// intercept interrupt signals
+		sigChan = make(chan os.Signal, 1)
+		signal.Notify(sigChan, s.interruptSignals...)
+		defer signal.Stop(sigChan)
+	}
+
+	if s.enableGracefulRestart {
+		restartChan = make(chan os.Signal, 1)
+		signal.Notify(restartChan, restartSignal)
+		defer signal.Stop(restartChan)
+	}
+
+	if sigChan != nil || restartChan != nil {
+		go func() {
+			defer s.recovery()
+
+			for {
+				select {
+				case sig := <-sigChan: // Block until we receive our signal.
+					s.logger.Printf("interrupt signal received: %v\n", sig)
+					cancel()
+					return
+				case sig := <-restartChan:
+					s.logger.Printf("restart signal received: %v\n", sig)
+					s.restart(ctx)
+				case <-ctx.Done():
+					return
+				}
+			}
+		}()
+	}
+
+	return s.RunContext(ctx)
+}
+
+// RunContext starts the service and blocks until ctx is done or Shutdown is called,
+// then the service is stopped gracefully.
+// Unlike Run, it does not intercept any interrupt signals.
+func (s *Service) RunContext(ctx context.Context) (err error) {
+	if !s.running.CompareAndSwap(false, true) {
+		return ErrServiceRunning
+	}
+	defer s.stoppedOnce.Do(func() {
+		s.stopErr = err
+		close(s.stopped)
+	})
+
+	if s.initErr != nil {
+		return s.initErr
+	}
+
+	// the in-process listener is served along with the other listeners
+	s.serveInProcess()
+
+	// bind and serve the extra listeners,they are closed if the main listeners fail to bind
+	err = s.startListeners()
+	if err != nil {
+		return err
+	}
+
 	// start gRPC server and gRPC http gateway server on one port
 	if s.enableGRPCShareAddress {
-		return s.startUseOneAddress()
+		return s.startUseOneAddress(ctx)
 	}
 
 	// only start gRPC server
 	if !s.enableHTTPGateway {
-		return s.startGRPCService()
+		return s.startGRPCService(ctx)
 	}
 
 	// start grpc and http gateway server on different port
-	return s.startTwoServices()
+	return s.startTwoServices(ctx)
+}
+
+// Shutdown asks the running service to stop gracefully and waits until it has stopped
+// or ctx is done.
+// It returns the same error as RunContext,eg: the shutdown hooks errors.
+// It is safe to call Shutdown from any goroutine and more than once.
+func (s *Service) Shutdown(ctx context.Context) error {
+	s.quitOnce.Do(func() { close(s.quit) })
+	if !s.running.Load() {
+		// the gRPC server may be serving on the in-process listener only
+		if s.inProcessServing.Load() {
+			s.gracefulStopGRPCServer()
+		}
+
+		return nil
+	}
+
+	select {
+	case <-s.stopped:
+		return s.stopErr
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// wait blocks until a server fails to start, ctx is done or Shutdown is called.
+// It returns the start error if the server fails to start.
+func (s *Service) wait(ctx context.Context, errChan <-chan error) error {
+	select {
+	case err := <-errChan:
+		s.closeListeners()
+		return err
+	case err := <-s.listenerErrChan:
+		s.closeListeners()
+		return err
+	case <-ctx.Done():
+		s.logger.Printf("service context done: %v\n", ctx.Err())
+		return nil
+	case <-s.quit:
+		s.logger.Printf("service shutdown called\n")
+		return nil
+	}
 }
 
 // startGRPCService start gRPC service
-func (s *Service) startGRPCService() error {
-	// intercept interrupt signals
-	sigChan := make(chan os.Signal, 1)
-	signal.Notify(sigChan, s.interruptSignals...)
+func (s *Service) startGRPCService(ctx context.Context) error {
+	listener, err := s.listenGRPC()
+	if err != nil {
+		s.closeListeners()
+		return err
+	}
+
 	// channels to receive error
 	errChan := make(chan error, 1)
 
@@ -233,23 +452,23 @@ func (s *Service) startGRPCService() error {
 		defer s.recovery()
 
 		s.logger.Printf("start gRPC server listening on %s\n", s.gRPCAddress)
-		errChan <- s.startGRPCServer()
+		errChan <- s.GRPCServer.Serve(listener)
 	}()
 
-	select {
-	case err := <-errChan:
+	// all the listeners are bound
+	s.notifyReady()
+	if err := s.wait(ctx, errChan); err != nil {
 		return err
-	case sig := <-sigChan: // Block until we receive our signal.
-		s.logger.Printf("interrupt signal received: %v\n", sig)
-		s.stopGRPCServer()
-		return nil
 	}
+
+	return s.stopGRPCServer()
 }
 
 // startTwoServices starts the microservice with listening on the ports
 // start gRPC server and gRPC http gateway server on different port
-func (s *Service) startTwoServices() error {
+func (s *Service) startTwoServices(ctx context.Context) error {
 	if s.gRPCHTTPAddress == s.gRPCAddress {
+		s.closeListeners()
 		return errors.New("gRPC server and gRPC http gateway address are the same")
 	}
 
@@ -263,20 +482,28 @@ func (s *Service) startTwoServices() error {
 		}
 	}
 
-	// intercept interrupt signals
-	sigChan := make(chan os.Signal, 1)
-	signal.Notify(sigChan, s.interruptSignals...)
+	grpcListener, err := s.listenGRPC()
+	if err != nil {
+		s.closeListeners()
+		return err
+	}
+
+	httpListener, err := s.listenGRPCGateway()
+	if err != nil {
+		_ = grpcListener.Close()
+		s.closeListeners()
+		return errors.Join(err, s.deregisterInstances(context.Background()))
+	}
 
 	// channels to receive error
-	errChan1 := make(chan error, 1)
-	errChan2 := make(chan error, 1)
+	errChan := make(chan error, 2)
 
 	// start gRPC server
 	go func() {
 		defer s.recovery()
 
 		s.logger.Printf("Starting gRPC server listening on %s\n", s.gRPCAddress)
-		errChan1 <- s.startGRPCServer()
+		errChan <- s.GRPCServer.Serve(grpcListener)
 	}()
 
 	// start gRPC HTTP gateway server
@@ -284,44 +511,50 @@ func (s *Service) startTwoServices() error {
 		defer s.recovery()
 
 		s.logger.Printf("Starting gRPC http gateway server listening on: %s\n", s.gRPCHTTPAddress)
-		errChan2 <- s.startGRPCGateway()
+		errChan <- s.serveHTTP(httpListener)
 	}()
 
+	// all the listeners are bound
+	s.notifyReady()
+
 	// wait for context cancellation or shutdown signal
-	select {
-	// if gRPC server fail to start
-	case err := <-errChan1:
+	// if gRPC server or http server fail to start, return the error
+	if err := s.wait(ctx, errChan); err != nil {
 		return err
-	// if http server fail to start
-	case err := <-errChan2:
-		return err
-	// if we received an interrupt signal
-	case sig := <-sigChan:
-		s.logger.Printf("Interrupt signal received: %v\n", sig)
-		s.stopTwoServices()
-		return nil
 	}
+
+	return s.stopTwoServices()
 }
 
 // stops the microservice gracefully.
-func (s *Service) stopTwoServices() {
+func (s *Service) stopTwoServices() error {
+	s.shutdownHealthServer()
+	errs := []error{s.runShutdownHooks(PhasePreDrain)}
+
 	// disable keep-alive on existing connections
 	s.gRPCHTTPServer.SetKeepAlivesEnabled(false)
 
-	// gracefully stop http server
+	// gracefully stop http server and the extra listeners
 	s.httpServerShutdown()
+	s.shutdownListeners()
+	errs = append(errs, s.runShutdownHooks(PhaseAfterHTTP))
 
 	// gracefully stop gRPC server
-	s.GRPCServer.GracefulStop()
+	s.gracefulStopGRPCServer()
 
-	s.shutdownFunc() // exec shutdown function
+	// exec shutdown hooks
+	errs = append(errs, s.runShutdownHooks(PhaseAfterGRPC), s.runShutdownHooks(PhaseFinal))
+	return errors.Join(errs...)
 }
 
 // start gRPC service and gRPC http gateway on one address
-func (s *Service) startUseOneAddress() error {
-	// intercept interrupt signals
-	sigChan := make(chan os.Signal, 1)
-	signal.Notify(sigChan, s.interruptSignals...)
+func (s *Service) startUseOneAddress(ctx context.Context) error {
+	listener, err := s.listenGRPCAndHTTP()
+	if err != nil {
+		s.closeListeners()
+		return err
+	}
+
 	// channels to receive error
 	errChan := make(chan error, 1)
 
@@ -330,50 +563,86 @@ func (s *Service) startUseOneAddress() error {
 		defer s.recovery()
 
 		s.logger.Printf("Starting gRPC http gateway and gRPC server listening on: %s\n", s.gRPCHTTPAddress)
-		errChan <- s.startGRPCAndHTTPServer()
+		errChan <- s.serveHTTP(listener)
 	}()
 
-	select {
-	case err := <-errChan:
+	// all the listeners are bound
+	s.notifyReady()
+	if err := s.wait(ctx, errChan); err != nil {
 		return err
-	case sig := <-sigChan: // Block until we receive our signal.
-		s.logger.Printf("interrupt signal received: %v\n", sig)
-		s.stopGRPCAndHTTPServer()
-		return nil
 	}
+
+	return s.stopGRPCAndHTTPServer()
 }
 
-func (s *Service) startGRPCAndHTTPServer() error {
+// listenGRPCAndHTTP binds the listener of gRPC server and gRPC http gateway on one address,
+// and registers the gRPC service instance.
+func (s *Service) listenGRPCAndHTTP() (net.Listener, error) {
 	if s.gRPCAddress == "" {
-		return fmt.Errorf("gRPC address is required")
+		return nil, fmt.Errorf("gRPC address is required")
 	}
 
 	s.gRPCHTTPAddress = s.gRPCAddress
-	err := s.registerGRPCHTTPEndpoints()
+	err := s.initGateway()
 	if err != nil {
-		return err
+		return nil, err
 	}
 
 	// create an http mux
-	otherHandler := s.gRPCHTTPHandler(s.mux)
+	otherHandler := s.gatewayHandler
+
+	s.gRPCHTTPServer.Addr = s.gRPCHTTPAddress
+	listener, err := s.bind("tcp", s.gRPCHTTPServer.Addr)
+	if err != nil {
+		return nil, err
+	}
+
+	// register the service after the listener is bound
+	err = s.registerInstance(listener.Addr(), hestia.ProtocolGRPC)
+	if err != nil {
+		_ = listener.Close()
+		return nil, err
+	}
+
+	// HTTP/2 is negotiated by ALPN on tls connections
+	if s.tlsConfig != nil {
+		s.gRPCHTTPServer.Handler = grpcHandler(s.GRPCServer, otherHandler, s.protocolHandlers()...)
+		s.gRPCHTTPServer.TLSConfig = s.serverTLSConfig()
+		return listener, nil
+	}
 
 	// convert HTTP requests to http2
-	s.gRPCHTTPServer.Handler = GRPCHandlerFunc(s.GRPCServer, otherHandler)
-	return s.gRPCHTTPServer.ListenAndServe()
+	s.gRPCHTTPServer.Handler = h2cHandler(grpcHandler(s.GRPCServer, otherHandler, s.protocolHandlers()...))
+	return listener, nil
 }
 
-func (s *Service) stopGRPCAndHTTPServer() {
+// serveHTTP serves the gRPC http server on the listener.
+func (s *Service) serveHTTP(listener net.Listener) error {
+	if s.tlsConfig != nil {
+		return s.gRPCHTTPServer.ServeTLS(listener, "", "")
+	}
+
+	return s.gRPCHTTPServer.Serve(listener)
+}
+
+func (s *Service) stopGRPCAndHTTPServer() error {
+	s.shutdownHealthServer()
+	errs := []error{s.runShutdownHooks(PhasePreDrain)}
+
 	// disable keep-alive on existing connections
 	s.gRPCHTTPServer.SetKeepAlivesEnabled(false)
 
-	// gracefully stop http server
+	// gracefully stop http server and the extra listeners
 	s.httpServerShutdown()
+	s.shutdownListeners()
+	errs = append(errs, s.runShutdownHooks(PhaseAfterHTTP))
 
 	// gracefully stop gRPC server
-	s.GRPCServer.GracefulStop()
+	s.gracefulStopGRPCServer()
 
-	// exec shutdown function
-	s.shutdownFunc()
+	// exec shutdown hooks
+	errs = append(errs, s.runShutdownHooks(PhaseAfterGRPC), s.runShutdownHooks(PhaseFinal))
+	return errors.Join(errs...)
 }
 
 // httpServerShutdown http gateway server graceful shutdown.
@@ -460,13 +729,31 @@ func (s *Service) GetPid() int {
 	return os.Getpid()
 }
 
-// stopServer stop the gRPC server gracefully
-func (s *Service) stopGRPCServer() {
+// stopGRPCServer stop the gRPC server gracefully
+func (s *Service) stopGRPCServer() error {
+	s.shutdownHealthServer()
+	errs := []error{s.runShutdownHooks(PhasePreDrain)}
+
+	// there is no http server,but the extra listeners may serve the gateway
+	// and the after-http hooks still run in order
+	s.shutdownListeners()
+	errs = append(errs, s.runShutdownHooks(PhaseAfterHTTP))
+
+	// graceful exit current service
+	s.gracefulStopGRPCServer()
+
+	// exec shutdown hooks
+	errs = append(errs, s.runShutdownHooks(PhaseAfterGRPC), s.runShutdownHooks(PhaseFinal))
+	return errors.Join(errs...)
+}
+
+// gracefulStopGRPCServer stops the gRPC server gracefully,
+// the server is stopped forcibly when the shutdown timeout is reached.
+func (s *Service) gracefulStopGRPCServer() {
 	done := make(chan struct{}, 1)
 	ctx, cancel := context.WithTimeout(context.Background(), s.shutdownTimeout)
 	defer cancel()
 
-	// graceful exit current service
 	go func() {
 		defer func() {
 			s.recovery()
@@ -475,9 +762,6 @@ func (s *Service) stopGRPCServer() {
 
 		// stop grpc server
 		s.GRPCServer.GracefulStop()
-
-		// exec shutdown function
-		s.shutdownFunc()
 	}()
 
 	select {
@@ -485,56 +769,93 @@ func (s *Service) stopGRPCServer() {
 		s.logger.Printf("stop gRPC server done\n")
 	case <-ctx.Done():
 		s.logger.Printf("stop gRPC server context timeout\n")
+		s.GRPCServer.Stop()
 	}
 
 	s.logger.Printf("gRPC server shutdown success")
 }
 
-// startGRPCServer start grpc server only.
-func (s *Service) startGRPCServer() error {
+// listenGRPC binds the gRPC listener and registers the gRPC service instance.
+func (s *Service) listenGRPC() (net.Listener, error) {
 	if s.gRPCNetwork == "" {
 		s.gRPCNetwork = "tcp"
 	}
 
-	listener, err := net.Listen(s.gRPCNetwork, s.gRPCAddress)
+	listener, err := s.bind(s.gRPCNetwork, s.gRPCAddress)
 	if err != nil {
-		return err
+		return nil, err
+	}
+
+	// register the service after the gRPC listener is bound
+	err = s.registerInstance(listener.Addr(), hestia.ProtocolGRPC)
+	if err != nil {
+		_ = listener.Close()
+		return nil, err
 	}
 
-	return s.GRPCServer.Serve(listener)
+	return listener, nil
 }
 
 // register gRPC http gateway handlerFromEndpoint
 func (s *Service) registerGRPCHTTPEndpoints() error {
 	ctx := context.Background()
 	var err error
+	endpoint, dialOptions := s.gatewayEndpoint()
 	for _, h := range s.handlerFromEndpoints {
-		err = h(ctx, s.mux, s.gRPCAddress, s.gRPCEndpointDialOptions)
+		err = h(ctx, s.mux, endpoint, dialOptions)
 		if err != nil {
 			s.logger.Printf("register handler from endPoint error: %s\n", err.Error())
 			return err
 		}
 	}
 
+	for _, h := range s.handlerServers {
+		err = h(ctx, s.mux)
+		if err != nil {
+			s.logger.Printf("register handler server error: %s\n", err.Error())
+			return err
+		}
+	}
+
+	// mirror gRPC health server on http gateway
+	if s.healthServer != nil {
+		err = s.registerHealthRoutes()
+		if err != nil {
+			return err
+		}
+	}
+
 	// apply routes
-	err = s.applyRoutes()
+	return s.applyRoutes()
+}
+
+// listenGRPCGateway binds the gRPC http gateway listener and registers the http service instance.
+func (s *Service) listenGRPCGateway() (net.Listener, error) {
+	err := s.initGateway()
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	// http server
 	s.gRPCHTTPServer.Addr = s.gRPCHTTPAddress
-	return nil
-}
+	s.gRPCHTTPServer.Handler = s.gatewayHandler
 
-func (s *Service) startGRPCGateway() error {
-	err := s.registerGRPCHTTPEndpoints()
+	listener, err := s.bind("tcp", s.gRPCHTTPServer.Addr)
 	if err != nil {
-		return err
+		return nil, err
+	}
+
+	// register the http gateway as a second service instance
+	err = s.registerInstance(listener.Addr(), hestia.ProtocolHTTP)
+	if err != nil {
+		_ = listener.Close()
+		return nil, err
+	}
+
+	if s.tlsConfig != nil {
+		s.gRPCHTTPServer.TLSConfig = s.serverTLSConfig()
 	}
 
-	s.gRPCHTTPServer.Handler = s.gRPCHTTPHandler(s.mux)
-	return s.gRPCHTTPServer.ListenAndServe()
+	return listener, nil
 }
 
 func (s *Service) applyRoutes() error {
@@ -544,10 +865,13 @@ func (s *Service) applyRoutes() error {
 			route.Path = "/" + route.Path
 		}
 
-		handler := route.Handler
-		err := s.mux.HandlePath(route.Method, route.Path, func(w http.ResponseWriter, r *http.Request, _ map[string]string) {
-			handler(w, r)
-		})
+		if route.Handler == nil {
+			s.logger.Printf("add http router error:%s,current method:%s path:%s invalid",
+				ErrRouteHandlerNil.Error(), route.Method, route.Path)
+			return ErrRouteHandlerNil
+		}
+
+		err := s.handlePath(route.Method, route.Path, route.Name, routeHandler(route))
 		if err != nil {
 			s.logger.Printf("add http router error:%s,current method:%s path:%s invalid", err.Error(),
 				route.Method, route.Path)
@@ -555,6 +879,18 @@ func (s *Service) applyRoutes() error {
 		}
 	}
 
+	if s.openAPI != nil {
+		err := s.registerOpenAPI()
+		if err != nil {
+			s.logger.Printf("register openapi documents error:%s", err.Error())
+			return err
+		}
+	}
+
+	if s.enableDebugRoutes {
+		return s.registerDebugRoutes()
+	}
+
 	return nil
 }
 
@@ -580,17 +916,17 @@ func defaultService() *Service {
 	s := &Service{
 		gRPCNetwork:            "tcp",
 		shutdownTimeout:        5 * time.Second,
+		restartTimeout:         defaultRestartTimeout,
 		interruptSignals:       interruptSignals,
 		streamInterceptors:     make([]grpc.StreamServerInterceptor, 0, 20),
 		unaryInterceptors:      make([]grpc.UnaryServerInterceptor, 0, 20),
 		serverOptions:          make([]grpc.ServerOption, 0, 8),
 		logger:                 dummyLogger,
 		enableDefaultProtoJSON: true,
+		quit:                   make(chan struct{}),
+		stopped:                make(chan struct{}),
 	}
 
-	// default shutdown function
-	s.shutdownFunc = func() {}
-
 	// goroutine recover catch stack
 	s.recovery = func() {
 		defer func() {
@@ -628,11 +964,53 @@ func defaultGRPCHTTPHandler(mux *gRuntime.ServeMux) http.Handler {
 // If a request is a h2c connection, it's hijacked and redirected to
 // s.ServeConn. Otherwise, the returned Handler just forwards requests to http.
 func GRPCHandlerFunc(grpcServer *grpc.Server, otherHandler http.Handler) http.Handler {
-	return h2c.NewHandler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+	return h2cHandler(grpcHandler(grpcServer, otherHandler))
+}
+
+// h2cHandler serves the HTTP/2 requests without tls and the HTTP/1 requests by h.
+func h2cHandler(h http.Handler) http.Handler {
+	return h2c.NewHandler(h, &http2.Server{})
+}
+
+// protocolHandler serves the requests of the other protocol by the gRPC server,eg: gRPC-Web and Connect.
+type protocolHandler interface {
+	http.Handler
+
+	// match reports whether the request is served by the handler
+	match(r *http.Request) bool
+}
+
+// grpcHandler dispatches the gRPC requests and the requests matched by the protocols to grpcServer,
+// other requests to otherHandler.
+// It is used directly on tls connections,where HTTP/2 is negotiated by ALPN.
+func grpcHandler(grpcServer *grpc.Server, otherHandler http.Handler, protocols ...protocolHandler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		for _, p := range protocols {
+			if p.match(r) {
+				p.ServeHTTP(w, r)
+				return
+			}
+		}
+
 		if r.ProtoMajor >= 2 && strings.Contains(r.Header.Get("Content-Type"), "application/grpc") {
 			grpcServer.ServeHTTP(w, r)
-		} else {
-			otherHandler.ServeHTTP(w, r)
+			return
 		}
-	}), &http2.Server{})
+
+		otherHandler.ServeHTTP(w, r)
+	})
+}
+
+// protocolHandlers returns the handlers of the protocols served on the shared port besides gRPC.
+func (s *Service) protocolHandlers() []protocolHandler {
+	var protocols []protocolHandler
+	if s.enableGRPCWeb {
+		protocols = append(protocols, newGRPCWeb(s.GRPCServer, s.grpcWebOptions...))
+	}
+
+	if s.enableConnect {
+		protocols = append(protocols, newConnectHandler(s.GRPCServer, s.connectOptions...))
+	}
+
+	return protocols
 }
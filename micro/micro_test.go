@@ -0,0 +1,151 @@
+package micro
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+)
+
+// freeAddress returns a local address which is free to listen on.
+func freeAddress(t *testing.T) string {
+	t.Helper()
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen free address error: %v", err)
+	}
+	defer l.Close()
+
+	return l.Addr().String()
+}
+
+// waitListening blocks until address accepts tcp connections.
+func waitListening(t *testing.T, address string) {
+	t.Helper()
+
+	deadline := time.Now().Add(3 * time.Second)
+	for time.Now().Before(deadline) {
+		conn, err := net.DialTimeout("tcp", address, 100*time.Millisecond)
+		if err == nil {
+			conn.Close()
+			return
+		}
+
+		time.Sleep(20 * time.Millisecond)
+	}
+
+	t.Fatalf("address %s is not listening", address)
+}
+
+func TestServiceRunContext(t *testing.T) {
+	cases := []struct {
+		name     string
+		httpAddr bool // start gRPC http gateway on a different address
+		opts     []Option
+		shutdown bool // stop by Shutdown instead of ctx cancel
+	}{
+		{
+			name: "grpc only",
+		},
+		{
+			name:     "grpc only shutdown",
+			shutdown: true,
+		},
+		{
+			name: "share address",
+			opts: []Option{WithEnableGRPCShareAddress()},
+		},
+		{
+			name:     "two services",
+			httpAddr: true,
+			shutdown: true,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			address := freeAddress(t)
+			opts := append([]Option{WithShutdownTimeout(time.Second)}, c.opts...)
+			httpAddress := ""
+			if c.httpAddr {
+				httpAddress = freeAddress(t)
+				opts = append(opts, WithGRPCHTTPAddress(httpAddress))
+			}
+
+			s := NewService(address, opts...)
+
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+
+			errChan := make(chan error, 1)
+			go func() {
+				errChan <- s.RunContext(ctx)
+			}()
+
+			waitListening(t, address)
+			if httpAddress != "" {
+				waitListening(t, httpAddress)
+			}
+
+			if c.shutdown {
+				shutdownCtx, shutdownCancel := context.WithTimeout(context.Background(), 3*time.Second)
+				defer shutdownCancel()
+				if err := s.Shutdown(shutdownCtx); err != nil {
+					t.Fatalf("shutdown error: %v", err)
+				}
+			} else {
+				cancel()
+			}
+
+			select {
+			case err := <-errChan:
+				if err != nil {
+					t.Fatalf("run context error: %v", err)
+				}
+			case <-time.After(3 * time.Second):
+				t.Fatalf("service did not stop")
+			}
+		})
+	}
+}
+
+func TestServiceRunContextStartError(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+
+	// the address is in use, so the service must fail to start
+	s := NewService(l.Addr().String())
+	if err := s.RunContext(context.Background()); err == nil {
+		t.Fatalf("expected listen error")
+	}
+
+	if err := s.RunContext(context.Background()); err != ErrServiceRunning {
+		t.Fatalf("expected ErrServiceRunning, got: %v", err)
+	}
+}
+
+func TestServiceShutdownBeforeRun(t *testing.T) {
+	s := NewService(freeAddress(t))
+	if err := s.Shutdown(context.Background()); err != nil {
+		t.Fatalf("shutdown error: %v", err)
+	}
+
+	// the service has been asked to quit, so it returns immediately
+	errChan := make(chan error, 1)
+	go func() {
+		errChan <- s.RunContext(context.Background())
+	}()
+
+	select {
+	case err := <-errChan:
+		if err != nil {
+			t.Fatalf("run context error: %v", err)
+		}
+	case <-time.After(3 * time.Second):
+		t.Fatalf("service did not stop")
+	}
+}
@@ -0,0 +1,135 @@
+package micro
+
+import (
+	"encoding/json"
+	"net/http"
+	"os"
+	"strings"
+
+	"github.com/daheige/hephfx/micro/openapi"
+)
+
+// defaultOpenAPIPath the default path prefix of the OpenAPI documents
+const defaultOpenAPIPath = "/openapi"
+
+// OpenAPIOption OpenAPI document option
+type OpenAPIOption func(o *openAPI)
+
+// openAPI serves the merged OpenAPI documents of the services.
+type openAPI struct {
+	path    string
+	docs    [][]byte
+	files   []string
+	title   string
+	version string
+	uiPath  string
+	ui      func(path string, docURL string) http.Handler
+}
+
+// WithOpenAPIPath returns an OpenAPIOption to set the path prefix of the documents,default: /openapi
+// The OpenAPI v2 document is served on <path>/v2.json and the OpenAPI v3 document on <path>/v3.json
+func WithOpenAPIPath(path string) OpenAPIOption {
+	return func(o *openAPI) {
+		o.path = path
+	}
+}
+
+// WithOpenAPIDocuments returns an OpenAPIOption to add the documents generated by protoc-gen-openapiv2,
+// eg: the *.swagger.json embedded by go:embed
+func WithOpenAPIDocuments(docs ...[]byte) OpenAPIOption {
+	return func(o *openAPI) {
+		o.docs = append(o.docs, docs...)
+	}
+}
+
+// WithOpenAPIFiles returns an OpenAPIOption to add the document files generated by protoc-gen-openapiv2,
+// the files are read when the gateway handlers are registered.
+func WithOpenAPIFiles(files ...string) OpenAPIOption {
+	return func(o *openAPI) {
+		o.files = append(o.files, files...)
+	}
+}
+
+// WithOpenAPIInfo returns an OpenAPIOption to set the title and the version of the merged document,
+// the info of the first document is used by default.
+func WithOpenAPIInfo(title string, version string) OpenAPIOption {
+	return func(o *openAPI) {
+		o.title = title
+		o.version = version
+	}
+}
+
+// WithSwaggerUI returns an OpenAPIOption to host the Swagger UI on the path,eg: /swagger
+// ui creates the Swagger UI handler which loads the OpenAPI v3 document,
+// eg: micro.WithSwaggerUI("/swagger", swaggerui.Handler)
+func WithSwaggerUI(path string, ui func(path string, docURL string) http.Handler) OpenAPIOption {
+	return func(o *openAPI) {
+		o.uiPath = strings.TrimSuffix(path, "/")
+		o.ui = ui
+	}
+}
+
+// registerOpenAPI merges the documents and registers the document routes,
+// the custom routes are added to the documents.
+func (s *Service) registerOpenAPI() error {
+	o := s.openAPI
+	docs := append([][]byte{}, o.docs...)
+	for _, file := range o.files {
+		b, err := os.ReadFile(file)
+		if err != nil {
+			return err
+		}
+
+		docs = append(docs, b)
+	}
+
+	doc, err := openapi.Merge(docs...)
+	if err != nil {
+		return err
+	}
+
+	doc.SetInfo(o.title, o.version)
+	for _, route := range s.routes {
+		if !strings.HasPrefix(route.Path, "/") {
+			route.Path = "/" + route.Path
+		}
+
+		doc.AddRoute(route.Method, route.Path, route.Name)
+	}
+
+	v2, err := json.Marshal(doc)
+	if err != nil {
+		return err
+	}
+
+	v3, err := json.Marshal(doc.V3())
+	if err != nil {
+		return err
+	}
+
+	path := strings.TrimSuffix(o.path, "/")
+	err = s.handlePath(http.MethodGet, path+"/v2.json", "openapi.v2", openAPIHandler(v2))
+	if err != nil {
+		return err
+	}
+
+	err = s.handlePath(http.MethodGet, path+"/v3.json", "openapi.v3", openAPIHandler(v3))
+	if err != nil || o.ui == nil {
+		return err
+	}
+
+	ui := o.ui(o.uiPath, path+"/v3.json")
+	err = s.handlePath(http.MethodGet, o.uiPath, "openapi.ui", ui)
+	if err != nil {
+		return err
+	}
+
+	return s.handlePath(http.MethodGet, o.uiPath+"/{file=**}", "openapi.ui", ui)
+}
+
+func openAPIHandler(doc []byte) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write(doc)
+	})
+}
@@ -0,0 +1,227 @@
+// Package openapi merges the OpenAPI v2 documents generated by protoc-gen-openapiv2,
+// adds the custom http routes to the paths and converts the document to OpenAPI v3.
+package openapi
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// Document the OpenAPI document,it is decoded from json as is.
+type Document map[string]interface{}
+
+// ErrNotSwagger2 the document is not the OpenAPI v2 document
+var ErrNotSwagger2 = errors.New("openapi document is not swagger 2.0")
+
+// pathParamPattern the path param of the grpc-gateway path template,eg: {name=shelves/*}
+var pathParamPattern = regexp.MustCompile(`\{([^}=]+)(=[^}]*)?\}`)
+
+// operationMethods the http methods of the path item operations
+var operationMethods = []string{"get", "put", "post", "delete", "options", "head", "patch"}
+
+// Merge merges the OpenAPI v2 documents into one document,
+// the paths,definitions and security definitions which appear in several documents
+// are taken from the first one.
+func Merge(docs ...[]byte) (Document, error) {
+	merged := Document{
+		"swagger":     "2.0",
+		"info":        map[string]interface{}{"title": "", "version": ""},
+		"paths":       map[string]interface{}{},
+		"definitions": map[string]interface{}{},
+	}
+
+	var (
+		tags     []interface{}
+		tagNames = make(map[string]bool)
+	)
+	for i, b := range docs {
+		var doc Document
+		if err := json.Unmarshal(b, &doc); err != nil {
+			return nil, fmt.Errorf("decode openapi document %d error: %w", i, err)
+		}
+
+		if doc["swagger"] != "2.0" {
+			return nil, fmt.Errorf("openapi document %d: %w", i, ErrNotSwagger2)
+		}
+
+		if i == 0 {
+			for _, key := range []string{"info", "host", "basePath", "schemes", "security", "externalDocs"} {
+				if v, ok := doc[key]; ok {
+					merged[key] = v
+				}
+			}
+		}
+
+		for _, key := range []string{"paths", "definitions", "securityDefinitions", "parameters", "responses"} {
+			mergeObject(merged, doc, key)
+		}
+
+		for _, key := range []string{"consumes", "produces"} {
+			mergeStrings(merged, doc, key)
+		}
+
+		for _, tag := range asSlice(doc["tags"]) {
+			name, _ := asMap(tag)["name"].(string)
+			if !tagNames[name] {
+				tagNames[name] = true
+				tags = append(tags, tag)
+			}
+		}
+	}
+
+	if len(tags) > 0 {
+		merged["tags"] = tags
+	}
+
+	return merged, nil
+}
+
+// SetInfo sets the title and the version of the document.
+func (d Document) SetInfo(title string, version string) {
+	info := asMap(d["info"])
+	if info == nil {
+		info = make(map[string]interface{})
+		d["info"] = info
+	}
+
+	if title != "" {
+		info["title"] = title
+	}
+
+	if version != "" {
+		info["version"] = version
+	}
+}
+
+// AddRoute adds the custom http route to the paths of the OpenAPI v2 document,
+// the path params of the grpc-gateway path template are converted,eg: /v1/{name=shelves/*} => /v1/{name}
+// It does nothing when the operation of the path already exists.
+func (d Document) AddRoute(method string, path string, name string) {
+	paths := asMap(d["paths"])
+	if paths == nil {
+		paths = make(map[string]interface{})
+		d["paths"] = paths
+	}
+
+	path = pathParamPattern.ReplaceAllString(path, "{$1}")
+	item := asMap(paths[path])
+	if item == nil {
+		item = make(map[string]interface{})
+		paths[path] = item
+	}
+
+	method = strings.ToLower(method)
+	if _, ok := item[method]; ok {
+		return
+	}
+
+	var parameters []interface{}
+	for _, m := range pathParamPattern.FindAllStringSubmatch(path, -1) {
+		parameters = append(parameters, map[string]interface{}{
+			"name":     m[1],
+			"in":       "path",
+			"required": true,
+			"type":     "string",
+		})
+	}
+
+	operation := map[string]interface{}{
+		"responses": map[string]interface{}{
+			"200": map[string]interface{}{"description": "A successful response."},
+		},
+	}
+	if name != "" {
+		operation["operationId"] = name
+		operation["summary"] = name
+	}
+
+	if len(parameters) > 0 {
+		operation["parameters"] = parameters
+	}
+
+	item[method] = operation
+}
+
+// mergeObject merges the object of the key,the existing properties are kept.
+func mergeObject(dst Document, src Document, key string) {
+	obj := asMap(src[key])
+	if len(obj) == 0 {
+		return
+	}
+
+	target := asMap(dst[key])
+	if target == nil {
+		target = make(map[string]interface{})
+		dst[key] = target
+	}
+
+	for name, v := range obj {
+		existing, ok := target[name]
+		if !ok {
+			target[name] = v
+			continue
+		}
+
+		// merge the operations of the same path
+		if key == "paths" {
+			item := asMap(existing)
+			for method, op := range asMap(v) {
+				if _, ok := item[method]; !ok {
+					item[method] = op
+				}
+			}
+		}
+	}
+}
+
+// mergeStrings merges the string array of the key without duplicates.
+func mergeStrings(dst Document, src Document, key string) {
+	values := asSlice(dst[key])
+	for _, v := range asSlice(src[key]) {
+		found := false
+		for _, existing := range values {
+			if existing == v {
+				found = true
+				break
+			}
+		}
+
+		if !found {
+			values = append(values, v)
+		}
+	}
+
+	if len(values) > 0 {
+		dst[key] = values
+	}
+}
+
+func asMap(v interface{}) map[string]interface{} {
+	switch m := v.(type) {
+	case map[string]interface{}:
+		return m
+	case Document:
+		return m
+	default:
+		return nil
+	}
+}
+
+func asSlice(v interface{}) []interface{} {
+	s, _ := v.([]interface{})
+	return s
+}
+
+// isOperationMethod reports whether the key of the path item is an operation.
+func isOperationMethod(key string) bool {
+	for _, m := range operationMethods {
+		if key == m {
+			return true
+		}
+	}
+
+	return false
+}
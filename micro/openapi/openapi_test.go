@@ -0,0 +1,134 @@
+package openapi
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"testing"
+)
+
+func readDocs(t *testing.T) [][]byte {
+	t.Helper()
+
+	// generated by protoc-gen-openapiv2 from example/protos/hello.proto and
+	// cmd/protoc-gen-hephfx-validate/testdata/protos/user.proto
+	var docs [][]byte
+	for _, file := range []string{"testdata/hello.swagger.json", "testdata/user.swagger.json"} {
+		b, err := os.ReadFile(file)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		docs = append(docs, b)
+	}
+
+	return docs
+}
+
+// lookup returns the value of the json path,eg: paths,/v1/say/{name},get
+func lookup(v interface{}, keys ...string) interface{} {
+	for _, key := range keys {
+		v = asMap(v)[key]
+	}
+
+	return v
+}
+
+func TestMerge(t *testing.T) {
+	doc, err := Merge(readDocs(t)...)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	doc.SetInfo("hephfx", "v1.0.0")
+	doc.AddRoute("GET", "/v1/books/{name=shelves/*}/{id}", "getBook")
+	doc.AddRoute("GET", "/v1/say/{name}", "ignored")
+
+	for _, path := range []string{"/v1/say/{name}", "/user.UserService/CreateUser", "/user.UserService/ListUsers"} {
+		if lookup(doc, "paths", path) == nil {
+			t.Fatalf("path %s is not merged", path)
+		}
+	}
+
+	for _, name := range []string{"HelloHelloReply", "userCreateUserReq", "rpcStatus"} {
+		if lookup(doc, "definitions", name) == nil {
+			t.Fatalf("definition %s is not merged", name)
+		}
+	}
+
+	if tags := asSlice(doc["tags"]); len(tags) != 2 {
+		t.Fatalf("tags = %v", tags)
+	}
+	if lookup(doc, "info", "title") != "hephfx" || lookup(doc, "info", "version") != "v1.0.0" {
+		t.Fatalf("info = %v", doc["info"])
+	}
+	if lookup(doc, "paths", "/v1/say/{name}", "get", "operationId") != "Greeter_SayHello" {
+		t.Fatal("the existing operation is replaced by the route")
+	}
+
+	params := asSlice(lookup(doc, "paths", "/v1/books/{name}/{id}", "get", "parameters"))
+	if len(params) != 2 || asMap(params[0])["name"] != "name" || asMap(params[1])["name"] != "id" {
+		t.Fatalf("route parameters = %v", params)
+	}
+
+	if _, err = Merge([]byte(`{"openapi":"3.0.3"}`)); !errors.Is(err, ErrNotSwagger2) {
+		t.Fatalf("expected ErrNotSwagger2,got:%v", err)
+	}
+}
+
+func TestV3(t *testing.T) {
+	doc, err := Merge(readDocs(t)...)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	doc["securityDefinitions"] = map[string]interface{}{
+		"bearer": map[string]interface{}{"type": "apiKey", "name": "Authorization", "in": "header"},
+		"basic":  map[string]interface{}{"type": "basic"},
+	}
+
+	// the v3 document is converted from a copy,the v2 document is unchanged
+	v3 := doc.V3()
+	if lookup(doc, "definitions", "rpcStatus") == nil {
+		t.Fatal("the v2 document is changed")
+	}
+
+	b, err := json.Marshal(v3)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var got map[string]interface{}
+	if err = json.Unmarshal(b, &got); err != nil {
+		t.Fatal(err)
+	}
+
+	if got["openapi"] != openAPIVersion || got["definitions"] != nil || got["swagger"] != nil {
+		t.Fatalf("document = %v", got)
+	}
+
+	param := asMap(asSlice(lookup(got, "paths", "/v1/say/{name}", "get", "parameters"))[0])
+	if param["in"] != "path" || lookup(param, "schema", "type") != "string" || param["type"] != nil {
+		t.Fatalf("parameter = %v", param)
+	}
+
+	ref := lookup(got, "paths", "/user.UserService/CreateUser", "post", "requestBody",
+		"content", "application/json", "schema", "$ref")
+	if ref != "#/components/schemas/userCreateUserReq" {
+		t.Fatalf("request body ref = %v", ref)
+	}
+
+	ref = lookup(got, "paths", "/v1/say/{name}", "get", "responses", "default",
+		"content", "application/json", "schema", "$ref")
+	if ref != "#/components/schemas/rpcStatus" {
+		t.Fatalf("response ref = %v", ref)
+	}
+
+	if lookup(got, "components", "schemas", "HelloHelloReply") == nil {
+		t.Fatal("schemas are not converted")
+	}
+	if lookup(got, "components", "securitySchemes", "basic", "scheme") != "basic" ||
+		lookup(got, "components", "securitySchemes", "bearer", "in") != "header" {
+		t.Fatalf("security schemes = %v", lookup(got, "components", "securitySchemes"))
+	}
+}
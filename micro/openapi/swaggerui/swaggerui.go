@@ -0,0 +1,50 @@
+// Package swaggerui serves the embedded Swagger UI,it is a separate package
+// so that the ui assets are linked only when it is imported.
+package swaggerui
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	swaggerFiles "github.com/swaggo/files/v2"
+)
+
+// initializerTpl the swagger-initializer.js which loads the document url
+const initializerTpl = `window.onload = function() {
+  window.ui = SwaggerUIBundle({
+    url: %q,
+    dom_id: '#swagger-ui',
+    deepLinking: true,
+    presets: [
+      SwaggerUIBundle.presets.apis,
+      SwaggerUIStandalonePreset
+    ],
+    plugins: [
+      SwaggerUIBundle.plugins.DownloadUrl
+    ],
+    layout: "StandaloneLayout"
+  });
+};
+`
+
+// Handler returns the Swagger UI handler mounted on the path,eg: /swagger
+// docURL is the url of the OpenAPI document,eg: /openapi/v3.json
+func Handler(path string, docURL string) http.Handler {
+	path = strings.TrimSuffix(path, "/")
+	initializer := []byte(fmt.Sprintf(initializerTpl, docURL))
+	files := http.StripPrefix(path+"/", http.FileServerFS(swaggerFiles.FS))
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case path:
+			// the assets are loaded by the relative urls
+			http.Redirect(w, r, path+"/", http.StatusMovedPermanently)
+		case path + "/swagger-initializer.js":
+			w.Header().Set("Content-Type", "application/javascript; charset=utf-8")
+			_, _ = w.Write(initializer)
+		default:
+			files.ServeHTTP(w, r)
+		}
+	})
+}
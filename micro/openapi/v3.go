@@ -0,0 +1,325 @@
+package openapi
+
+import (
+	"strings"
+)
+
+// openAPIVersion the version of the converted OpenAPI v3 document
+const openAPIVersion = "3.0.3"
+
+// schemaKeys the schema keys of the v2 non-body parameters and headers
+var schemaKeys = []string{
+	"type", "format", "items", "enum", "default", "maximum", "exclusiveMaximum", "minimum", "exclusiveMinimum",
+	"maxLength", "minLength", "pattern", "maxItems", "minItems", "uniqueItems", "multipleOf",
+}
+
+// V3 converts the OpenAPI v2 document to the OpenAPI v3 document,
+// the definitions are moved to components.schemas and the body parameters to the request bodies.
+func (d Document) V3() Document {
+	v2 := rewriteRefs(map[string]interface{}(d)).(map[string]interface{})
+	doc := Document{
+		"openapi": openAPIVersion,
+		"info":    v2["info"],
+		"paths":   map[string]interface{}{},
+	}
+
+	for _, key := range []string{"tags", "security", "externalDocs"} {
+		if v, ok := v2[key]; ok {
+			doc[key] = v
+		}
+	}
+
+	if servers := convertServers(v2); len(servers) > 0 {
+		doc["servers"] = servers
+	}
+
+	consumes := stringsOf(v2["consumes"], "application/json")
+	produces := stringsOf(v2["produces"], "application/json")
+	paths := asMap(doc["paths"])
+	for path, item := range asMap(v2["paths"]) {
+		converted := make(map[string]interface{})
+		for key, v := range asMap(item) {
+			switch {
+			case key == "parameters":
+				converted[key] = convertParameters(asSlice(v))
+			case isOperationMethod(key):
+				converted[key] = convertOperation(asMap(v), consumes, produces)
+			default:
+				converted[key] = v
+			}
+		}
+
+		paths[path] = converted
+	}
+
+	components := make(map[string]interface{})
+	if schemas := asMap(v2["definitions"]); len(schemas) > 0 {
+		components["schemas"] = schemas
+	}
+
+	if schemes := convertSecuritySchemes(asMap(v2["securityDefinitions"])); len(schemes) > 0 {
+		components["securitySchemes"] = schemes
+	}
+
+	if len(components) > 0 {
+		doc["components"] = components
+	}
+
+	return doc
+}
+
+// convertServers converts the host,basePath and schemes to the servers.
+func convertServers(v2 map[string]interface{}) []interface{} {
+	host, _ := v2["host"].(string)
+	basePath, _ := v2["basePath"].(string)
+	if host == "" {
+		if basePath == "" {
+			return nil
+		}
+
+		return []interface{}{map[string]interface{}{"url": basePath}}
+	}
+
+	var servers []interface{}
+	for _, scheme := range stringsOf(v2["schemes"], "https") {
+		servers = append(servers, map[string]interface{}{"url": scheme + "://" + host + basePath})
+	}
+
+	return servers
+}
+
+// convertOperation converts the parameters and the responses of the operation.
+func convertOperation(op map[string]interface{}, consumes []string, produces []string) map[string]interface{} {
+	consumes = stringsOf(op["consumes"], consumes...)
+	produces = stringsOf(op["produces"], produces...)
+	converted := make(map[string]interface{})
+	for key, v := range op {
+		switch key {
+		case "consumes", "produces", "schemes":
+		case "parameters":
+			var (
+				parameters []interface{}
+				form       = map[string]interface{}{"type": "object", "properties": map[string]interface{}{}}
+				required   []interface{}
+			)
+			for _, p := range asSlice(v) {
+				param := asMap(p)
+				switch param["in"] {
+				case "body":
+					body := map[string]interface{}{"content": contentOf(consumes, param["schema"])}
+					copyKeys(body, param, "description", "required")
+					converted["requestBody"] = body
+				case "formData":
+					name, _ := param["name"].(string)
+					asMap(form["properties"])[name] = schemaOf(param)
+					if param["required"] == true {
+						required = append(required, name)
+					}
+				default:
+					parameters = append(parameters, convertParameter(param))
+				}
+			}
+
+			if properties := asMap(form["properties"]); len(properties) > 0 {
+				if len(required) > 0 {
+					form["required"] = required
+				}
+
+				converted["requestBody"] = map[string]interface{}{
+					"content": contentOf(stringsOf(op["consumes"], "application/x-www-form-urlencoded"), form),
+				}
+			}
+
+			if len(parameters) > 0 {
+				converted["parameters"] = parameters
+			}
+		case "responses":
+			responses := make(map[string]interface{})
+			for code, r := range asMap(v) {
+				responses[code] = convertResponse(asMap(r), produces)
+			}
+
+			converted[key] = responses
+		default:
+			converted[key] = v
+		}
+	}
+
+	return converted
+}
+
+// convertParameters converts the non-body parameters of the path item.
+func convertParameters(parameters []interface{}) []interface{} {
+	converted := make([]interface{}, 0, len(parameters))
+	for _, p := range parameters {
+		converted = append(converted, convertParameter(asMap(p)))
+	}
+
+	return converted
+}
+
+// convertParameter moves the type of the v2 parameter into the schema,
+// the collectionFormat is converted into the style and explode.
+func convertParameter(param map[string]interface{}) map[string]interface{} {
+	converted := map[string]interface{}{"schema": schemaOf(param)}
+	copyKeys(converted, param, "name", "in", "description", "required", "allowEmptyValue")
+	for key, v := range param {
+		if strings.HasPrefix(key, "x-") {
+			converted[key] = v
+		}
+	}
+
+	switch param["collectionFormat"] {
+	case "multi":
+		converted["style"] = "form"
+		converted["explode"] = true
+	case "csv":
+		converted["style"] = "form"
+		converted["explode"] = false
+	case "ssv":
+		converted["style"] = "spaceDelimited"
+	case "pipes":
+		converted["style"] = "pipeDelimited"
+	}
+
+	return converted
+}
+
+// convertResponse moves the schema of the response into the content.
+func convertResponse(r map[string]interface{}, produces []string) map[string]interface{} {
+	converted := make(map[string]interface{})
+	for key, v := range r {
+		switch key {
+		case "schema":
+			converted["content"] = contentOf(produces, v)
+		case "headers":
+			headers := make(map[string]interface{})
+			for name, h := range asMap(v) {
+				header := map[string]interface{}{"schema": schemaOf(asMap(h))}
+				copyKeys(header, asMap(h), "description")
+				headers[name] = header
+			}
+
+			converted[key] = headers
+		case "examples":
+		default:
+			converted[key] = v
+		}
+	}
+
+	return converted
+}
+
+// convertSecuritySchemes converts the security definitions to the security schemes.
+func convertSecuritySchemes(definitions map[string]interface{}) map[string]interface{} {
+	schemes := make(map[string]interface{})
+	for name, v := range definitions {
+		def := asMap(v)
+		scheme := make(map[string]interface{})
+		copyKeys(scheme, def, "description")
+		switch def["type"] {
+		case "basic":
+			scheme["type"] = "http"
+			scheme["scheme"] = "basic"
+		case "apiKey":
+			copyKeys(scheme, def, "type", "name", "in")
+		case "oauth2":
+			flow := make(map[string]interface{})
+			copyKeys(flow, def, "authorizationUrl", "tokenUrl")
+			flow["scopes"] = def["scopes"]
+			if flow["scopes"] == nil {
+				flow["scopes"] = map[string]interface{}{}
+			}
+
+			flowName := map[interface{}]string{
+				"implicit":    "implicit",
+				"password":    "password",
+				"application": "clientCredentials",
+				"accessCode":  "authorizationCode",
+			}[def["flow"]]
+			scheme["type"] = "oauth2"
+			scheme["flows"] = map[string]interface{}{flowName: flow}
+		default:
+			continue
+		}
+
+		schemes[name] = scheme
+	}
+
+	return schemes
+}
+
+// schemaOf returns the schema of the v2 non-body parameter or header.
+func schemaOf(param map[string]interface{}) map[string]interface{} {
+	schema := make(map[string]interface{})
+	copyKeys(schema, param, schemaKeys...)
+	if schema["type"] == "file" {
+		schema["type"] = "string"
+		schema["format"] = "binary"
+	}
+
+	return schema
+}
+
+// contentOf returns the content of the media types with the schema.
+func contentOf(mediaTypes []string, schema interface{}) map[string]interface{} {
+	content := make(map[string]interface{})
+	for _, t := range mediaTypes {
+		content[t] = map[string]interface{}{"schema": schema}
+	}
+
+	return content
+}
+
+// rewriteRefs copies the value and rewrites the definition refs to the component schema refs,
+// eg: #/definitions/rpcStatus => #/components/schemas/rpcStatus
+func rewriteRefs(v interface{}) interface{} {
+	switch value := v.(type) {
+	case map[string]interface{}:
+		m := make(map[string]interface{}, len(value))
+		for key, item := range value {
+			if ref, ok := item.(string); ok && key == "$ref" {
+				m[key] = strings.Replace(ref, "#/definitions/", "#/components/schemas/", 1)
+				continue
+			}
+
+			m[key] = rewriteRefs(item)
+		}
+
+		return m
+	case []interface{}:
+		s := make([]interface{}, len(value))
+		for i, item := range value {
+			s[i] = rewriteRefs(item)
+		}
+
+		return s
+	default:
+		return v
+	}
+}
+
+// stringsOf returns the strings of the json array,or the default values when it is empty.
+func stringsOf(v interface{}, defaults ...string) []string {
+	var values []string
+	for _, item := range asSlice(v) {
+		if s, ok := item.(string); ok {
+			values = append(values, s)
+		}
+	}
+
+	if len(values) == 0 {
+		return defaults
+	}
+
+	return values
+}
+
+// copyKeys copies the values of the keys from src to dst.
+func copyKeys(dst map[string]interface{}, src map[string]interface{}, keys ...string) {
+	for _, key := range keys {
+		if v, ok := src[key]; ok {
+			dst[key] = v
+		}
+	}
+}
@@ -0,0 +1,69 @@
+package micro
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/daheige/hephfx/micro/openapi/swaggerui"
+)
+
+func TestWithOpenAPI(t *testing.T) {
+	s := NewService("", WithEnableInProcessGateway(),
+		WithRoutes(Route{Method: http.MethodGet, Path: "/v1/users/{id}", Name: "getUser",
+			Handler: func(w http.ResponseWriter, r *http.Request) {}}),
+		WithOpenAPI(
+			WithOpenAPIFiles("openapi/testdata/hello.swagger.json", "openapi/testdata/user.swagger.json"),
+			WithOpenAPIInfo("hephfx", "v1.0.0"),
+			WithSwaggerUI("/swagger", swaggerui.Handler),
+		),
+	)
+	h, err := s.HTTPHandler()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	get := func(path string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+		return rec
+	}
+
+	for _, path := range []string{"/openapi/v2.json", "/openapi/v3.json"} {
+		rec := get(path)
+		var doc struct {
+			Info  map[string]interface{}            `json:"info"`
+			Paths map[string]map[string]interface{} `json:"paths"`
+		}
+		if err = json.Unmarshal(rec.Body.Bytes(), &doc); err != nil {
+			t.Fatalf("%s: %v", path, err)
+		}
+
+		if doc.Info["title"] != "hephfx" || doc.Paths["/v1/say/{name}"]["get"] == nil ||
+			doc.Paths["/user.UserService/CreateUser"]["post"] == nil || doc.Paths["/v1/users/{id}"]["get"] == nil {
+			t.Fatalf("%s: document = %s", path, rec.Body.String())
+		}
+	}
+
+	if rec := get("/swagger"); rec.Code != http.StatusMovedPermanently || rec.Header().Get("Location") != "/swagger/" {
+		t.Fatalf("swagger ui redirect = %d %v", rec.Code, rec.Header())
+	}
+	if rec := get("/swagger/"); rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), "swagger-ui") {
+		t.Fatalf("swagger ui index = %d", rec.Code)
+	}
+	if rec := get("/swagger/swagger-initializer.js"); !strings.Contains(rec.Body.String(), `"/openapi/v3.json"`) {
+		t.Fatalf("swagger ui initializer = %s", rec.Body.String())
+	}
+	if rec := get("/swagger/swagger-ui-bundle.js"); rec.Code != http.StatusOK {
+		t.Fatalf("swagger ui bundle = %d", rec.Code)
+	}
+}
+
+func TestWithOpenAPIError(t *testing.T) {
+	s := NewService("", WithEnableInProcessGateway(), WithOpenAPI(WithOpenAPIFiles("openapi/testdata/none.json")))
+	if _, err := s.HTTPHandler(); err == nil {
+		t.Fatal("expected the document file error")
+	}
+}
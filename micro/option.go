@@ -1,13 +1,22 @@
 package micro
 
 import (
+	"context"
+	"crypto/tls"
 	"net/http"
 	"os"
+	"slices"
 	"time"
 
 	gPrometheus "github.com/grpc-ecosystem/go-grpc-middleware/providers/prometheus"
 	gRuntime "github.com/grpc-ecosystem/grpc-gateway/v2/runtime"
 	"google.golang.org/grpc"
+
+	"github.com/daheige/hephfx/hestia"
+	"github.com/daheige/hephfx/logger"
+	"github.com/daheige/hephfx/micro/auth"
+	"github.com/daheige/hephfx/micro/gerrors"
+	"github.com/daheige/hephfx/micro/tracing"
 )
 
 // Option for grpc service option
@@ -34,10 +43,40 @@ func WithStreamInterceptor(streamInterceptor ...grpc.StreamServerInterceptor) Op
 	}
 }
 
-// WithShutdownFunc returns an Option to register a function which will be called when server shutdown
+// WithShutdownFunc returns an Option to register a function which will be called after the gRPC server shutdown
+// It can be called multiple times,the functions are called in registration order.
 func WithShutdownFunc(f func()) Option {
 	return func(s *Service) {
-		s.shutdownFunc = f
+		s.shutdownHooks = append(s.shutdownHooks, ShutdownHook{
+			Name:  "shutdown-func",
+			Phase: PhaseAfterGRPC,
+			Fn: func(context.Context) error {
+				f()
+				return nil
+			},
+		})
+	}
+}
+
+// WithShutdownHook returns an Option to register a named hook which will be called in the phase
+// when server shutdown.
+// The hook ctx is done when the shutdown timeout is reached,
+// and the hook error is returned by Run.
+func WithShutdownHook(phase ShutdownPhase, name string, fn func(ctx context.Context) error) Option {
+	return func(s *Service) {
+		s.shutdownHooks = append(s.shutdownHooks, ShutdownHook{
+			Name:  name,
+			Phase: phase,
+			Fn:    fn,
+		})
+	}
+}
+
+// WithShutdownHooks returns an Option to register some shutdown hooks,
+// it can be used to set a timeout for each hook.
+func WithShutdownHooks(hooks ...ShutdownHook) Option {
+	return func(s *Service) {
+		s.shutdownHooks = append(s.shutdownHooks, hooks...)
 	}
 }
 
@@ -76,6 +115,91 @@ func WithEnableRequestAccess() Option {
 	}
 }
 
+// WithAccessLog returns an Option to install the structured access log interceptors,
+// the access logs are written through logger.Logger.
+func WithAccessLog(l logger.Logger, opts ...AccessLogOption) Option {
+	return func(s *Service) {
+		s.unaryInterceptors = append(s.unaryInterceptors, AccessLogUnaryInterceptor(l, opts...))
+		s.streamInterceptors = append(s.streamInterceptors, AccessLogStreamInterceptor(l, opts...))
+	}
+}
+
+// WithMetadataPropagation returns an Option to install the metadata propagation interceptors,
+// x-request-id and the allowed headers are copied into ctxkeys context values.
+// The interceptors run right after the recovery interceptor,so the other interceptors see the values.
+func WithMetadataPropagation(opts ...PropagationOption) Option {
+	return func(s *Service) {
+		// index 0 is the recovery interceptor
+		s.unaryInterceptors = slices.Insert(s.unaryInterceptors, 1, PropagationUnaryServerInterceptor(opts...))
+		s.streamInterceptors = slices.Insert(s.streamInterceptors, 1, PropagationStreamServerInterceptor(opts...))
+	}
+}
+
+// WithTracing returns an Option to install the OpenTelemetry tracing interceptors,
+// the trace context of the http gateway requests is carried into gRPC metadata by the annotator.
+func WithTracing(opts ...tracing.Option) Option {
+	return func(s *Service) {
+		// index 0 is the recovery interceptor
+		s.unaryInterceptors = slices.Insert(s.unaryInterceptors, 1, tracing.UnaryServerInterceptor(opts...))
+		s.streamInterceptors = slices.Insert(s.streamInterceptors, 1, tracing.StreamServerInterceptor(opts...))
+		s.annotators = append(s.annotators, tracing.GatewayAnnotator(opts...))
+	}
+}
+
+// WithRateLimiter returns an Option to install the rate limit and concurrency limit interceptors.
+func WithRateLimiter(l *RateLimiter) Option {
+	return func(s *Service) {
+		s.unaryInterceptors = append(s.unaryInterceptors, l.UnaryServerInterceptor())
+		s.streamInterceptors = append(s.streamInterceptors, l.StreamServerInterceptor())
+	}
+}
+
+// WithLoadShedder returns an Option to install the adaptive load shedding interceptors.
+func WithLoadShedder(shedder *Shedder) Option {
+	return func(s *Service) {
+		s.unaryInterceptors = append(s.unaryInterceptors, shedder.UnaryServerInterceptor())
+		s.streamInterceptors = append(s.streamInterceptors, shedder.StreamServerInterceptor())
+	}
+}
+
+// WithDeadline returns an Option to install the deadline interceptors,
+// the X-Timeout header of the http gateway requests is converted into the deadline.
+func WithDeadline(opts ...DeadlineOption) Option {
+	return func(s *Service) {
+		s.unaryInterceptors = append(s.unaryInterceptors, DeadlineUnaryInterceptor(opts...))
+		s.streamInterceptors = append(s.streamInterceptors, DeadlineStreamInterceptor(opts...))
+		s.gatewayMiddlewares = append(s.gatewayMiddlewares, gatewayTimeoutMiddleware)
+	}
+}
+
+// WithAuth returns an Option to install the authentication interceptors,
+// the Authorization and X-Api-Key headers of the http gateway requests are forwarded by the annotator.
+func WithAuth(opts ...auth.Option) Option {
+	return func(s *Service) {
+		s.unaryInterceptors = append(s.unaryInterceptors, auth.UnaryServerInterceptor(opts...))
+		s.streamInterceptors = append(s.streamInterceptors, auth.StreamServerInterceptor(opts...))
+		s.annotators = append(s.annotators, auth.GatewayAnnotator())
+	}
+}
+
+// WithAuthPolicy returns an Option to install the authorization interceptors,
+// it must be applied after WithAuth so that the principal is in the context.
+func WithAuthPolicy(policy *auth.Policy) Option {
+	return func(s *Service) {
+		s.unaryInterceptors = append(s.unaryInterceptors, policy.UnaryServerInterceptor())
+		s.streamInterceptors = append(s.streamInterceptors, policy.StreamServerInterceptor())
+	}
+}
+
+// WithErrorRegistry returns an Option to install the interceptors which convert the domain errors
+// of the handlers to gRPC status errors by the registry,eg: gerrors.DefaultRegistry()
+func WithErrorRegistry(r *gerrors.Registry) Option {
+	return func(s *Service) {
+		s.unaryInterceptors = append(s.unaryInterceptors, r.UnaryServerInterceptor())
+		s.streamInterceptors = append(s.streamInterceptors, r.StreamServerInterceptor())
+	}
+}
+
 // WithEnablePrometheus enable prometheus
 func WithEnablePrometheus() Option {
 	return func(s *Service) {
@@ -90,6 +214,63 @@ func WithServerMetricsOptions(opts ...gPrometheus.ServerMetricsOption) Option {
 	}
 }
 
+// WithEnableHealthCheck registers the standard grpc.health.v1.Health service on gRPC server,
+// and mirrors it as /healthz and /readyz on gRPC http gateway.
+// All serving status are set to NOT_SERVING at the start of shutdown.
+func WithEnableHealthCheck() Option {
+	return func(s *Service) {
+		s.enableHealthCheck = true
+	}
+}
+
+// WithRegistry registers the service into the registry automatically.
+// svc is the service instance template,its Address is filled in from the real listener address
+// after the gRPC listener is bound.
+// When the gRPC http gateway runs on its own port, the gateway is registered as a second
+// hestia.ProtocolHTTP instance.
+// All the instances are deregistered in the pre-drain shutdown phase,before the gRPC server graceful stop.
+func WithRegistry(reg hestia.Registry, svc *hestia.Service) Option {
+	return func(s *Service) {
+		s.registry = reg
+		s.registryService = svc
+	}
+}
+
+// WithTLSConfig returns an Option to set the tls config of gRPC server and gRPC http gateway.
+// When the gRPC server and gRPC http gateway start on one port,
+// HTTP/2 is negotiated by ALPN instead of h2c.
+func WithTLSConfig(cfg *tls.Config) Option {
+	return func(s *Service) {
+		s.tlsConfig = cfg
+	}
+}
+
+// WithTLSCertFile returns an Option to set the server certificate and private key files.
+// The files are reloaded when they are changed if WithTLSReloadInterval is set.
+func WithTLSCertFile(certFile, keyFile string) Option {
+	return func(s *Service) {
+		s.tlsCertFile = certFile
+		s.tlsKeyFile = keyFile
+	}
+}
+
+// WithTLSClientCAFile returns an Option to enable mTLS,
+// the client certificates are verified by the CA file.
+// It must be used with WithTLSCertFile.
+func WithTLSClientCAFile(caFile string) Option {
+	return func(s *Service) {
+		s.tlsClientCAFile = caFile
+	}
+}
+
+// WithTLSReloadInterval returns an Option to reload the certificate files when they are changed,
+// the files are checked at most once per interval.
+func WithTLSReloadInterval(interval time.Duration) Option {
+	return func(s *Service) {
+		s.tlsReloadInterval = interval
+	}
+}
+
 // WithEnableRequestValidator set request validator interceptor
 func WithEnableRequestValidator() Option {
 	return func(s *Service) {
@@ -97,6 +278,15 @@ func WithEnableRequestValidator() Option {
 	}
 }
 
+// WithRequestValidator returns an Option to enable the request validator interceptor with the options,
+// eg: WithValidatorTranslator
+func WithRequestValidator(opts ...ValidatorOption) Option {
+	return func(s *Service) {
+		s.enableRequestValidator = true
+		s.validatorOptions = append(s.validatorOptions, opts...)
+	}
+}
+
 // WithGRPCNetwork set gRPC start network type
 func WithGRPCNetwork(network string) Option {
 	return func(s *Service) {
@@ -111,6 +301,49 @@ func WithHandlerFromEndpoints(h ...HandlerFromEndpoint) Option {
 	}
 }
 
+// WithHandlerServers add HandlerServer,the handlers call the gRPC service implementation directly
+// without the gRPC interceptors.
+func WithHandlerServers(h ...HandlerServer) Option {
+	return func(s *Service) {
+		s.handlerServers = append(s.handlerServers, h...)
+	}
+}
+
+// WithEnableInProcessGateway returns an Option to connect the gRPC http gateway handlers
+// to the gRPC server over an in-memory listener instead of dialing to the gRPC address.
+// The gateway can be used without binding any ports by HTTPHandler.
+func WithEnableInProcessGateway() Option {
+	return func(s *Service) {
+		s.enableInProcessGateway = true
+	}
+}
+
+// WithListeners returns an Option to add extra listeners,
+// the same gRPC server and gateway handler serve all of them.
+// Each listener is shut down gracefully with its own timeout.
+func WithListeners(listeners ...Listener) Option {
+	return func(s *Service) {
+		s.listeners = append(s.listeners, listeners...)
+	}
+}
+
+// WithEnableGracefulRestart returns an Option to restart the service gracefully on SIGUSR2.
+// The listeners are handed over to a new process of the same executable,
+// and the service is stopped gracefully after the new process is ready.
+func WithEnableGracefulRestart() Option {
+	return func(s *Service) {
+		s.enableGracefulRestart = true
+	}
+}
+
+// WithRestartTimeout returns an Option to set the timeout of waiting for the new process to be ready,
+// default: 30s
+func WithRestartTimeout(timeout time.Duration) Option {
+	return func(s *Service) {
+		s.restartTimeout = timeout
+	}
+}
+
 // WithEnableHTTPGateway enable http gateway
 func WithEnableHTTPGateway() Option {
 	return func(s *Service) {
@@ -125,6 +358,13 @@ func WithMuxOption(muxOption ...gRuntime.ServeMuxOption) Option {
 	}
 }
 
+// WithAnnotators returns an Option to inject metadata from http request into gRPC context
+func WithAnnotators(annotators ...AnnotatorFunc) Option {
+	return func(s *Service) {
+		s.annotators = append(s.annotators, annotators...)
+	}
+}
+
 // WithRoutes adds additional routes
 func WithRoutes(routes ...Route) Option {
 	return func(s *Service) {
@@ -132,6 +372,73 @@ func WithRoutes(routes ...Route) Option {
 	}
 }
 
+// WithRouteGroups adds the routes of the route groups,
+// the routes should be added to the groups before calling NewService.
+func WithRouteGroups(groups ...*RouteGroup) Option {
+	return func(s *Service) {
+		for _, g := range groups {
+			s.routes = append(s.routes, g.Routes()...)
+		}
+	}
+}
+
+// WithEnableDebugRoutes lists the registered http routes on /debug/routes of gRPC http gateway,
+// it includes the custom routes and the builtin routes,eg: /healthz
+func WithEnableDebugRoutes() Option {
+	return func(s *Service) {
+		s.enableDebugRoutes = true
+	}
+}
+
+// WithOpenAPI returns an Option to serve the OpenAPI documents on gRPC http gateway,
+// the documents of the services are merged into one document with the custom routes.
+func WithOpenAPI(opts ...OpenAPIOption) Option {
+	return func(s *Service) {
+		s.openAPI = &openAPI{path: defaultOpenAPIPath}
+		for _, o := range opts {
+			o(s.openAPI)
+		}
+	}
+}
+
+// WithCORS returns an Option to handle the cors requests of gRPC http gateway.
+// The gateway middlewares wrap the handler of WithGRPCHTTPHandler,the first one is the outermost.
+func WithCORS(opts ...CORSOption) Option {
+	return func(s *Service) {
+		s.gatewayMiddlewares = append(s.gatewayMiddlewares, CORSMiddleware(opts...))
+	}
+}
+
+// WithCompression returns an Option to compress the responses of gRPC http gateway by gzip or zstd.
+func WithCompression(opts ...CompressOption) Option {
+	return func(s *Service) {
+		s.gatewayMiddlewares = append(s.gatewayMiddlewares, CompressMiddleware(opts...))
+	}
+}
+
+// WithBodyLimit returns an Option to limit the request body size of gRPC http gateway,eg: 4 << 20
+func WithBodyLimit(limit int64) Option {
+	return func(s *Service) {
+		s.gatewayMiddlewares = append(s.gatewayMiddlewares, BodyLimitMiddleware(limit))
+	}
+}
+
+// WithSecurityHeaders returns an Option to set the standard security headers of gRPC http gateway responses.
+func WithSecurityHeaders(opts ...SecurityOption) Option {
+	return func(s *Service) {
+		s.gatewayMiddlewares = append(s.gatewayMiddlewares, SecurityHeadersMiddleware(opts...))
+	}
+}
+
+// WithStreaming returns an Option to bridge the streaming methods of gRPC http gateway,
+// the server-streaming methods are served as Server-Sent Events and the bidi-streaming methods over WebSocket,
+// eg: micro.WithStreaming(micro.WithSSE("/v1/orders/{id}/events"), micro.WithWebSocket("/v1/chat"))
+func WithStreaming(opts ...StreamOption) Option {
+	return func(s *Service) {
+		s.gatewayMiddlewares = append(s.gatewayMiddlewares, StreamMiddleware(opts...))
+	}
+}
+
 // WithGRPCEndpointDialOptions returns an Option to append a gRPC dial option
 func WithGRPCEndpointDialOptions(dialOption ...grpc.DialOption) Option {
 	return func(s *Service) {
@@ -187,6 +494,27 @@ func WithEnableGRPCShareAddress() Option {
 	}
 }
 
+// WithGRPCWeb returns an Option to serve the gRPC-Web requests by the gRPC server on the shared port,
+// the gRPC-Web requests are served by the gateway handler when it is not set,
+// eg: micro.WithGRPCWeb(micro.WithGRPCWebCORS(micro.WithCORSAllowOrigins("https://app.example.com")))
+func WithGRPCWeb(opts ...GRPCWebOption) Option {
+	return func(s *Service) {
+		s.enableGRPCWeb = true
+		s.grpcWebOptions = append(s.grpcWebOptions, opts...)
+	}
+}
+
+// WithConnect returns an Option to serve the Connect requests of the registered methods
+// by the gRPC server on the shared port,
+// the Connect requests are served by the gateway handler when it is not set,
+// eg: micro.WithConnect(micro.WithConnectMaxRecvMsgSize(8 << 20))
+func WithConnect(opts ...ConnectOption) Option {
+	return func(s *Service) {
+		s.enableConnect = true
+		s.connectOptions = append(s.connectOptions, opts...)
+	}
+}
+
 // WithEnableDefaultProtoJSON set protoJSON
 func WithEnableDefaultProtoJSON(b bool) Option {
 	return func(s *Service) {
@@ -0,0 +1,164 @@
+package micro
+
+import (
+	"context"
+	"strings"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+
+	"github.com/daheige/hephfx/ctxkeys"
+	"github.com/daheige/hephfx/gutils"
+)
+
+// PropagationOption metadata propagation interceptor option
+type PropagationOption func(p *propagation)
+
+// propagation carries x-request-id and the allowed headers from incoming metadata
+// to context values and outgoing metadata.
+type propagation struct {
+	headers []string // the allowed headers,x-request-id is always propagated
+}
+
+// WithPropagationHeaders returns a PropagationOption to append the allowed headers,
+// eg: x-tenant-id, x-user-id
+func WithPropagationHeaders(headers ...string) PropagationOption {
+	return func(p *propagation) {
+		for _, header := range headers {
+			p.headers = append(p.headers, strings.ToLower(header))
+		}
+	}
+}
+
+func newPropagation(opts ...PropagationOption) *propagation {
+	p := &propagation{}
+	for _, o := range opts {
+		o(p)
+	}
+
+	return p
+}
+
+// PropagationCtxKey returns the ctxkeys.CtxKey of the propagated header,
+// the header value is stored in context as a string.
+func PropagationCtxKey(header string) ctxkeys.CtxKey {
+	return ctxkeys.CtxKey{Name: strings.ToLower(header)}
+}
+
+// PropagationUnaryServerInterceptor returns a server unary interceptor which copies x-request-id
+// and the allowed headers from incoming metadata into ctxkeys context values.
+// x-request-id is generated when it is not in the incoming metadata.
+func PropagationUnaryServerInterceptor(opts ...PropagationOption) grpc.UnaryServerInterceptor {
+	p := newPropagation(opts...)
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo,
+		handler grpc.UnaryHandler) (interface{}, error) {
+		return handler(p.serverContext(ctx, info.FullMethod), req)
+	}
+}
+
+// PropagationStreamServerInterceptor returns a server stream interceptor which copies x-request-id
+// and the allowed headers from incoming metadata into ctxkeys context values.
+func PropagationStreamServerInterceptor(opts ...PropagationOption) grpc.StreamServerInterceptor {
+	p := newPropagation(opts...)
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		return handler(srv, &contextServerStream{
+			ServerStream: ss,
+			ctx:          p.serverContext(ss.Context(), info.FullMethod),
+		})
+	}
+}
+
+// PropagationUnaryClientInterceptor returns a client unary interceptor which carries x-request-id
+// and the allowed headers from context values or incoming metadata to outgoing metadata.
+// x-request-id is generated when it is not found.
+func PropagationUnaryClientInterceptor(opts ...PropagationOption) grpc.UnaryClientInterceptor {
+	p := newPropagation(opts...)
+	return func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn,
+		invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+		return invoker(p.clientContext(ctx), method, req, reply, cc, opts...)
+	}
+}
+
+// PropagationStreamClientInterceptor returns a client stream interceptor which carries x-request-id
+// and the allowed headers from context values or incoming metadata to outgoing metadata.
+func PropagationStreamClientInterceptor(opts ...PropagationOption) grpc.StreamClientInterceptor {
+	p := newPropagation(opts...)
+	return func(ctx context.Context, desc *grpc.StreamDesc, cc *grpc.ClientConn, method string,
+		streamer grpc.Streamer, opts ...grpc.CallOption) (grpc.ClientStream, error) {
+		return streamer(p.clientContext(ctx), desc, cc, method, opts...)
+	}
+}
+
+// contextServerStream overrides the context of grpc.ServerStream.
+type contextServerStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+// Context returns the context of the stream.
+func (s *contextServerStream) Context() context.Context {
+	return s.ctx
+}
+
+// serverContext returns the context with x-request-id and the allowed headers.
+func (p *propagation) serverContext(ctx context.Context, method string) context.Context {
+	md := IncomingMD(ctx)
+	requestID := GetStringFromMD(md, ctxkeys.XRequestID)
+	if requestID == "" {
+		// the handler gets the same request id from the incoming metadata
+		requestID = gutils.Uuid()
+		md = md.Copy()
+		md.Set(ctxkeys.XRequestID.String(), requestID)
+		ctx = metadata.NewIncomingContext(ctx, md)
+	}
+
+	ctx = context.WithValue(ctx, ctxkeys.XRequestID, requestID)
+	ctx = context.WithValue(ctx, ctxkeys.RequestMethod, method)
+	if clientIP, err := GetGRPCClientIP(ctx); err == nil {
+		ctx = context.WithValue(ctx, ctxkeys.ClientIP, clientIP)
+	}
+
+	for _, header := range p.headers {
+		if values := md.Get(header); len(values) > 0 {
+			ctx = context.WithValue(ctx, PropagationCtxKey(header), values[0])
+		}
+	}
+
+	return ctx
+}
+
+// clientContext returns the context with x-request-id and the allowed headers in outgoing metadata.
+// The headers which are already in outgoing metadata are not overwritten.
+func (p *propagation) clientContext(ctx context.Context) context.Context {
+	md := OutgoingMD(ctx)
+	incoming := IncomingMD(ctx)
+	if len(md.Get(ctxkeys.XRequestID.String())) == 0 {
+		requestID := propagatedValue(ctx, incoming, ctxkeys.XRequestID)
+		if requestID == "" {
+			requestID = gutils.Uuid()
+		}
+
+		md.Set(ctxkeys.XRequestID.String(), requestID)
+	}
+
+	for _, header := range p.headers {
+		if len(md.Get(header)) > 0 {
+			continue
+		}
+
+		if value := propagatedValue(ctx, incoming, PropagationCtxKey(header)); value != "" {
+			md.Set(header, value)
+		}
+	}
+
+	return metadata.NewOutgoingContext(ctx, md)
+}
+
+// propagatedValue returns the value from context value first,then from incoming metadata.
+func propagatedValue(ctx context.Context, incoming metadata.MD, key ctxkeys.CtxKey) string {
+	if value, ok := ctx.Value(key).(string); ok && value != "" {
+		return value
+	}
+
+	return GetStringFromMD(incoming, key)
+}
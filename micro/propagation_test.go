@@ -0,0 +1,127 @@
+package micro
+
+import (
+	"context"
+	"testing"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+
+	"github.com/daheige/hephfx/ctxkeys"
+	"github.com/daheige/hephfx/example/pb"
+)
+
+// ctxServerStream is a grpc.ServerStream with the given context.
+type ctxServerStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *ctxServerStream) Context() context.Context {
+	return s.ctx
+}
+
+func TestPropagationUnaryServer(t *testing.T) {
+	l, logs := newObservedLogger()
+	greeter := &testGreeter{}
+	s := newTestService(t, greeter,
+		// the access log is installed first,it still sees the propagated values
+		WithAccessLog(l),
+		WithMetadataPropagation(WithPropagationHeaders("X-Tenant-Id")),
+	)
+	conn := dialService(t, s)
+
+	ctx := metadata.AppendToOutgoingContext(context.Background(), "x-tenant-id", "tenant-1")
+	if _, err := pb.NewGreeterClient(conn).SayHello(ctx, &pb.HelloReq{Name: "heige"}); err != nil {
+		t.Fatalf("say hello error: %v", err)
+	}
+
+	requestID, _ := greeter.lastContext().Value(ctxkeys.XRequestID).(string)
+	if requestID == "" {
+		t.Fatalf("x-request-id is not generated")
+	}
+	if id := GetStringFromMD(IncomingMD(greeter.lastContext()), ctxkeys.XRequestID); id != requestID {
+		t.Fatalf("incoming x-request-id = %s, want %s", id, requestID)
+	}
+	if tenant := greeter.lastContext().Value(PropagationCtxKey("x-tenant-id")); tenant != "tenant-1" {
+		t.Fatalf("x-tenant-id ctx value = %v, want tenant-1", tenant)
+	}
+	if method := greeter.lastContext().Value(ctxkeys.RequestMethod); method != "/Hello.Greeter/SayHello" {
+		t.Fatalf("request method ctx value = %v", method)
+	}
+
+	entries := logs.All()
+	if len(entries) != 1 || entries[0].ContextMap()[ctxkeys.XRequestID.String()] != requestID {
+		t.Fatalf("access log entries = %v, want x-request-id %s", entries, requestID)
+	}
+}
+
+func TestPropagationStreamServer(t *testing.T) {
+	interceptor := PropagationStreamServerInterceptor(WithPropagationHeaders("x-tenant-id"))
+	md := metadata.Pairs("x-request-id", "req-1", "x-tenant-id", "tenant-1")
+	ss := &ctxServerStream{ctx: metadata.NewIncomingContext(context.Background(), md)}
+	info := &grpc.StreamServerInfo{FullMethod: "/Hello.Greeter/Watch"}
+
+	var ctx context.Context
+	err := interceptor(nil, ss, info, func(_ interface{}, stream grpc.ServerStream) error {
+		ctx = stream.Context()
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if id := ctx.Value(ctxkeys.XRequestID); id != "req-1" {
+		t.Fatalf("x-request-id ctx value = %v, want req-1", id)
+	}
+	if tenant := ctx.Value(PropagationCtxKey("x-tenant-id")); tenant != "tenant-1" {
+		t.Fatalf("x-tenant-id ctx value = %v, want tenant-1", tenant)
+	}
+	if method := ctx.Value(ctxkeys.RequestMethod); method != info.FullMethod {
+		t.Fatalf("request method ctx value = %v", method)
+	}
+}
+
+func TestPropagationClient(t *testing.T) {
+	opt := WithPropagationHeaders("x-tenant-id", "x-user-id")
+	incoming := metadata.Pairs("x-request-id", "req-1", "x-user-id", "user-1")
+	ctx := metadata.NewIncomingContext(context.Background(), incoming)
+	ctx = context.WithValue(ctx, PropagationCtxKey("x-tenant-id"), "tenant-1")
+
+	var outgoing metadata.MD
+	unary := PropagationUnaryClientInterceptor(opt)
+	err := unary(ctx, "/Hello.Greeter/SayHello", nil, nil, nil,
+		func(ctx context.Context, _ string, _, _ interface{}, _ *grpc.ClientConn, _ ...grpc.CallOption) error {
+			outgoing, _ = metadata.FromOutgoingContext(ctx)
+			return nil
+		})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for key, want := range map[string]string{"x-request-id": "req-1", "x-tenant-id": "tenant-1", "x-user-id": "user-1"} {
+		if values := outgoing.Get(key); len(values) != 1 || values[0] != want {
+			t.Fatalf("outgoing %s = %v, want %s", key, values, want)
+		}
+	}
+
+	// the headers in outgoing metadata are not overwritten,x-request-id is generated if not found
+	ctx = metadata.AppendToOutgoingContext(context.Background(), "x-user-id", "user-2")
+	stream := PropagationStreamClientInterceptor(opt)
+	_, err = stream(ctx, &grpc.StreamDesc{}, nil, "/Hello.Greeter/Watch",
+		func(ctx context.Context, _ *grpc.StreamDesc, _ *grpc.ClientConn, _ string,
+			_ ...grpc.CallOption) (grpc.ClientStream, error) {
+			outgoing, _ = metadata.FromOutgoingContext(ctx)
+			return nil, nil
+		})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if values := outgoing.Get("x-user-id"); len(values) != 1 || values[0] != "user-2" {
+		t.Fatalf("outgoing x-user-id = %v, want user-2", values)
+	}
+	if values := outgoing.Get("x-request-id"); len(values) != 1 || values[0] == "" {
+		t.Fatalf("outgoing x-request-id is not generated")
+	}
+}
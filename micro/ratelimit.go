@@ -0,0 +1,409 @@
+package micro
+
+import (
+	"context"
+	"math"
+	"net/netip"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"golang.org/x/time/rate"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+
+	"github.com/daheige/hephfx/settings"
+)
+
+const (
+	// RetryAfterKey the metadata key of the seconds to wait before retrying a rejected request.
+	RetryAfterKey = "retry-after"
+
+	// defaultClientIdleTimeout the idle per client token buckets are removed after the timeout.
+	defaultClientIdleTimeout = 3 * time.Minute
+
+	// defaultMaxClients the default max number of the per client token buckets of a rule.
+	defaultMaxClients = 10000
+)
+
+// RateLimitRule token bucket rate limit rule of the methods.
+type RateLimitRule struct {
+	// Method the full method,eg: /Hello.Greeter/SayHello
+	// /Hello.Greeter/* matches all methods of the service,* matches all methods.
+	Method string `json:"method" mapstructure:"method"`
+
+	// Rate the tokens per second of the method,0 means no limit
+	Rate float64 `json:"rate" mapstructure:"rate"`
+
+	// Burst the bucket size of the method,default: ceil(Rate)
+	Burst int `json:"burst" mapstructure:"burst"`
+
+	// ClientRate the tokens per second of each client identity,0 means no limit
+	ClientRate float64 `json:"client_rate" mapstructure:"client_rate"`
+
+	// ClientBurst the bucket size of each client identity,default: ceil(ClientRate)
+	ClientBurst int `json:"client_burst" mapstructure:"client_burst"`
+}
+
+// RateLimitConfig rate limit and concurrency limit config,it can be read from settings.Config:
+//
+//	rate_limit:
+//	  max_in_flight: 1000
+//	  client_key: x-client-id
+//	  trusted_proxies: ["127.0.0.1","10.0.0.0/8"]
+//	  rules:
+//	    - method: "/Hello.Greeter/SayHello"
+//	      rate: 100
+//	      burst: 200
+//	      client_rate: 10
+//	    - method: "*"
+//	      rate: 1000
+type RateLimitConfig struct {
+	// MaxInFlight the max number of concurrent requests,0 means no limit.
+	// A stream is in flight until it is finished.
+	MaxInFlight int `json:"max_in_flight" mapstructure:"max_in_flight"`
+
+	// ClientKey the metadata key of the client identity,eg: x-client-id.
+	// The peer ip is used when it is empty or not found.
+	ClientKey string `json:"client_key" mapstructure:"client_key"`
+
+	// TrustedProxies the ip or cidr of the trusted proxies,eg: 127.0.0.1,10.0.0.0/8.
+	// The x-forwarded-for addresses are used only when the peer ip is trusted,
+	// the nearest untrusted address is the client identity. The invalid values are ignored.
+	TrustedProxies []string `json:"trusted_proxies" mapstructure:"trusted_proxies"`
+
+	// MaxClients the max number of the per client token buckets of a rule,default: 10000.
+	// The new clients share one token bucket until the idle buckets are removed.
+	MaxClients int `json:"max_clients" mapstructure:"max_clients"`
+
+	// Rules the rate limit rules,the most specific rule of the method is used
+	Rules []RateLimitRule `json:"rules" mapstructure:"rules"`
+}
+
+// RateLimiter limits the request rate per method and per client identity with token buckets,
+// and limits the number of in-flight requests.
+type RateLimiter struct {
+	limits   atomic.Pointer[rateLimits]
+	inFlight atomic.Int64
+}
+
+// rateLimits the token buckets built from RateLimitConfig,it is replaced on update.
+type rateLimits struct {
+	maxInFlight    int64
+	clientKey      string
+	trustedProxies []netip.Prefix
+	rules          map[string]*methodLimiter
+}
+
+// methodLimiter the token buckets of a rule.
+type methodLimiter struct {
+	limiter *rate.Limiter // nil means no limit
+
+	clientRate  rate.Limit
+	clientBurst int
+	maxClients  int
+	mu          sync.Mutex
+	clients     map[string]*clientLimiter
+	overflow    *rate.Limiter // shared by the new clients when maxClients is exceeded
+	lastSweep   time.Time
+}
+
+type clientLimiter struct {
+	limiter  *rate.Limiter
+	lastSeen time.Time
+}
+
+// NewRateLimiter creates a RateLimiter with the config.
+func NewRateLimiter(cfg RateLimitConfig) *RateLimiter {
+	l := &RateLimiter{}
+	l.Update(cfg)
+	return l
+}
+
+// NewRateLimiterFromConfig creates a RateLimiter from the section of settings.Config,
+// the limits are reloaded when the config file is watched and changed.
+func NewRateLimiterFromConfig(conf settings.Config, key string) (*RateLimiter, error) {
+	var cfg RateLimitConfig
+	if err := conf.ReadSection(key, &cfg); err != nil {
+		return nil, err
+	}
+
+	l := NewRateLimiter(cfg)
+	if w, ok := conf.(settings.Watcher); ok {
+		w.OnChange(func() {
+			var cfg RateLimitConfig
+			if err := conf.ReadSection(key, &cfg); err != nil {
+				return
+			}
+
+			l.Update(cfg)
+		})
+	}
+
+	return l, nil
+}
+
+// Update replaces the limits with the config,the token buckets are reset
+// and the in-flight requests are kept.
+func (l *RateLimiter) Update(cfg RateLimitConfig) {
+	limits := &rateLimits{
+		maxInFlight:    int64(cfg.MaxInFlight),
+		clientKey:      strings.ToLower(cfg.ClientKey),
+		trustedProxies: parsePrefixes(cfg.TrustedProxies),
+		rules:          make(map[string]*methodLimiter, len(cfg.Rules)),
+	}
+
+	maxClients := cfg.MaxClients
+	if maxClients <= 0 {
+		maxClients = defaultMaxClients
+	}
+
+	for _, rule := range cfg.Rules {
+		m := &methodLimiter{
+			clientRate:  rate.Limit(rule.ClientRate),
+			clientBurst: burst(rule.ClientRate, rule.ClientBurst),
+			maxClients:  maxClients,
+			clients:     make(map[string]*clientLimiter),
+		}
+		if rule.Rate > 0 {
+			m.limiter = rate.NewLimiter(rate.Limit(rule.Rate), burst(rule.Rate, rule.Burst))
+		}
+
+		limits.rules[rule.Method] = m
+	}
+
+	l.limits.Store(limits)
+}
+
+// InFlight returns the number of in-flight requests.
+func (l *RateLimiter) InFlight() int64 {
+	return l.inFlight.Load()
+}
+
+// UnaryServerInterceptor returns a server unary interceptor which rejects the requests
+// over the limits with codes.ResourceExhausted and the retry-after trailer.
+func (l *RateLimiter) UnaryServerInterceptor() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo,
+		handler grpc.UnaryHandler) (interface{}, error) {
+		release, retryAfter, err := l.acquire(ctx, info.FullMethod)
+		if err != nil {
+			_ = grpc.SetTrailer(ctx, retryAfterMD(retryAfter))
+			return nil, err
+		}
+
+		defer release()
+		return handler(ctx, req)
+	}
+}
+
+// StreamServerInterceptor returns a server stream interceptor which rejects the streams
+// over the limits with codes.ResourceExhausted and the retry-after trailer.
+func (l *RateLimiter) StreamServerInterceptor() grpc.StreamServerInterceptor {
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		release, retryAfter, err := l.acquire(ss.Context(), info.FullMethod)
+		if err != nil {
+			ss.SetTrailer(retryAfterMD(retryAfter))
+			return err
+		}
+
+		defer release()
+		return handler(srv, ss)
+	}
+}
+
+// acquire takes a token of the method and the client,and an in-flight slot.
+func (l *RateLimiter) acquire(ctx context.Context, method string) (func(), time.Duration, error) {
+	limits := l.limits.Load()
+	n := l.inFlight.Add(1)
+	if limits.maxInFlight > 0 && n > limits.maxInFlight {
+		l.inFlight.Add(-1)
+		return nil, time.Second, status.Errorf(codes.ResourceExhausted,
+			"too many in-flight requests,max: %d", limits.maxInFlight)
+	}
+
+	if m := limits.rule(method); m != nil {
+		if delay := m.reserve(time.Now(), limits.clientID(ctx)); delay > 0 {
+			l.inFlight.Add(-1)
+			return nil, delay, status.Errorf(codes.ResourceExhausted,
+				"method %s rate limit exceeded,retry after %s", method, delay)
+		}
+	}
+
+	return func() {
+		l.inFlight.Add(-1)
+	}, 0, nil
+}
+
+// rule returns the most specific rule of the method.
+func (r *rateLimits) rule(method string) *methodLimiter {
+	if m, ok := r.rules[method]; ok {
+		return m
+	}
+
+	if i := strings.LastIndex(method, "/"); i > 0 {
+		if m, ok := r.rules[method[:i]+"/*"]; ok {
+			return m
+		}
+	}
+
+	return r.rules["*"]
+}
+
+// clientID returns the client identity from the metadata or the peer ip,
+// the x-forwarded-for addresses are used only when the peer is a trusted proxy.
+func (r *rateLimits) clientID(ctx context.Context) string {
+	md := IncomingMD(ctx)
+	if r.clientKey != "" {
+		if values := md.Get(r.clientKey); len(values) > 0 && values[0] != "" {
+			return values[0]
+		}
+	}
+
+	ip, _ := GetGRPCClientIP(ctx)
+	if !r.trusted(ip) {
+		return ip
+	}
+
+	// the proxies append the addresses to x-forwarded-for,eg: the http gateway,
+	// so the addresses are checked from right to left.
+	addresses := strings.Split(strings.Join(md.Get("x-forwarded-for"), ","), ",")
+	for i := len(addresses) - 1; i >= 0; i-- {
+		address := strings.TrimSpace(addresses[i])
+		if address == "" {
+			continue
+		}
+
+		ip = address
+		if !r.trusted(address) {
+			break
+		}
+	}
+
+	return ip
+}
+
+// trusted reports whether the ip is a trusted proxy.
+func (r *rateLimits) trusted(ip string) bool {
+	addr, err := netip.ParseAddr(ip)
+	if err != nil {
+		return false
+	}
+
+	addr = addr.Unmap()
+	for _, prefix := range r.trustedProxies {
+		if prefix.Contains(addr) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// parsePrefixes parses the ip or cidr values,the invalid values are ignored.
+func parsePrefixes(values []string) []netip.Prefix {
+	var prefixes []netip.Prefix
+	for _, v := range values {
+		if prefix, err := netip.ParsePrefix(v); err == nil {
+			prefixes = append(prefixes, prefix.Masked())
+			continue
+		}
+
+		if addr, err := netip.ParseAddr(v); err == nil {
+			addr = addr.Unmap()
+			prefixes = append(prefixes, netip.PrefixFrom(addr, addr.BitLen()))
+		}
+	}
+
+	return prefixes
+}
+
+// reserve takes a token of the method and the client,
+// returns the delay to wait for the tokens when they are not available.
+func (m *methodLimiter) reserve(now time.Time, client string) time.Duration {
+	var clientLimiter *rate.Limiter
+	if m.clientRate > 0 {
+		clientLimiter = m.client(now, client)
+	}
+
+	var reservations []*rate.Reservation
+	var delay time.Duration
+	for _, limiter := range []*rate.Limiter{clientLimiter, m.limiter} {
+		if limiter == nil {
+			continue
+		}
+
+		r := limiter.ReserveN(now, 1)
+		reservations = append(reservations, r)
+		if !r.OK() {
+			delay = max(delay, time.Second)
+		} else if d := r.DelayFrom(now); d > delay {
+			delay = d
+		}
+	}
+
+	if delay > 0 {
+		// the tokens are not taken by the rejected request
+		for _, r := range reservations {
+			r.CancelAt(now)
+		}
+	}
+
+	return delay
+}
+
+// client returns the token bucket of the client,the idle buckets are removed periodically.
+// The new clients share the overflow bucket when the number of the buckets reaches maxClients.
+func (m *methodLimiter) client(now time.Time, client string) *rate.Limiter {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if now.Sub(m.lastSweep) > defaultClientIdleTimeout {
+		for id, c := range m.clients {
+			if now.Sub(c.lastSeen) > defaultClientIdleTimeout {
+				delete(m.clients, id)
+			}
+		}
+
+		m.lastSweep = now
+	}
+
+	c, ok := m.clients[client]
+	if !ok {
+		if len(m.clients) >= m.maxClients {
+			if m.overflow == nil {
+				m.overflow = rate.NewLimiter(m.clientRate, m.clientBurst)
+			}
+
+			return m.overflow
+		}
+
+		c = &clientLimiter{limiter: rate.NewLimiter(m.clientRate, m.clientBurst)}
+		m.clients[client] = c
+	}
+
+	c.lastSeen = now
+	return c.limiter
+}
+
+// burst returns the bucket size,default: ceil(r)
+func burst(r float64, b int) int {
+	if b > 0 {
+		return b
+	}
+
+	return int(math.Ceil(r))
+}
+
+// retryAfterMD returns the metadata with the retry-after seconds,the minimum is 1 second.
+func retryAfterMD(d time.Duration) metadata.MD {
+	seconds := int64(math.Ceil(d.Seconds()))
+	if seconds < 1 {
+		seconds = 1
+	}
+
+	return metadata.Pairs(RetryAfterKey, strconv.FormatInt(seconds, 10))
+}
@@ -0,0 +1,197 @@
+package micro
+
+import (
+	"context"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+
+	"github.com/daheige/hephfx/example/pb"
+	"github.com/daheige/hephfx/settings"
+)
+
+// sayHelloAs calls SayHello with the x-client-id metadata,returns the error and the trailer.
+func sayHelloAs(conn *grpc.ClientConn, clientID string) (error, metadata.MD) {
+	ctx := metadata.AppendToOutgoingContext(context.Background(), "x-client-id", clientID)
+	var trailer metadata.MD
+	_, err := pb.NewGreeterClient(conn).SayHello(ctx, &pb.HelloReq{Name: "heige"}, grpc.Trailer(&trailer))
+	return err, trailer
+}
+
+func TestRateLimit(t *testing.T) {
+	l := NewRateLimiter(RateLimitConfig{
+		ClientKey: "X-Client-Id",
+		Rules: []RateLimitRule{
+			{Method: "/Hello.Greeter/SayHello", Rate: 0.1, Burst: 3, ClientRate: 0.1},
+			{Method: "/grpc.health.v1.Health/*", Rate: 0.1},
+		},
+	})
+	s := newTestService(t, &testGreeter{}, WithEnableHealthCheck(), WithRateLimiter(l))
+	conn := dialService(t, s)
+
+	if err, _ := sayHelloAs(conn, "a"); err != nil {
+		t.Fatalf("say hello error: %v", err)
+	}
+
+	// the client bucket of a is empty
+	err, trailer := sayHelloAs(conn, "a")
+	if status.Code(err) != codes.ResourceExhausted {
+		t.Fatalf("client a error = %v, want %s", err, codes.ResourceExhausted)
+	}
+	if values := trailer.Get(RetryAfterKey); len(values) != 1 || values[0] != "10" {
+		t.Fatalf("retry-after = %v, want 10", values)
+	}
+
+	if err, _ = sayHelloAs(conn, "b"); err != nil {
+		t.Fatalf("client b say hello error: %v", err)
+	}
+
+	// the rejected requests do not take the method tokens
+	if err, _ = sayHelloAs(conn, "c"); err != nil {
+		t.Fatalf("client c say hello error: %v", err)
+	}
+	if err, _ = sayHelloAs(conn, "d"); status.Code(err) != codes.ResourceExhausted {
+		t.Fatalf("client d error = %v, want %s", err, codes.ResourceExhausted)
+	}
+
+	// the stream is limited by the service rule
+	client := healthpb.NewHealthClient(conn)
+	for i, want := range []codes.Code{codes.OK, codes.ResourceExhausted} {
+		ctx, cancel := context.WithCancel(context.Background())
+		stream, err := client.Watch(ctx, &healthpb.HealthCheckRequest{})
+		if err == nil {
+			_, err = stream.Recv()
+		}
+		cancel()
+
+		if status.Code(err) != want {
+			t.Fatalf("watch %d error = %v, want %s", i, err, want)
+		}
+	}
+}
+
+func TestRateLimitMaxInFlight(t *testing.T) {
+	l := NewRateLimiter(RateLimitConfig{MaxInFlight: 1})
+	s := newTestService(t, &testGreeter{delay: 200 * time.Millisecond}, WithRateLimiter(l))
+	conn := dialService(t, s)
+
+	errChan := make(chan error, 1)
+	go func() {
+		err, _ := sayHelloAs(conn, "a")
+		errChan <- err
+	}()
+
+	deadline := time.Now().Add(3 * time.Second)
+	for l.InFlight() == 0 && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	err, trailer := sayHelloAs(conn, "b")
+	if status.Code(err) != codes.ResourceExhausted || len(trailer.Get(RetryAfterKey)) != 1 {
+		t.Fatalf("in-flight error = %v trailer = %v", err, trailer)
+	}
+
+	if err = <-errChan; err != nil {
+		t.Fatalf("say hello error: %v", err)
+	}
+	if n := l.InFlight(); n != 0 {
+		t.Fatalf("in-flight = %d, want 0", n)
+	}
+}
+
+func TestRateLimiterFromConfig(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "app.yaml")
+	writeConfig := func(maxInFlight string) {
+		content := "rate_limit:\n  max_in_flight: " + maxInFlight + "\n  rules:\n" +
+			"    - method: \"/Hello.Greeter/SayHello\"\n      rate: 10\n      burst: 20\n"
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	writeConfig("10")
+	conf, err := settings.Load(path, settings.WithWatchFile())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	l, err := NewRateLimiterFromConfig(conf, "rate_limit")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	limits := l.limits.Load()
+	if limits.maxInFlight != 10 || limits.rules["/Hello.Greeter/SayHello"].limiter.Burst() != 20 {
+		t.Fatalf("unexpected limits: %+v", limits)
+	}
+
+	// the limits are reloaded when the config file is changed
+	time.Sleep(100 * time.Millisecond)
+	writeConfig("20")
+	deadline := time.Now().Add(5 * time.Second)
+	for l.limits.Load().maxInFlight != 20 && time.Now().Before(deadline) {
+		time.Sleep(20 * time.Millisecond)
+	}
+
+	if n := l.limits.Load().maxInFlight; n != 20 {
+		t.Fatalf("max in-flight = %d after reload, want 20", n)
+	}
+}
+
+func TestRateLimitClientID(t *testing.T) {
+	l := NewRateLimiter(RateLimitConfig{TrustedProxies: []string{"127.0.0.1", "10.0.0.0/8", "invalid"}})
+	limits := l.limits.Load()
+	for _, c := range []struct {
+		peer string
+		xff  []string
+		want string
+	}{
+		{"203.0.113.1", []string{"198.51.100.1"}, "203.0.113.1"}, // untrusted peer
+		{"127.0.0.1", nil, "127.0.0.1"},
+		{"127.0.0.1", []string{"198.51.100.1"}, "198.51.100.1"},
+		{"127.0.0.1", []string{"1.1.1.1, 198.51.100.1", "10.0.0.2"}, "198.51.100.1"}, // spoofed first address
+		{"127.0.0.1", []string{"10.0.0.3,10.0.0.2"}, "10.0.0.3"},
+	} {
+		ctx := peer.NewContext(context.Background(), &peer.Peer{
+			Addr: &net.TCPAddr{IP: net.ParseIP(c.peer), Port: 50051},
+		})
+		if len(c.xff) > 0 {
+			ctx = metadata.NewIncomingContext(ctx, metadata.MD{"x-forwarded-for": c.xff})
+		}
+
+		if id := limits.clientID(ctx); id != c.want {
+			t.Fatalf("peer %s x-forwarded-for %v client id = %s, want %s", c.peer, c.xff, id, c.want)
+		}
+	}
+}
+
+func TestRateLimitMaxClients(t *testing.T) {
+	l := NewRateLimiter(RateLimitConfig{
+		MaxClients: 2,
+		Rules:      []RateLimitRule{{Method: "*", ClientRate: 0.1}},
+	})
+	m := l.limits.Load().rule("/Hello.Greeter/SayHello")
+	now := time.Now()
+	for _, client := range []string{"a", "b", "c", "d"} {
+		m.client(now, client)
+	}
+
+	if len(m.clients) != 2 || m.client(now, "c") != m.client(now, "d") {
+		t.Fatalf("clients = %d, want 2 and the new clients share the overflow bucket", len(m.clients))
+	}
+
+	// the idle buckets are removed,so the new clients get their own buckets
+	now = now.Add(2 * defaultClientIdleTimeout)
+	if m.client(now, "e") == m.overflow || len(m.clients) != 1 {
+		t.Fatalf("clients = %d after the idle buckets are removed, want 1", len(m.clients))
+	}
+}
@@ -0,0 +1,103 @@
+package micro
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"time"
+
+	"github.com/daheige/hephfx/hestia"
+)
+
+// defaultRegisterTimeout the timeout of registering a service instance.
+const defaultRegisterTimeout = 5 * time.Second
+
+// registerInstance registers a service instance for the bound listener address.
+// The instance is copied from the service registered by WithRegistry,
+// and its Address is filled in from the real listener address.
+func (s *Service) registerInstance(addr net.Addr, protocol hestia.ProtocolType) error {
+	if s.registry == nil || s.registryService == nil {
+		return nil
+	}
+
+	address, err := hestia.Resolve(addr.String())
+	if err != nil {
+		return fmt.Errorf("resolve %s listener address %s error: %w", protocol, addr.String(), err)
+	}
+
+	instance := s.newRegistryInstance(protocol)
+	instance.Network = addr.Network()
+	instance.Address = address
+
+	ctx, cancel := context.WithTimeout(context.Background(), defaultRegisterTimeout)
+	defer cancel()
+
+	err = s.registry.Register(ctx, instance)
+	if err != nil {
+		return fmt.Errorf("%s register service %s error: %w", s.registry.String(), instance.Name, err)
+	}
+
+	s.logger.Printf("%s register service:%s protocol:%s address:%s instanceID:%s success\n",
+		s.registry.String(), instance.Name, protocol, instance.Address, instance.InstanceID)
+
+	s.registryMu.Lock()
+	s.registeredInstances = append(s.registeredInstances, instance)
+	s.registryMu.Unlock()
+
+	return nil
+}
+
+// newRegistryInstance returns a copy of the service registered by WithRegistry.
+func (s *Service) newRegistryInstance(protocol hestia.ProtocolType) *hestia.Service {
+	instance := *s.registryService
+	instance.Protocol = protocol
+	instance.Metadata = make(map[string]interface{}, len(s.registryService.Metadata))
+	for k, v := range s.registryService.Metadata {
+		instance.Metadata[k] = v
+	}
+
+	instance.Tags = make(map[string]string, len(s.registryService.Tags))
+	for k, v := range s.registryService.Tags {
+		instance.Tags[k] = v
+	}
+
+	// the http gateway instance must not share the instance id with the gRPC instance
+	if protocol == hestia.ProtocolHTTP && instance.InstanceID != "" {
+		instance.InstanceID += "-http"
+	}
+
+	return &instance
+}
+
+// deregisterInstances deregisters all the registered service instances.
+// It runs as a pre-drain shutdown hook,so it is done before the gRPC server graceful stop.
+func (s *Service) deregisterInstances(ctx context.Context) error {
+	// the new process registers the same instances when the service is restarted gracefully,
+	// deregistering them would remove the instances of the new process.
+	if s.restarted.Load() && s.registryService.InstanceID != "" {
+		s.logger.Printf("%s skip deregister service:%s,the instances are taken over by the new process\n",
+			s.registry.String(), s.registryService.Name)
+		return nil
+	}
+
+	s.registryMu.Lock()
+	instances := s.registeredInstances
+	s.registeredInstances = nil
+	s.registryMu.Unlock()
+
+	var errs []error
+	for _, instance := range instances {
+		err := s.registry.Deregister(ctx, instance)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s deregister service %s protocol:%s error: %w",
+				s.registry.String(), instance.Name, instance.Protocol, err))
+			continue
+		}
+
+		s.logger.Printf("%s deregister service:%s protocol:%s instanceID:%s success\n",
+			s.registry.String(), instance.Name, instance.Protocol, instance.InstanceID)
+	}
+
+	return errors.Join(errs...)
+}
@@ -0,0 +1,132 @@
+package micro
+
+import (
+	"context"
+	"net"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/daheige/hephfx/hestia"
+)
+
+// fakeRegistry records the register and deregister calls.
+type fakeRegistry struct {
+	mu     sync.Mutex
+	events []string
+	online map[string]*hestia.Service
+}
+
+func newFakeRegistry() *fakeRegistry {
+	return &fakeRegistry{online: make(map[string]*hestia.Service)}
+}
+
+func (r *fakeRegistry) Register(_ context.Context, s *hestia.Service) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.events = append(r.events, "register:"+string(s.Protocol))
+	r.online[string(s.Protocol)] = s
+	return nil
+}
+
+func (r *fakeRegistry) Deregister(_ context.Context, s *hestia.Service) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.events = append(r.events, "deregister:"+string(s.Protocol))
+	delete(r.online, string(s.Protocol))
+	return nil
+}
+
+func (r *fakeRegistry) String() string {
+	return "fake"
+}
+
+func (r *fakeRegistry) record(event string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.events = append(r.events, event)
+}
+
+func TestWithRegistry(t *testing.T) {
+	address := freeAddress(t)
+	httpAddress := freeAddress(t)
+	reg := newFakeRegistry()
+	s := NewService(
+		address,
+		WithGRPCHTTPAddress(httpAddress),
+		WithShutdownTimeout(time.Second),
+		WithRegistry(reg, &hestia.Service{Name: "greeter", InstanceID: "greeter-1", Version: "v1"}),
+		WithShutdownHook(PhaseAfterHTTP, "after-http", func(ctx context.Context) error {
+			reg.record("after-http")
+			return nil
+		}),
+	)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	errChan := make(chan error, 1)
+	go func() {
+		errChan <- s.RunContext(ctx)
+	}()
+	waitListening(t, address)
+	waitListening(t, httpAddress)
+
+	// the service is registered after the listener is bound,so wait for it
+	deadline := time.Now().Add(3 * time.Second)
+	for {
+		reg.mu.Lock()
+		n := len(reg.online)
+		reg.mu.Unlock()
+		if n == 2 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("service instances are not registered")
+		}
+
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	reg.mu.Lock()
+	grpcInstance := reg.online[string(hestia.ProtocolGRPC)]
+	httpInstance := reg.online[string(hestia.ProtocolHTTP)]
+	reg.mu.Unlock()
+
+	_, port, _ := net.SplitHostPort(address)
+	if _, got, _ := net.SplitHostPort(grpcInstance.Address); got != port {
+		t.Fatalf("gRPC instance address = %s, want port %s", grpcInstance.Address, port)
+	}
+	_, httpPort, _ := net.SplitHostPort(httpAddress)
+	if _, got, _ := net.SplitHostPort(httpInstance.Address); got != httpPort {
+		t.Fatalf("http instance address = %s, want port %s", httpInstance.Address, httpPort)
+	}
+	if grpcInstance.InstanceID != "greeter-1" || httpInstance.InstanceID != "greeter-1-http" {
+		t.Fatalf("unexpected instance ids: %s %s", grpcInstance.InstanceID, httpInstance.InstanceID)
+	}
+
+	cancel()
+	if err := <-errChan; err != nil {
+		t.Fatalf("run context error: %v", err)
+	}
+
+	reg.mu.Lock()
+	defer reg.mu.Unlock()
+	if len(reg.online) != 0 {
+		t.Fatalf("service instances are not deregistered: %v", reg.online)
+	}
+
+	// deregister must be done before the servers stop
+	events := reg.events[2:]
+	want := []string{"deregister:GRPC", "deregister:HTTP", "after-http"}
+	if reg.events[2] != "deregister:GRPC" {
+		want = []string{"deregister:HTTP", "deregister:GRPC", "after-http"}
+	}
+	if !reflect.DeepEqual(events, want) {
+		t.Fatalf("events = %v, want %v", events, want)
+	}
+}
@@ -0,0 +1,314 @@
+package micro
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+)
+
+const (
+	// restartListenersEnv the keys of the listeners inherited from the parent process,
+	// the listener file descriptors start from 3 in the same order.
+	restartListenersEnv = "HEPHFX_RESTART_LISTENERS"
+
+	// restartReadyFdEnv the file descriptor which the new process writes to when it is ready.
+	restartReadyFdEnv = "HEPHFX_RESTART_READY_FD"
+
+	// defaultRestartTimeout the timeout of waiting for the new process to be ready.
+	defaultRestartTimeout = 30 * time.Second
+)
+
+var (
+	// ErrServiceNotRunning is returned when restarting a service which is not running.
+	ErrServiceNotRunning = errors.New("service is not running")
+
+	// ErrRestartInProgress is returned when the service is already restarting.
+	ErrRestartInProgress = errors.New("service restart is in progress")
+)
+
+// boundListener is a listener bound by the service,it is handed over to the new process on restart.
+type boundListener struct {
+	key      string // network://address
+	listener net.Listener
+}
+
+// inherited holds the listeners inherited from the parent process.
+var inherited struct {
+	once      sync.Once
+	mu        sync.Mutex
+	listeners map[string]net.Listener
+	ready     *os.File // notify the parent process that the new process is ready
+	err       error
+}
+
+// loadInherited loads the listeners inherited from the parent process once.
+func loadInherited() {
+	inherited.once.Do(func() {
+		keys := os.Getenv(restartListenersEnv)
+		readyFd := os.Getenv(restartReadyFdEnv)
+		_ = os.Unsetenv(restartListenersEnv)
+		_ = os.Unsetenv(restartReadyFdEnv)
+		if readyFd == "" {
+			return
+		}
+
+		fd, err := strconv.Atoi(readyFd)
+		if err != nil {
+			inherited.err = fmt.Errorf("invalid %s: %s", restartReadyFdEnv, readyFd)
+			return
+		}
+
+		syscall.CloseOnExec(fd)
+		inherited.ready = os.NewFile(uintptr(fd), "restart-ready")
+		if keys == "" {
+			return
+		}
+
+		names := strings.Split(keys, "\n")
+		listeners, err := filesListeners(systemdListenFdsStart, len(names), names)
+		if err != nil {
+			inherited.err = err
+			return
+		}
+
+		inherited.listeners = make(map[string]net.Listener, len(listeners))
+		for _, l := range listeners {
+			inherited.listeners[l.Name] = l.Listener
+		}
+	})
+}
+
+// takeInheritedListener returns the listener inherited from the parent process,
+// it returns nil when there is no such listener.
+func takeInheritedListener(key string) (net.Listener, error) {
+	loadInherited()
+
+	inherited.mu.Lock()
+	defer inherited.mu.Unlock()
+
+	if inherited.err != nil {
+		return nil, inherited.err
+	}
+
+	ln := inherited.listeners[key]
+	delete(inherited.listeners, key)
+	return ln, nil
+}
+
+// bind returns the listener inherited from the parent process when the service is restarted
+// gracefully, otherwise it listens on the address.
+func (s *Service) bind(network, address string) (net.Listener, error) {
+	key := network + "://" + address
+	ln, err := takeInheritedListener(key)
+	if err != nil {
+		return nil, err
+	}
+
+	if ln != nil {
+		s.logger.Printf("use the listener %s inherited from the parent process\n", key)
+	} else {
+		if network == "unix" {
+			removeStaleSocket(address)
+		}
+
+		ln, err = net.Listen(network, address)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	s.boundMu.Lock()
+	s.boundListeners = append(s.boundListeners, boundListener{key: key, listener: ln})
+	s.boundMu.Unlock()
+
+	return ln, nil
+}
+
+// notifyReady tells the parent process that the service is ready when it is restarted gracefully,
+// and closes the inherited listeners which are not used any more.
+func (s *Service) notifyReady() {
+	loadInherited()
+
+	inherited.mu.Lock()
+	defer inherited.mu.Unlock()
+
+	for key, ln := range inherited.listeners {
+		s.logger.Printf("close the unused listener %s inherited from the parent process\n", key)
+		_ = ln.Close()
+	}
+	inherited.listeners = nil
+
+	if inherited.ready == nil {
+		return
+	}
+
+	_, err := inherited.ready.Write([]byte{1})
+	if err != nil {
+		s.logger.Printf("notify the parent process ready error: %v\n", err)
+	} else {
+		s.logger.Printf("notify the parent process pid:%d ready\n", os.Getppid())
+	}
+
+	_ = inherited.ready.Close()
+	inherited.ready = nil
+}
+
+// Restart restarts the service gracefully without dropping connections.
+// It starts a new process of the same executable with the bound listeners,
+// and waits until the new process is ready, then the service is stopped gracefully
+// and Run returns after the in-flight requests are done.
+// If the new process is not ready before ctx is done, it is killed and the service keeps running.
+// Note: the pre-created listeners of WithListeners are not handed over.
+func (s *Service) Restart(ctx context.Context) error {
+	if !s.running.Load() {
+		return ErrServiceNotRunning
+	}
+
+	if !s.restarting.CompareAndSwap(false, true) {
+		return ErrRestartInProgress
+	}
+
+	success := false
+	defer func() {
+		if !success {
+			s.restarting.Store(false)
+		}
+	}()
+
+	s.boundMu.Lock()
+	bound := append([]boundListener(nil), s.boundListeners...)
+	s.boundMu.Unlock()
+
+	files := make([]*os.File, 0, len(bound)+1)
+	keys := make([]string, 0, len(bound))
+	defer func() {
+		for _, f := range files {
+			_ = f.Close()
+		}
+	}()
+
+	for _, b := range bound {
+		filer, ok := b.listener.(interface{ File() (*os.File, error) })
+		if !ok {
+			continue
+		}
+
+		f, err := filer.File()
+		if err != nil {
+			return fmt.Errorf("listener %s file error: %w", b.key, err)
+		}
+
+		files = append(files, f)
+		keys = append(keys, b.key)
+	}
+
+	r, w, err := os.Pipe()
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+
+	cmd, err := s.restartCommand(keys)
+	if err != nil {
+		_ = w.Close()
+		return err
+	}
+
+	cmd.ExtraFiles = append(files, w)
+	err = cmd.Start()
+	_ = w.Close()
+	if err != nil {
+		return fmt.Errorf("start new process error: %w", err)
+	}
+
+	pid := cmd.Process.Pid
+	s.logger.Printf("start new process pid:%d\n", pid)
+	go func() {
+		_ = cmd.Wait()
+	}()
+
+	// the read returns io.EOF when the new process exits before it is ready
+	ready := make(chan error, 1)
+	go func() {
+		_, err := r.Read(make([]byte, 1))
+		ready <- err
+	}()
+
+	select {
+	case err = <-ready:
+	case <-ctx.Done():
+		err = ctx.Err()
+	}
+
+	if err != nil {
+		_ = cmd.Process.Kill()
+		return fmt.Errorf("new process pid:%d is not ready: %w", pid, err)
+	}
+
+	// the unix socket files are used by the new process
+	for _, b := range bound {
+		if ul, ok := b.listener.(*net.UnixListener); ok {
+			ul.SetUnlinkOnClose(false)
+		}
+	}
+
+	success = true
+	s.restarted.Store(true)
+	s.logger.Printf("new process pid:%d is ready,stop the service gracefully\n", pid)
+	s.quitOnce.Do(func() { close(s.quit) })
+	return nil
+}
+
+// restart restarts the service with the restart timeout,the error is logged.
+func (s *Service) restart(ctx context.Context) {
+	ctx, cancel := context.WithTimeout(ctx, s.restartTimeout)
+	defer cancel()
+
+	err := s.Restart(ctx)
+	if err != nil {
+		s.logger.Printf("restart service error: %v\n", err)
+	}
+}
+
+// restartCommand returns the command of the new process.
+func (s *Service) restartCommand(keys []string) (*exec.Cmd, error) {
+	path, err := os.Executable()
+	if err != nil {
+		return nil, fmt.Errorf("get executable path error: %w", err)
+	}
+
+	args := os.Args[1:]
+	if s.restartArgs != nil {
+		args = s.restartArgs
+	}
+
+	env := make([]string, 0, len(os.Environ())+2)
+	for _, e := range os.Environ() {
+		if strings.HasPrefix(e, restartListenersEnv+"=") || strings.HasPrefix(e, restartReadyFdEnv+"=") {
+			continue
+		}
+
+		env = append(env, e)
+	}
+
+	env = append(env,
+		restartListenersEnv+"="+strings.Join(keys, "\n"),
+		// the ready file descriptor follows the listener file descriptors
+		restartReadyFdEnv+"="+strconv.Itoa(systemdListenFdsStart+len(keys)),
+	)
+
+	cmd := exec.Command(path, args...)
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	cmd.Env = env
+	return cmd, nil
+}
@@ -0,0 +1,176 @@
+package micro
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+
+	"github.com/daheige/hephfx/example/pb"
+)
+
+// restartTestEnv is set to the addresses of the service when TestGracefulRestart restarts.
+const restartTestEnv = "HEPHFX_TEST_RESTART"
+
+func newRestartService(address, httpAddress, sock string) *Service {
+	s := NewService(
+		address,
+		WithGRPCHTTPAddress(httpAddress),
+		WithShutdownTimeout(time.Second),
+		WithHandlerFromEndpoints(pb.RegisterGreeterHandlerFromEndpoint),
+		WithListeners(Listener{Network: "unix", Address: sock}),
+	)
+	// the reply message has the process id
+	pb.RegisterGreeterServer(s.GRPCServer, &testGreeter{suffix: fmt.Sprintf(" pid:%d", os.Getpid())})
+	s.restartArgs = []string{"-test.run=^TestGracefulRestart$"}
+	return s
+}
+
+// runRestartChild runs the service in the new process until SIGTERM is received.
+func runRestartChild(config string) {
+	addresses := strings.Split(config, ",")
+	s := newRestartService(addresses[0], addresses[1], addresses[2])
+
+	// the new process must not outlive the test
+	timer := time.AfterFunc(10*time.Second, func() {
+		_ = s.Shutdown(context.Background())
+	})
+	defer timer.Stop()
+
+	code := 0
+	if err := s.Run(); err != nil {
+		code = 1
+	}
+
+	os.Exit(code)
+}
+
+// greeterPid returns the process id which replies the SayHello request.
+func greeterPid(t *testing.T, target string) int {
+	t.Helper()
+
+	conn, err := grpc.NewClient(target, grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	reply, err := pb.NewGreeterClient(conn).SayHello(context.Background(), &pb.HelloReq{Name: "heige"})
+	if err != nil {
+		t.Fatalf("%s say hello error: %v", target, err)
+	}
+
+	return parsePid(t, reply.GetMessage())
+}
+
+func parsePid(t *testing.T, message string) int {
+	t.Helper()
+
+	_, after, ok := strings.Cut(message, "pid:")
+	if !ok {
+		t.Fatalf("unexpected reply message: %s", message)
+	}
+
+	pid, err := strconv.Atoi(strings.Trim(after, `"}`+"\n"))
+	if err != nil {
+		t.Fatalf("unexpected reply message: %s", message)
+	}
+
+	return pid
+}
+
+func httpGreeterPid(t *testing.T, address string) int {
+	t.Helper()
+
+	resp, err := http.Get("http://" + address + "/v1/say/heige")
+	if err != nil {
+		t.Fatalf("gateway request error: %v", err)
+	}
+	defer resp.Body.Close()
+
+	body, _ := io.ReadAll(resp.Body)
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("gateway response code:%d body:%s", resp.StatusCode, body)
+	}
+
+	return parsePid(t, string(body))
+}
+
+func TestGracefulRestart(t *testing.T) {
+	if config := os.Getenv(restartTestEnv); config != "" && os.Getenv(restartReadyFdEnv) != "" {
+		runRestartChild(config)
+		return
+	}
+
+	if runtime.GOOS != "linux" {
+		t.Skip("graceful restart is tested on linux")
+	}
+
+	address := freeAddress(t)
+	httpAddress := freeAddress(t)
+	sock := filepath.Join(t.TempDir(), "grpc.sock")
+	t.Setenv(restartTestEnv, strings.Join([]string{address, httpAddress, sock}, ","))
+
+	s := newRestartService(address, httpAddress, sock)
+	errChan := make(chan error, 1)
+	go func() {
+		errChan <- s.RunContext(context.Background())
+	}()
+	waitListening(t, address)
+	waitListening(t, httpAddress)
+
+	parentPid := os.Getpid()
+	if pid := greeterPid(t, address); pid != parentPid {
+		t.Fatalf("reply pid = %d, want %d", pid, parentPid)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if err := s.Restart(ctx); err != nil {
+		t.Fatalf("restart error: %v", err)
+	}
+
+	// the old process drains and stops
+	select {
+	case err := <-errChan:
+		if err != nil {
+			t.Fatalf("run context error: %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("the old process is not stopped after restart")
+	}
+
+	// the new process serves on the inherited listeners
+	childPid := greeterPid(t, address)
+	if childPid == parentPid {
+		t.Fatalf("reply pid = %d, want the new process pid", childPid)
+	}
+	defer func() {
+		_ = syscall.Kill(childPid, syscall.SIGTERM)
+	}()
+
+	if pid := greeterPid(t, "unix://"+sock); pid != childPid {
+		t.Fatalf("unix socket reply pid = %d, want %d", pid, childPid)
+	}
+	if pid := httpGreeterPid(t, httpAddress); pid != childPid {
+		t.Fatalf("gateway reply pid = %d, want %d", pid, childPid)
+	}
+}
+
+func TestRestartNotRunning(t *testing.T) {
+	s := NewService(freeAddress(t))
+	if err := s.Restart(context.Background()); err != ErrServiceNotRunning {
+		t.Fatalf("restart error = %v, want %v", err, ErrServiceNotRunning)
+	}
+}
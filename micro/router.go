@@ -1,12 +1,189 @@
 package micro
 
 import (
+	"context"
+	"encoding/json"
+	"errors"
 	"net/http"
+	"strings"
+
+	"github.com/daheige/hephfx/ctxkeys"
 )
 
+// debugRoutesPath the http gateway route list path
+const debugRoutesPath = "/debug/routes"
+
+// ErrRouteHandlerNil the route handler is nil
+var ErrRouteHandlerNil = errors.New("route handler is nil")
+
 // Route represents the route for mux
+// Path supports the path params of the grpc-gateway path template,eg: /v1/users/{id}
 type Route struct {
 	Method  string
 	Path    string
 	Handler http.HandlerFunc
+
+	// Name the route name shown in the route list,it is optional
+	Name string
+
+	// Middlewares wrap the handler,the first one is the outermost
+	Middlewares []HTTPMiddleware
+}
+
+// RouteInfo the route shown in the route list.
+type RouteInfo struct {
+	Method string `json:"method"`
+	Path   string `json:"path"`
+	Name   string `json:"name,omitempty"`
+}
+
+// PathParams returns the path params of the route,eg: /v1/users/{id} => map[id:1]
+func PathParams(r *http.Request) map[string]string {
+	params, _ := r.Context().Value(ctxkeys.PathParams).(map[string]string)
+	return params
+}
+
+// PathParam returns the path param of the route by name.
+func PathParam(r *http.Request, name string) string {
+	return PathParams(r)[name]
+}
+
+// RouteGroup the routes with a shared path prefix and middlewares.
+type RouteGroup struct {
+	prefix      string
+	middlewares []HTTPMiddleware
+	routes      []Route
+	groups      []*RouteGroup
+}
+
+// NewRouteGroup returns a route group,the middlewares wrap all routes of the group.
+//
+//	api := micro.NewRouteGroup("/api/v1", authMiddleware)
+//	api.GET("/users/{id}", getUser)
+//	admin := api.Group("/admin", adminMiddleware)
+//	admin.DELETE("/users/{id}", deleteUser)
+func NewRouteGroup(prefix string, middlewares ...HTTPMiddleware) *RouteGroup {
+	return &RouteGroup{prefix: prefix, middlewares: middlewares}
+}
+
+// Use appends the middlewares of the group.
+func (g *RouteGroup) Use(middlewares ...HTTPMiddleware) *RouteGroup {
+	g.middlewares = append(g.middlewares, middlewares...)
+	return g
+}
+
+// Group creates a sub group which inherits the prefix and the middlewares of the group.
+func (g *RouteGroup) Group(prefix string, middlewares ...HTTPMiddleware) *RouteGroup {
+	sub := NewRouteGroup(prefix, middlewares...)
+	g.groups = append(g.groups, sub)
+	return sub
+}
+
+// Add adds the routes to the group.
+func (g *RouteGroup) Add(routes ...Route) *RouteGroup {
+	g.routes = append(g.routes, routes...)
+	return g
+}
+
+// Handle adds a route to the group.
+func (g *RouteGroup) Handle(method string, path string, handler http.HandlerFunc,
+	middlewares ...HTTPMiddleware) *RouteGroup {
+	return g.Add(Route{Method: method, Path: path, Handler: handler, Middlewares: middlewares})
+}
+
+// GET adds a GET route to the group.
+func (g *RouteGroup) GET(path string, handler http.HandlerFunc, middlewares ...HTTPMiddleware) *RouteGroup {
+	return g.Handle(http.MethodGet, path, handler, middlewares...)
+}
+
+// POST adds a POST route to the group.
+func (g *RouteGroup) POST(path string, handler http.HandlerFunc, middlewares ...HTTPMiddleware) *RouteGroup {
+	return g.Handle(http.MethodPost, path, handler, middlewares...)
+}
+
+// PUT adds a PUT route to the group.
+func (g *RouteGroup) PUT(path string, handler http.HandlerFunc, middlewares ...HTTPMiddleware) *RouteGroup {
+	return g.Handle(http.MethodPut, path, handler, middlewares...)
+}
+
+// PATCH adds a PATCH route to the group.
+func (g *RouteGroup) PATCH(path string, handler http.HandlerFunc, middlewares ...HTTPMiddleware) *RouteGroup {
+	return g.Handle(http.MethodPatch, path, handler, middlewares...)
+}
+
+// DELETE adds a DELETE route to the group.
+func (g *RouteGroup) DELETE(path string, handler http.HandlerFunc, middlewares ...HTTPMiddleware) *RouteGroup {
+	return g.Handle(http.MethodDelete, path, handler, middlewares...)
+}
+
+// Routes returns the routes of the group and the sub groups,
+// the paths are prefixed and the group middlewares are placed before the route middlewares.
+func (g *RouteGroup) Routes() []Route {
+	routes := append([]Route{}, g.routes...)
+	for _, sub := range g.groups {
+		routes = append(routes, sub.Routes()...)
+	}
+
+	for i := range routes {
+		routes[i].Path = joinRoutePath(g.prefix, routes[i].Path)
+		routes[i].Middlewares = append(append([]HTTPMiddleware{}, g.middlewares...), routes[i].Middlewares...)
+	}
+
+	return routes
+}
+
+// joinRoutePath joins the prefix and the path,eg: /api/ + users => /api/users
+func joinRoutePath(prefix string, path string) string {
+	prefix = strings.TrimSuffix(prefix, "/")
+	if path == "" || path == "/" {
+		if prefix == "" {
+			return "/"
+		}
+
+		return prefix
+	}
+
+	return prefix + "/" + strings.TrimPrefix(path, "/")
+}
+
+// routeHandler returns the handler of the route wrapped by the middlewares.
+func routeHandler(route Route) http.Handler {
+	var h http.Handler = route.Handler
+	for i := len(route.Middlewares) - 1; i >= 0; i-- {
+		h = route.Middlewares[i](h)
+	}
+
+	return h
+}
+
+// handlePath registers the handler on the gateway mux and records it in the route list,
+// the path params are stored in the request context.
+func (s *Service) handlePath(method string, path string, name string, h http.Handler) error {
+	err := s.mux.HandlePath(method, path, func(w http.ResponseWriter, r *http.Request, params map[string]string) {
+		if len(params) > 0 {
+			r = r.WithContext(context.WithValue(r.Context(), ctxkeys.PathParams, params))
+		}
+
+		h.ServeHTTP(w, r)
+	})
+	if err != nil {
+		return err
+	}
+
+	s.routeInfos = append(s.routeInfos, RouteInfo{Method: method, Path: path, Name: name})
+	return nil
+}
+
+// registerDebugRoutes registers the route list endpoint,
+// it lists the custom routes and the builtin routes of the gateway.
+func (s *Service) registerDebugRoutes() error {
+	return s.handlePath(http.MethodGet, debugRoutesPath, "debug.routes", http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			b, _ := json.Marshal(map[string]interface{}{
+				"routes": s.routeInfos,
+			})
+
+			w.Header().Set("Content-Type", "application/json")
+			_, _ = w.Write(b)
+		}))
 }
@@ -0,0 +1,93 @@
+package micro
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+)
+
+func headerMiddleware(value string) HTTPMiddleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Add("X-Middleware", value)
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+func TestRouteGroups(t *testing.T) {
+	api := NewRouteGroup("/api/v1/", headerMiddleware("api"))
+	api.GET("/users/{id}", func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("user:" + PathParam(r, "id")))
+	}, headerMiddleware("route"))
+
+	admin := api.Group("admin", headerMiddleware("admin"))
+	admin.DELETE("/users/{id}/tags/{tag}", func(w http.ResponseWriter, r *http.Request) {
+		params := PathParams(r)
+		_, _ = w.Write([]byte(params["id"] + ":" + params["tag"]))
+	})
+
+	s := NewService("", WithEnableInProcessGateway(), WithEnableHealthCheck(), WithEnableDebugRoutes(),
+		WithRoutes(Route{Method: http.MethodGet, Path: "ping", Name: "ping",
+			Handler: func(w http.ResponseWriter, r *http.Request) {
+				_, _ = w.Write([]byte("pong"))
+			}}),
+		WithRouteGroups(api),
+	)
+	h, err := s.HTTPHandler()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cases := []struct {
+		method      string
+		path        string
+		body        string
+		middlewares []string
+	}{
+		{http.MethodGet, "/ping", "pong", nil},
+		{http.MethodGet, "/api/v1/users/1", "user:1", []string{"api", "route"}},
+		{http.MethodDelete, "/api/v1/admin/users/2/tags/vip", "2:vip", []string{"api", "admin"}},
+	}
+	for _, c := range cases {
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, httptest.NewRequest(c.method, c.path, nil))
+		if rec.Code != http.StatusOK || rec.Body.String() != c.body {
+			t.Fatalf("%s %s = %d %s", c.method, c.path, rec.Code, rec.Body.String())
+		}
+
+		if middlewares := rec.Header().Values("X-Middleware"); !reflect.DeepEqual(middlewares, c.middlewares) {
+			t.Fatalf("%s %s middlewares = %v", c.method, c.path, middlewares)
+		}
+	}
+
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, debugRoutesPath, nil))
+	var list struct {
+		Routes []RouteInfo `json:"routes"`
+	}
+	if err = json.Unmarshal(rec.Body.Bytes(), &list); err != nil {
+		t.Fatal(err)
+	}
+
+	want := []RouteInfo{
+		{Method: http.MethodGet, Path: "/healthz", Name: "health.liveness"},
+		{Method: http.MethodGet, Path: "/readyz", Name: "health.readiness"},
+		{Method: http.MethodGet, Path: "/ping", Name: "ping"},
+		{Method: http.MethodGet, Path: "/api/v1/users/{id}"},
+		{Method: http.MethodDelete, Path: "/api/v1/admin/users/{id}/tags/{tag}"},
+		{Method: http.MethodGet, Path: debugRoutesPath, Name: "debug.routes"},
+	}
+	if !reflect.DeepEqual(list.Routes, want) {
+		t.Fatalf("routes = %v", list.Routes)
+	}
+}
+
+func TestRouteHandlerNil(t *testing.T) {
+	s := NewService("", WithEnableInProcessGateway(), WithRoutes(Route{Method: http.MethodGet, Path: "/nil"}))
+	if _, err := s.HTTPHandler(); err != ErrRouteHandlerNil {
+		t.Fatalf("expected ErrRouteHandlerNil,got:%v", err)
+	}
+}
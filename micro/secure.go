@@ -0,0 +1,146 @@
+package micro
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"google.golang.org/genproto/googleapis/rpc/code"
+
+	"github.com/daheige/hephfx/micro/gerrors"
+)
+
+// SecurityOption security headers middleware option
+type SecurityOption func(s *securityHeaders)
+
+// securityHeaders the standard security headers of the http gateway responses,
+// the empty value disables the header.
+type securityHeaders struct {
+	contentTypeOptions    string
+	frameOptions          string
+	referrerPolicy        string
+	contentSecurityPolicy string
+	hstsMaxAge            time.Duration
+	hstsIncludeSubDomains bool
+	headers               map[string]string
+}
+
+// WithFrameOptions returns a SecurityOption to set the X-Frame-Options header,default: DENY
+func WithFrameOptions(value string) SecurityOption {
+	return func(s *securityHeaders) {
+		s.frameOptions = value
+	}
+}
+
+// WithReferrerPolicy returns a SecurityOption to set the Referrer-Policy header,
+// default: strict-origin-when-cross-origin
+func WithReferrerPolicy(value string) SecurityOption {
+	return func(s *securityHeaders) {
+		s.referrerPolicy = value
+	}
+}
+
+// WithContentSecurityPolicy returns a SecurityOption to set the Content-Security-Policy header,
+// eg: default-src 'self'
+func WithContentSecurityPolicy(value string) SecurityOption {
+	return func(s *securityHeaders) {
+		s.contentSecurityPolicy = value
+	}
+}
+
+// WithHSTS returns a SecurityOption to set the Strict-Transport-Security header of the https requests,
+// default: max-age=31536000
+func WithHSTS(maxAge time.Duration, includeSubDomains bool) SecurityOption {
+	return func(s *securityHeaders) {
+		s.hstsMaxAge = maxAge
+		s.hstsIncludeSubDomains = includeSubDomains
+	}
+}
+
+// WithSecurityHeader returns a SecurityOption to set a custom header,
+// eg: Cross-Origin-Opener-Policy: same-origin
+func WithSecurityHeader(key string, value string) SecurityOption {
+	return func(s *securityHeaders) {
+		s.headers[key] = value
+	}
+}
+
+// SecurityHeadersMiddleware returns the http middleware which sets the standard security headers:
+// X-Content-Type-Options,X-Frame-Options,Referrer-Policy,Content-Security-Policy and
+// Strict-Transport-Security (https only).
+func SecurityHeadersMiddleware(opts ...SecurityOption) HTTPMiddleware {
+	s := &securityHeaders{
+		contentTypeOptions: "nosniff",
+		frameOptions:       "DENY",
+		referrerPolicy:     "strict-origin-when-cross-origin",
+		hstsMaxAge:         365 * 24 * time.Hour,
+		headers:            make(map[string]string),
+	}
+	for _, o := range opts {
+		o(s)
+	}
+
+	headers := map[string]string{
+		"X-Content-Type-Options":  s.contentTypeOptions,
+		"X-Frame-Options":         s.frameOptions,
+		"Referrer-Policy":         s.referrerPolicy,
+		"Content-Security-Policy": s.contentSecurityPolicy,
+	}
+	for key, value := range s.headers {
+		headers[key] = value
+	}
+
+	hsts := ""
+	if s.hstsMaxAge > 0 {
+		hsts = "max-age=" + strconv.Itoa(int(s.hstsMaxAge.Seconds()))
+		if s.hstsIncludeSubDomains {
+			hsts += "; includeSubDomains"
+		}
+	}
+
+	return func(h http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			for key, value := range headers {
+				if value != "" {
+					w.Header().Set(key, value)
+				}
+			}
+
+			if hsts != "" && r.TLS != nil {
+				w.Header().Set("Strict-Transport-Security", hsts)
+			}
+
+			h.ServeHTTP(w, r)
+		})
+	}
+}
+
+// BodyLimitMiddleware returns the http middleware which limits the request body size,
+// the requests whose Content-Length exceeds the limit are rejected with 413,
+// the chunked request bodies fail to read after the limit is reached.
+func BodyLimitMiddleware(limit int64) HTTPMiddleware {
+	return func(h http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.ContentLength > limit {
+				b, _ := json.Marshal(&gerrors.HTTPError{
+					Code:    int(code.Code_RESOURCE_EXHAUSTED),
+					Status:  code.Code_RESOURCE_EXHAUSTED.String(),
+					Message: fmt.Sprintf("request body too large,limit: %d bytes", limit),
+				})
+
+				w.Header().Set("Content-Type", "application/json")
+				w.WriteHeader(http.StatusRequestEntityTooLarge)
+				_, _ = w.Write(b)
+				return
+			}
+
+			if r.Body != nil && r.Body != http.NoBody {
+				r.Body = http.MaxBytesReader(w, r.Body, limit)
+			}
+
+			h.ServeHTTP(w, r)
+		})
+	}
+}
@@ -0,0 +1,74 @@
+package micro
+
+import (
+	"crypto/tls"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSecurityHeadersMiddleware(t *testing.T) {
+	h := SecurityHeadersMiddleware(
+		WithFrameOptions(""),
+		WithContentSecurityPolicy("default-src 'self'"),
+		WithHSTS(time.Hour, true),
+		WithSecurityHeader("Cross-Origin-Opener-Policy", "same-origin"),
+	)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, r)
+
+	want := map[string]string{
+		"X-Content-Type-Options":     "nosniff",
+		"X-Frame-Options":            "",
+		"Referrer-Policy":            "strict-origin-when-cross-origin",
+		"Content-Security-Policy":    "default-src 'self'",
+		"Cross-Origin-Opener-Policy": "same-origin",
+		"Strict-Transport-Security":  "",
+	}
+	for key, value := range want {
+		if got := rec.Header().Get(key); got != value {
+			t.Fatalf("%s = %q", key, got)
+		}
+	}
+
+	r.TLS = &tls.ConnectionState{}
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, r)
+	if got := rec.Header().Get("Strict-Transport-Security"); got != "max-age=3600; includeSubDomains" {
+		t.Fatalf("hsts = %q", got)
+	}
+}
+
+func TestBodyLimitMiddleware(t *testing.T) {
+	h := BodyLimitMiddleware(8)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if _, err := io.ReadAll(r.Body); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+		}
+	}))
+
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/", strings.NewReader("0123456789")))
+	if rec.Code != http.StatusRequestEntityTooLarge || !strings.Contains(rec.Body.String(), "RESOURCE_EXHAUSTED") {
+		t.Fatalf("response = %d %s", rec.Code, rec.Body.String())
+	}
+
+	// chunked body without Content-Length
+	r := httptest.NewRequest(http.MethodPost, "/", io.MultiReader(strings.NewReader("0123456789")))
+	r.ContentLength = -1
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, r)
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("chunked response = %d %s", rec.Code, rec.Body.String())
+	}
+
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/", strings.NewReader("01234567")))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("response = %d %s", rec.Code, rec.Body.String())
+	}
+}
@@ -0,0 +1,290 @@
+package micro
+
+import (
+	"context"
+	"errors"
+	"math"
+	"runtime"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/daheige/hephfx/monitor"
+)
+
+const (
+	// cpuSampleInterval the interval of sampling the process cpu usage.
+	cpuSampleInterval = 250 * time.Millisecond
+
+	// cpuDecay the decay of the moving average of the cpu usage.
+	cpuDecay = 0.95
+)
+
+// ErrServiceOverloaded the request is shed by the adaptive load shedder.
+var ErrServiceOverloaded = errors.New("service is overloaded")
+
+// ShedderOption adaptive load shedder option
+type ShedderOption func(s *Shedder)
+
+// Shedder is an adaptive load shedder in the style of BBR.
+// It estimates the max in-flight requests by the max passed requests and the min response time
+// of a rolling window,the requests are shed when the process cpu usage reaches the threshold
+// and the in-flight requests exceed the estimated max in-flight requests.
+type Shedder struct {
+	cpuThreshold float64
+	coolOff      time.Duration
+	cpuUsage     func() float64
+	window       *rollingWindow
+
+	inFlight atomic.Int64
+	dropped  atomic.Uint64
+	dropTime atomic.Int64 // the unix nano of the last dropped request
+
+	now func() time.Time // for testing
+}
+
+// WithShedderWindow returns a ShedderOption to set the rolling window and the number of buckets,
+// default: 5s and 50 buckets.
+func WithShedderWindow(window time.Duration, buckets int) ShedderOption {
+	return func(s *Shedder) {
+		if window > 0 && buckets > 0 {
+			s.window = newRollingWindow(window, buckets)
+		}
+	}
+}
+
+// WithShedderCPUThreshold returns a ShedderOption to set the cpu usage threshold,
+// range: (0,1],default: 0.8
+func WithShedderCPUThreshold(threshold float64) ShedderOption {
+	return func(s *Shedder) {
+		s.cpuThreshold = threshold
+	}
+}
+
+// WithShedderCoolOff returns a ShedderOption to keep checking the in-flight requests
+// for the duration after a request is dropped,even if the cpu usage is below the threshold,
+// default: 1s
+func WithShedderCoolOff(coolOff time.Duration) ShedderOption {
+	return func(s *Shedder) {
+		s.coolOff = coolOff
+	}
+}
+
+// WithShedderCPUUsage returns a ShedderOption to set the cpu usage func,range: [0,1],
+// default: the moving average of the process cpu usage.
+func WithShedderCPUUsage(fn func() float64) ShedderOption {
+	return func(s *Shedder) {
+		s.cpuUsage = fn
+	}
+}
+
+// NewShedder creates an adaptive load shedder.
+func NewShedder(opts ...ShedderOption) *Shedder {
+	s := &Shedder{
+		cpuThreshold: 0.8,
+		coolOff:      time.Second,
+		cpuUsage:     processCPUUsage,
+		window:       newRollingWindow(5*time.Second, 50),
+		now:          time.Now,
+	}
+
+	for _, o := range opts {
+		o(s)
+	}
+
+	return s
+}
+
+// Allow returns ErrServiceOverloaded when the request should be shed,
+// otherwise done must be called when the request is finished.
+func (s *Shedder) Allow() (done func(), err error) {
+	if s.shouldDrop() {
+		s.dropped.Add(1)
+		s.dropTime.Store(s.now().UnixNano())
+		return nil, ErrServiceOverloaded
+	}
+
+	s.inFlight.Add(1)
+	start := s.now()
+	return func() {
+		now := s.now()
+		s.inFlight.Add(-1)
+		s.window.add(now, now.Sub(start))
+	}, nil
+}
+
+// Stats returns the state of the shedder.
+func (s *Shedder) Stats() monitor.ShedderStats {
+	maxPass, minRT := s.window.stats(s.now())
+	return monitor.ShedderStats{
+		InFlight:    s.inFlight.Load(),
+		MaxInFlight: s.maxInFlight(maxPass, minRT),
+		MinRT:       minRT,
+		CPUUsage:    s.cpuUsage(),
+		Dropping:    s.coolingOff(),
+		Dropped:     s.dropped.Load(),
+	}
+}
+
+// RegisterMetrics registers the prometheus gauges of the shedder by monitor.RegisterShedder.
+func (s *Shedder) RegisterMetrics(name string) error {
+	return monitor.RegisterShedder(name, s.Stats)
+}
+
+// UnaryServerInterceptor returns a server unary interceptor which rejects the requests
+// with codes.Unavailable when the service is overloaded.
+func (s *Shedder) UnaryServerInterceptor() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo,
+		handler grpc.UnaryHandler) (interface{}, error) {
+		done, err := s.Allow()
+		if err != nil {
+			return nil, status.Error(codes.Unavailable, err.Error())
+		}
+
+		defer done()
+		return handler(ctx, req)
+	}
+}
+
+// StreamServerInterceptor returns a server stream interceptor which rejects the streams
+// with codes.Unavailable when the service is overloaded.
+func (s *Shedder) StreamServerInterceptor() grpc.StreamServerInterceptor {
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		done, err := s.Allow()
+		if err != nil {
+			return status.Error(codes.Unavailable, err.Error())
+		}
+
+		defer done()
+		return handler(srv, ss)
+	}
+}
+
+func (s *Shedder) shouldDrop() bool {
+	if s.cpuUsage() < s.cpuThreshold && !s.coolingOff() {
+		return false
+	}
+
+	inFlight := s.inFlight.Load()
+	maxInFlight := s.maxInFlight(s.window.stats(s.now()))
+	return maxInFlight > 0 && inFlight > 1 && inFlight > maxInFlight
+}
+
+// coolingOff reports whether a request is dropped within the cool off duration.
+func (s *Shedder) coolingOff() bool {
+	dropTime := s.dropTime.Load()
+	return dropTime > 0 && s.now().Sub(time.Unix(0, dropTime)) <= s.coolOff
+}
+
+// maxInFlight returns the estimated max in-flight requests,0 means unknown.
+func (s *Shedder) maxInFlight(maxPass int64, minRT time.Duration) int64 {
+	return int64(math.Ceil(float64(maxPass) * float64(minRT) / float64(s.window.width)))
+}
+
+// rollingWindow counts the passed requests and the response time in buckets.
+type rollingWindow struct {
+	mu      sync.Mutex
+	width   time.Duration
+	buckets []rollingBucket
+}
+
+type rollingBucket struct {
+	index int64 // the bucket index since the unix epoch
+	count int64
+	rtSum time.Duration
+}
+
+func newRollingWindow(window time.Duration, buckets int) *rollingWindow {
+	return &rollingWindow{
+		width:   window / time.Duration(buckets),
+		buckets: make([]rollingBucket, buckets),
+	}
+}
+
+func (w *rollingWindow) add(now time.Time, rt time.Duration) {
+	index := now.UnixNano() / int64(w.width)
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	b := &w.buckets[index%int64(len(w.buckets))]
+	if b.index != index {
+		*b = rollingBucket{index: index}
+	}
+
+	b.count++
+	b.rtSum += rt
+}
+
+// stats returns the max passed requests of a bucket and the min average response time
+// of the completed buckets in the window.
+func (w *rollingWindow) stats(now time.Time) (int64, time.Duration) {
+	current := now.UnixNano() / int64(w.width)
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	var maxPass int64
+	var minRT time.Duration
+	for _, b := range w.buckets {
+		if b.count == 0 || b.index >= current || b.index <= current-int64(len(w.buckets)) {
+			continue
+		}
+
+		maxPass = max(maxPass, b.count)
+		if rt := b.rtSum / time.Duration(b.count); minRT == 0 || rt < minRT {
+			minRT = rt
+		}
+	}
+
+	return maxPass, minRT
+}
+
+// processCPU samples the process cpu usage in background.
+var processCPU struct {
+	once  sync.Once
+	usage atomic.Uint64 // float64 bits
+}
+
+// processCPUUsage returns the moving average of the process cpu usage,range: [0,1].
+func processCPUUsage() float64 {
+	processCPU.once.Do(func() {
+		go sampleCPUUsage()
+	})
+
+	return math.Float64frombits(processCPU.usage.Load())
+}
+
+func sampleCPUUsage() {
+	lastCPU, lastTime := cpuTime(), time.Now()
+	ticker := time.NewTicker(cpuSampleInterval)
+	defer ticker.Stop()
+
+	var usage float64
+	for range ticker.C {
+		cpu, now := cpuTime(), time.Now()
+		elapsed := now.Sub(lastTime) * time.Duration(runtime.GOMAXPROCS(0))
+		if elapsed > 0 {
+			current := min(float64(cpu-lastCPU)/float64(elapsed), 1)
+			usage = usage*cpuDecay + current*(1-cpuDecay)
+			processCPU.usage.Store(math.Float64bits(usage))
+		}
+
+		lastCPU, lastTime = cpu, now
+	}
+}
+
+// cpuTime returns the user and system cpu time of the process.
+func cpuTime() time.Duration {
+	var ru syscall.Rusage
+	if err := syscall.Getrusage(syscall.RUSAGE_SELF, &ru); err != nil {
+		return 0
+	}
+
+	return time.Duration(ru.Utime.Nano() + ru.Stime.Nano())
+}
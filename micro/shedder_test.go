@@ -0,0 +1,159 @@
+package micro
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/daheige/hephfx/monitor"
+)
+
+// fakeClock is a manual clock for the shedder.
+type fakeClock struct {
+	now time.Time
+}
+
+func (c *fakeClock) Now() time.Time {
+	return c.now
+}
+
+func (c *fakeClock) Add(d time.Duration) {
+	c.now = c.now.Add(d)
+}
+
+// newSaturatedShedder returns a shedder which has passed 10 requests in 100ms with 100ms rt,
+// the estimated max in-flight requests is 10.
+func newSaturatedShedder(t *testing.T, cpu *atomic.Value) (*Shedder, *fakeClock) {
+	t.Helper()
+
+	clock := &fakeClock{now: time.Unix(1000, 0)}
+	s := NewShedder(
+		WithShedderWindow(time.Second, 10),
+		WithShedderCPUUsage(func() float64 { return cpu.Load().(float64) }),
+	)
+	s.now = clock.Now
+
+	dones := make([]func(), 0, 10)
+	for i := 0; i < 10; i++ {
+		done, err := s.Allow()
+		if err != nil {
+			t.Fatalf("allow %d error: %v", i, err)
+		}
+		dones = append(dones, done)
+	}
+
+	clock.Add(100 * time.Millisecond)
+	for _, done := range dones {
+		done()
+	}
+
+	clock.Add(100 * time.Millisecond)
+	if stats := s.Stats(); stats.MaxInFlight != 10 || stats.MinRT != 100*time.Millisecond {
+		t.Fatalf("unexpected stats: %+v", stats)
+	}
+
+	return s, clock
+}
+
+func TestShedder(t *testing.T) {
+	cpu := &atomic.Value{}
+	cpu.Store(0.9)
+	s, clock := newSaturatedShedder(t, cpu)
+
+	// the requests are shed when the in-flight requests exceed the max in-flight requests
+	for i := 0; i < 11; i++ {
+		if _, err := s.Allow(); err != nil {
+			t.Fatalf("allow %d error: %v", i, err)
+		}
+	}
+
+	if _, err := s.Allow(); err != ErrServiceOverloaded {
+		t.Fatalf("allow error = %v, want %v", err, ErrServiceOverloaded)
+	}
+
+	stats := s.Stats()
+	if !stats.Dropping || stats.Dropped != 1 || stats.InFlight != 11 {
+		t.Fatalf("unexpected stats: %+v", stats)
+	}
+
+	// the requests are still checked in the cool off duration
+	cpu.Store(0.1)
+	if _, err := s.Allow(); err != ErrServiceOverloaded {
+		t.Fatalf("allow in cool off error = %v, want %v", err, ErrServiceOverloaded)
+	}
+
+	clock.Add(2 * time.Second)
+	if _, err := s.Allow(); err != nil {
+		t.Fatalf("allow after cool off error: %v", err)
+	}
+	if s.Stats().Dropping {
+		t.Fatalf("shedder is dropping after cool off")
+	}
+}
+
+func TestShedderInterceptor(t *testing.T) {
+	cpu := &atomic.Value{}
+	cpu.Store(1.0)
+	s, _ := newSaturatedShedder(t, cpu)
+	for i := 0; i < 11; i++ {
+		_, _ = s.Allow()
+	}
+
+	interceptor := s.UnaryServerInterceptor()
+	_, err := interceptor(context.Background(), nil, &grpc.UnaryServerInfo{FullMethod: "/Hello.Greeter/SayHello"},
+		func(ctx context.Context, req interface{}) (interface{}, error) {
+			t.Fatalf("the shed request is handled")
+			return nil, nil
+		})
+	if status.Code(err) != codes.Unavailable {
+		t.Fatalf("interceptor error = %v, want %s", err, codes.Unavailable)
+	}
+
+	// the shedder state is exposed by the monitor collector
+	registry := prometheus.NewPedanticRegistry()
+	registry.MustRegister(monitor.NewShedderCollector("greeter", s.Stats))
+	families, err := registry.Gather()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	values := map[string]float64{}
+	for _, family := range families {
+		metric := family.GetMetric()[0]
+		if metric.GetGauge() != nil {
+			values[family.GetName()] = metric.GetGauge().GetValue()
+		} else {
+			values[family.GetName()] = metric.GetCounter().GetValue()
+		}
+	}
+
+	want := map[string]float64{
+		"shedder_in_flight":      11,
+		"shedder_max_in_flight":  10,
+		"shedder_min_rt_seconds": 0.1,
+		"shedder_cpu_usage":      1,
+		"shedder_dropping":       1,
+		"shedder_dropped_total":  1,
+	}
+	for name, value := range want {
+		if values[name] != value {
+			t.Fatalf("%s = %v, want %v", name, values[name], value)
+		}
+	}
+}
+
+func TestProcessCPUUsage(t *testing.T) {
+	usage := processCPUUsage()
+	if usage < 0 || usage > 1 {
+		t.Fatalf("cpu usage = %v, want [0,1]", usage)
+	}
+	if cpuTime() <= 0 {
+		t.Fatalf("process cpu time is not available")
+	}
+}
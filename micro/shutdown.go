@@ -0,0 +1,103 @@
+package micro
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// ShutdownPhase is the stage of the service shutdown in which a hook runs.
+type ShutdownPhase int
+
+const (
+	// PhasePreDrain runs before the servers stop accepting new requests,
+	// eg: deregister the service from the registry.
+	PhasePreDrain ShutdownPhase = iota
+
+	// PhaseAfterHTTP runs after the gRPC http gateway server has stopped.
+	PhaseAfterHTTP
+
+	// PhaseAfterGRPC runs after the gRPC server has stopped,eg: close db connections.
+	PhaseAfterGRPC
+
+	// PhaseFinal runs at the end of the shutdown,eg: flush logs.
+	PhaseFinal
+)
+
+// String returns the name of the shutdown phase.
+func (p ShutdownPhase) String() string {
+	switch p {
+	case PhasePreDrain:
+		return "pre-drain"
+	case PhaseAfterHTTP:
+		return "after-http"
+	case PhaseAfterGRPC:
+		return "after-grpc"
+	case PhaseFinal:
+		return "final"
+	default:
+		return fmt.Sprintf("phase(%d)", int(p))
+	}
+}
+
+// ShutdownHook is a named function which will be called when the service shutdown.
+type ShutdownHook struct {
+	// Name hook name,used for logging and errors
+	Name string
+
+	// Phase the shutdown phase in which the hook runs
+	Phase ShutdownPhase
+
+	// Timeout the hook timeout,default: the service shutdown timeout
+	Timeout time.Duration
+
+	// Fn the hook function,ctx is done when the hook timeout is reached
+	Fn func(ctx context.Context) error
+}
+
+// runShutdownHooks runs all hooks of the phase in registration order.
+// Every hook gets its own timeout context, so a slow hook can not use up the budget of the others.
+func (s *Service) runShutdownHooks(phase ShutdownPhase) error {
+	var errs []error
+	for _, hook := range s.shutdownHooks {
+		if hook.Phase != phase {
+			continue
+		}
+
+		if err := s.runShutdownHook(hook); err != nil {
+			s.logger.Printf("shutdown hook %s phase:%s error: %v\n", hook.Name, phase, err)
+			errs = append(errs, fmt.Errorf("shutdown hook %s: %w", hook.Name, err))
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+func (s *Service) runShutdownHook(hook ShutdownHook) error {
+	timeout := hook.Timeout
+	if timeout <= 0 {
+		timeout = s.shutdownTimeout
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	done := make(chan error, 1)
+	go func() {
+		defer func() {
+			if r := recover(); r != nil {
+				done <- fmt.Errorf("exec panic: %v", r)
+			}
+		}()
+
+		done <- hook.Fn(ctx)
+	}()
+
+	select {
+	case err := <-done:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,80 @@
+package micro
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestShutdownHooks(t *testing.T) {
+	var (
+		mu    sync.Mutex
+		calls []string
+	)
+	record := func(name string, err error) func(ctx context.Context) error {
+		return func(ctx context.Context) error {
+			mu.Lock()
+			defer mu.Unlock()
+
+			calls = append(calls, name)
+			return err
+		}
+	}
+
+	errDBClose := errors.New("db close error")
+	s := NewService(
+		freeAddress(t),
+		WithShutdownTimeout(time.Second),
+		WithShutdownHook(PhaseFinal, "flush-log", record("flush-log", nil)),
+		WithShutdownHook(PhaseAfterGRPC, "close-db", record("close-db", errDBClose)),
+		WithShutdownFunc(func() {
+			_ = record("shutdown-func", nil)(context.Background())
+		}),
+		WithShutdownHook(PhasePreDrain, "deregister", record("deregister", nil)),
+		WithShutdownHooks(ShutdownHook{
+			Name:    "slow",
+			Phase:   PhaseAfterHTTP,
+			Timeout: 50 * time.Millisecond,
+			Fn: func(ctx context.Context) error {
+				<-ctx.Done()
+				return ctx.Err()
+			},
+		}),
+	)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	errChan := make(chan error, 1)
+	go func() {
+		errChan <- s.RunContext(ctx)
+	}()
+
+	waitListening(t, s.gRPCAddress)
+	cancel()
+
+	var err error
+	select {
+	case err = <-errChan:
+	case <-time.After(3 * time.Second):
+		t.Fatalf("service did not stop")
+	}
+
+	if !errors.Is(err, errDBClose) {
+		t.Fatalf("expected close-db error, got: %v", err)
+	}
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected slow hook timeout error, got: %v", err)
+	}
+
+	want := []string{"deregister", "close-db", "shutdown-func", "flush-log"}
+	if !reflect.DeepEqual(calls, want) {
+		t.Fatalf("hooks order = %v, want %v", calls, want)
+	}
+
+	// Shutdown returns the same error as RunContext
+	if shutdownErr := s.Shutdown(context.Background()); !errors.Is(shutdownErr, errDBClose) {
+		t.Fatalf("expected shutdown error, got: %v", shutdownErr)
+	}
+}
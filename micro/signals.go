@@ -11,3 +11,6 @@ var interruptSignals = []os.Signal{
 	syscall.SIGINT, syscall.SIGTERM, os.Interrupt, syscall.SIGHUP,
 	syscall.SIGSTOP, syscall.SIGQUIT,
 }
+
+// restartSignal the signal to restart the service gracefully.
+var restartSignal os.Signal = syscall.SIGUSR2
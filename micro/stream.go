@@ -0,0 +1,538 @@
+package micro
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+const (
+	// defaultStreamHeartbeat the default heartbeat interval of the streaming connections
+	defaultStreamHeartbeat = 15 * time.Second
+
+	// defaultWebSocketReadLimit the default max size of the websocket client messages
+	defaultWebSocketReadLimit = 4 << 20
+
+	// webSocketCloseTimeout the timeout of writing the websocket close message
+	webSocketCloseTimeout = time.Second
+)
+
+// StreamOption streaming bridge option
+type StreamOption func(b *streamBridge)
+
+// streamBridge bridges the streaming methods of the http gateway to Server-Sent Events and WebSocket.
+type streamBridge struct {
+	ssePaths     [][]string
+	wsPaths      [][]string
+	wsMethod     string
+	heartbeat    time.Duration
+	writeTimeout time.Duration
+	readLimit    int64
+	upgrader     websocket.Upgrader
+}
+
+// WithSSE returns a StreamOption to serve the server-streaming methods of the paths as Server-Sent Events,
+// the paths support the grpc-gateway path template,eg: /v1/orders/{id}/events
+// The requests are bridged only when the Accept header contains text/event-stream.
+func WithSSE(paths ...string) StreamOption {
+	return func(b *streamBridge) {
+		for _, path := range paths {
+			b.ssePaths = append(b.ssePaths, parseStreamPath(path))
+		}
+	}
+}
+
+// WithWebSocket returns a StreamOption to serve the bidi-streaming methods of the paths over WebSocket,
+// the paths support the grpc-gateway path template,eg: /v1/chat/{room}
+func WithWebSocket(paths ...string) StreamOption {
+	return func(b *streamBridge) {
+		for _, path := range paths {
+			b.wsPaths = append(b.wsPaths, parseStreamPath(path))
+		}
+	}
+}
+
+// WithWebSocketMethod returns a StreamOption to set the http method which the websocket requests
+// are forwarded to the gateway with,default: POST
+func WithWebSocketMethod(method string) StreamOption {
+	return func(b *streamBridge) {
+		b.wsMethod = method
+	}
+}
+
+// WithWebSocketCheckOrigin returns a StreamOption to check the origin of the websocket requests,
+// the requests of the same origin are accepted by default.
+func WithWebSocketCheckOrigin(fn func(r *http.Request) bool) StreamOption {
+	return func(b *streamBridge) {
+		b.upgrader.CheckOrigin = fn
+	}
+}
+
+// WithWebSocketReadLimit returns a StreamOption to set the max size of the websocket client messages,
+// default: 4MB
+func WithWebSocketReadLimit(limit int64) StreamOption {
+	return func(b *streamBridge) {
+		b.readLimit = limit
+	}
+}
+
+// WithStreamHeartbeat returns a StreamOption to set the heartbeat interval,default: 15s
+// The Server-Sent Events streams send the comment lines and the websocket connections send the ping messages,
+// the websocket connections are closed when the pong messages are not received in two intervals.
+// The zero interval disables the heartbeats.
+func WithStreamHeartbeat(interval time.Duration) StreamOption {
+	return func(b *streamBridge) {
+		b.heartbeat = interval
+	}
+}
+
+// WithStreamWriteTimeout returns a StreamOption to set the timeout of writing a message to the client,
+// the stream is canceled when the client does not read in time.
+// There is no timeout by default,the slow client blocks the stream.
+func WithStreamWriteTimeout(timeout time.Duration) StreamOption {
+	return func(b *streamBridge) {
+		b.writeTimeout = timeout
+	}
+}
+
+// StreamMiddleware returns the http middleware which bridges the streaming methods of the gateway.
+// The gateway writes the stream messages as newline-delimited json,
+// they are translated to the Server-Sent Events or the websocket messages:
+//
+//   - Server-Sent Events: the result of each message is sent as the data of an event,
+//     the stream error is sent as the error event.
+//   - WebSocket: the client messages are sent to the stream and
+//     the stream messages are sent as is,eg: {"result":{...}} or {"error":{...}}
+//
+// The slow clients block the stream,so that the gRPC flow control applies the backpressure,
+// the stream is canceled when the client disconnects.
+func StreamMiddleware(opts ...StreamOption) HTTPMiddleware {
+	b := &streamBridge{
+		wsMethod:  http.MethodPost,
+		heartbeat: defaultStreamHeartbeat,
+		readLimit: defaultWebSocketReadLimit,
+	}
+	for _, o := range opts {
+		o(b)
+	}
+
+	return func(h http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			switch {
+			case websocket.IsWebSocketUpgrade(r) && matchStreamPaths(b.wsPaths, r.URL.Path):
+				b.serveWebSocket(w, r, h)
+			case strings.Contains(r.Header.Get("Accept"), "text/event-stream") &&
+				matchStreamPaths(b.ssePaths, r.URL.Path):
+				b.serveSSE(w, r, h)
+			default:
+				h.ServeHTTP(w, r)
+			}
+		})
+	}
+}
+
+// serveSSE serves the stream as Server-Sent Events.
+func (b *streamBridge) serveSSE(w http.ResponseWriter, r *http.Request, h http.Handler) {
+	rc := http.NewResponseController(w)
+	// the stream lasts longer than the write timeout of the http server
+	_ = rc.SetWriteDeadline(time.Time{})
+
+	sw := &sseResponseWriter{ResponseWriter: w, rc: rc, writeTimeout: b.writeTimeout}
+	done := make(chan struct{})
+	var wg sync.WaitGroup
+	if b.heartbeat > 0 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			ticker := time.NewTicker(b.heartbeat)
+			defer ticker.Stop()
+			for {
+				select {
+				case <-done:
+					return
+				case <-r.Context().Done():
+					return
+				case <-ticker.C:
+					if err := sw.ping(); err != nil {
+						return
+					}
+				}
+			}
+		}()
+	}
+
+	h.ServeHTTP(sw, r)
+
+	// the response writer must not be used after the handler returns
+	close(done)
+	wg.Wait()
+	sw.finish()
+}
+
+// serveWebSocket serves the stream over WebSocket.
+func (b *streamBridge) serveWebSocket(w http.ResponseWriter, r *http.Request, h http.Handler) {
+	conn, err := b.upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		// the upgrader replies the error response
+		return
+	}
+
+	defer conn.Close()
+
+	ctx, cancel := context.WithCancel(r.Context())
+	defer cancel()
+
+	// the client messages are written to the request body line by line,
+	// the gateway decodes them and sends them to the stream.
+	body, bodyWriter := io.Pipe()
+	defer body.Close()
+
+	req := r.Clone(ctx)
+	req.Method = b.wsMethod
+	req.Body = body
+	req.ContentLength = -1
+	for _, key := range []string{
+		"Connection", "Upgrade", "Sec-Websocket-Key", "Sec-Websocket-Version",
+		"Sec-Websocket-Extensions", "Sec-Websocket-Protocol",
+	} {
+		req.Header.Del(key)
+	}
+
+	conn.SetReadLimit(b.readLimit)
+	done := make(chan struct{})
+	defer close(done)
+	if b.heartbeat > 0 {
+		_ = conn.SetReadDeadline(time.Now().Add(2 * b.heartbeat))
+		conn.SetPongHandler(func(string) error {
+			return conn.SetReadDeadline(time.Now().Add(2 * b.heartbeat))
+		})
+
+		go b.pingWebSocket(conn, done)
+	}
+
+	go func() {
+		// the stream is canceled when the client disconnects
+		defer cancel()
+
+		for {
+			_, msg, err := conn.ReadMessage()
+			if err != nil {
+				_ = bodyWriter.CloseWithError(err)
+				return
+			}
+
+			if b.heartbeat > 0 {
+				_ = conn.SetReadDeadline(time.Now().Add(2 * b.heartbeat))
+			}
+
+			if _, err = bodyWriter.Write(append(msg, '\n')); err != nil {
+				return
+			}
+		}
+	}()
+
+	ww := &wsResponseWriter{conn: conn, header: make(http.Header), writeTimeout: b.writeTimeout}
+	h.ServeHTTP(ww, req)
+	ww.finish()
+}
+
+// pingWebSocket sends the ping messages until done is closed.
+func (b *streamBridge) pingWebSocket(conn *websocket.Conn, done <-chan struct{}) {
+	ticker := time.NewTicker(b.heartbeat)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-done:
+			return
+		case <-ticker.C:
+			err := conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(b.heartbeat))
+			if err != nil {
+				return
+			}
+		}
+	}
+}
+
+// sseResponseWriter translates the newline-delimited json of the gateway into Server-Sent Events,
+// the error response before the stream is written as is.
+type sseResponseWriter struct {
+	http.ResponseWriter
+	rc           *http.ResponseController
+	writeTimeout time.Duration
+	mu           sync.Mutex
+	buf          []byte
+	id           int
+	wroteHeader  bool
+	passthrough  bool
+}
+
+// WriteHeader writes the event stream headers,or the status code of the error response.
+func (w *sseResponseWriter) WriteHeader(code int) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	w.writeHeader(code)
+}
+
+func (w *sseResponseWriter) writeHeader(code int) {
+	if w.wroteHeader {
+		return
+	}
+
+	w.wroteHeader = true
+	if code != http.StatusOK {
+		w.passthrough = true
+		w.ResponseWriter.WriteHeader(code)
+		return
+	}
+
+	header := w.Header()
+	header.Set("Content-Type", "text/event-stream")
+	header.Set("Cache-Control", "no-cache")
+	header.Set("X-Accel-Buffering", "no") // disable the response buffering of nginx
+	header.Del("Content-Length")
+	header.Del("Transfer-Encoding")
+	w.ResponseWriter.WriteHeader(code)
+}
+
+// Write writes an event for each complete line.
+func (w *sseResponseWriter) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	w.writeHeader(http.StatusOK)
+	if w.passthrough {
+		return w.ResponseWriter.Write(p)
+	}
+
+	w.buf = append(w.buf, p...)
+	for {
+		i := bytes.IndexByte(w.buf, '\n')
+		if i < 0 {
+			break
+		}
+
+		err := w.writeEvent(w.buf[:i])
+		w.buf = append(w.buf[:0], w.buf[i+1:]...)
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	return len(p), nil
+}
+
+// Flush flushes the events to the client.
+func (w *sseResponseWriter) Flush() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	w.writeHeader(http.StatusOK)
+	_ = w.rc.Flush()
+}
+
+// ping writes a comment line to keep the connection alive,
+// the event stream headers are written if the stream has not started.
+func (w *sseResponseWriter) ping() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	w.writeHeader(http.StatusOK)
+	if w.passthrough {
+		return nil
+	}
+
+	if err := w.write([]byte(": ping\n\n")); err != nil {
+		return err
+	}
+
+	return w.rc.Flush()
+}
+
+// finish writes the incomplete line as the last event.
+func (w *sseResponseWriter) finish() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.passthrough || len(w.buf) == 0 {
+		return
+	}
+
+	if err := w.writeEvent(w.buf); err == nil {
+		_ = w.rc.Flush()
+	}
+
+	w.buf = nil
+}
+
+// writeEvent writes the gateway message as an event,
+// eg: {"result":{...}} => data: {...} and {"error":{...}} => event: error
+func (w *sseResponseWriter) writeEvent(line []byte) error {
+	line = bytes.TrimSpace(line)
+	if len(line) == 0 {
+		return nil
+	}
+
+	event, data := "", line
+	var chunk map[string]json.RawMessage
+	if err := json.Unmarshal(line, &chunk); err == nil && len(chunk) == 1 {
+		if result, ok := chunk["result"]; ok {
+			data = result
+		} else if e, ok := chunk["error"]; ok {
+			event, data = "error", e
+		}
+	}
+
+	w.id++
+	var b bytes.Buffer
+	b.WriteString("id: " + strconv.Itoa(w.id) + "\n")
+	if event != "" {
+		b.WriteString("event: " + event + "\n")
+	}
+
+	for _, d := range bytes.Split(data, []byte("\n")) {
+		b.WriteString("data: ")
+		b.Write(d)
+		b.WriteByte('\n')
+	}
+
+	b.WriteByte('\n')
+	return w.write(b.Bytes())
+}
+
+func (w *sseResponseWriter) write(p []byte) error {
+	if w.writeTimeout > 0 {
+		_ = w.rc.SetWriteDeadline(time.Now().Add(w.writeTimeout))
+	}
+
+	_, err := w.ResponseWriter.Write(p)
+	return err
+}
+
+// wsResponseWriter sends each line of the gateway response as a websocket text message.
+type wsResponseWriter struct {
+	conn         *websocket.Conn
+	header       http.Header
+	writeTimeout time.Duration
+	status       int
+	buf          []byte
+}
+
+// Header returns the response headers,they are not sent over WebSocket.
+func (w *wsResponseWriter) Header() http.Header {
+	return w.header
+}
+
+// WriteHeader records the status code,the connection is closed with an error when it is not 2xx.
+func (w *wsResponseWriter) WriteHeader(code int) {
+	if w.status == 0 {
+		w.status = code
+	}
+}
+
+// Write sends a message for each complete line.
+func (w *wsResponseWriter) Write(p []byte) (int, error) {
+	w.WriteHeader(http.StatusOK)
+	w.buf = append(w.buf, p...)
+	for {
+		i := bytes.IndexByte(w.buf, '\n')
+		if i < 0 {
+			break
+		}
+
+		err := w.writeMessage(w.buf[:i])
+		w.buf = append(w.buf[:0], w.buf[i+1:]...)
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	return len(p), nil
+}
+
+// Flush does nothing,the messages are sent when the lines are complete.
+func (w *wsResponseWriter) Flush() {}
+
+// finish sends the incomplete line and closes the connection with the close message.
+func (w *wsResponseWriter) finish() {
+	if len(w.buf) > 0 {
+		_ = w.writeMessage(w.buf)
+		w.buf = nil
+	}
+
+	code, reason := websocket.CloseNormalClosure, ""
+	if w.status >= http.StatusBadRequest {
+		code, reason = websocket.CloseInternalServerErr, http.StatusText(w.status)
+	}
+
+	_ = w.conn.WriteControl(websocket.CloseMessage, websocket.FormatCloseMessage(code, reason),
+		time.Now().Add(webSocketCloseTimeout))
+}
+
+func (w *wsResponseWriter) writeMessage(msg []byte) error {
+	msg = bytes.TrimSpace(msg)
+	if len(msg) == 0 {
+		return nil
+	}
+
+	if w.writeTimeout > 0 {
+		_ = w.conn.SetWriteDeadline(time.Now().Add(w.writeTimeout))
+	}
+
+	return w.conn.WriteMessage(websocket.TextMessage, msg)
+}
+
+// streamPathParam the path param of the grpc-gateway path template,eg: {id} or {name=shelves/*}
+var streamPathParam = regexp.MustCompile(`\{[^}=]+(=([^}]*))?\}`)
+
+// parseStreamPath parses the path template into the segments,
+// the path params are replaced by the wildcards,eg: /v1/{name=shelves/*}/{id} => [v1 shelves * *]
+func parseStreamPath(path string) []string {
+	path = streamPathParam.ReplaceAllStringFunc(path, func(param string) string {
+		if m := streamPathParam.FindStringSubmatch(param); m[2] != "" {
+			return m[2]
+		}
+
+		return "*"
+	})
+
+	return strings.Split(strings.Trim(path, "/"), "/")
+}
+
+// matchStreamPaths reports whether the request path matches one of the path templates,
+// * matches a segment and ** matches the rest segments.
+func matchStreamPaths(templates [][]string, path string) bool {
+	segments := strings.Split(strings.Trim(path, "/"), "/")
+	for _, tpl := range templates {
+		if matchStreamPath(tpl, segments) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func matchStreamPath(tpl []string, segments []string) bool {
+	for i, s := range tpl {
+		if s == "**" {
+			return true
+		}
+
+		if i >= len(segments) || (s != "*" && s != segments[i]) || segments[i] == "" {
+			return false
+		}
+	}
+
+	return len(tpl) == len(segments)
+}
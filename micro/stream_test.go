@@ -0,0 +1,277 @@
+package micro
+
+import (
+	"bufio"
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+	gRuntime "github.com/grpc-ecosystem/grpc-gateway/v2/runtime"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+)
+
+// streamHandlers registers the streaming handlers like the generated gateway code,
+// the path of the handler is sent to finished when it returns.
+func streamHandlers(finished chan<- string) HandlerServer {
+	forward := func(mux *gRuntime.ServeMux, w http.ResponseWriter, r *http.Request,
+		recv func() (proto.Message, error)) {
+		defer func() { finished <- r.URL.Path }()
+
+		_, outbound := gRuntime.MarshalerForRequest(mux, r)
+		ctx := gRuntime.NewServerMetadataContext(r.Context(), gRuntime.ServerMetadata{})
+		gRuntime.ForwardResponseStream(ctx, mux, outbound, w, r, recv)
+	}
+
+	return func(ctx context.Context, mux *gRuntime.ServeMux) error {
+		err := mux.HandlePath(http.MethodGet, "/v1/ticks/{name}",
+			func(w http.ResponseWriter, r *http.Request, params map[string]string) {
+				i := 0
+				forward(mux, w, r, func() (proto.Message, error) {
+					if i == 3 {
+						if r.URL.Query().Get("fail") != "" {
+							return nil, status.Error(codes.Aborted, "aborted")
+						}
+
+						return nil, io.EOF
+					}
+
+					i++
+					return wrapperspb.String(params["name"] + "-" + strconv.Itoa(i)), nil
+				})
+			})
+		if err != nil {
+			return err
+		}
+
+		err = mux.HandlePath(http.MethodGet, "/v1/wait", func(w http.ResponseWriter, r *http.Request, _ map[string]string) {
+			forward(mux, w, r, func() (proto.Message, error) {
+				<-r.Context().Done()
+				return nil, r.Context().Err()
+			})
+		})
+		if err != nil {
+			return err
+		}
+
+		return mux.HandlePath(http.MethodPost, "/v1/echo", func(w http.ResponseWriter, r *http.Request, _ map[string]string) {
+			inbound, _ := gRuntime.MarshalerForRequest(mux, r)
+			msgs := make(chan proto.Message)
+			go func() {
+				defer close(msgs)
+
+				dec := inbound.NewDecoder(r.Body)
+				for {
+					msg := &wrapperspb.StringValue{}
+					if err := dec.Decode(msg); err != nil {
+						return
+					}
+
+					select {
+					case msgs <- msg:
+					case <-r.Context().Done():
+						return
+					}
+				}
+			}()
+
+			forward(mux, w, r, func() (proto.Message, error) {
+				select {
+				case msg, ok := <-msgs:
+					if !ok {
+						return nil, io.EOF
+					}
+
+					return msg, nil
+				case <-r.Context().Done():
+					return nil, r.Context().Err()
+				}
+			})
+		})
+	}
+}
+
+func newStreamServer(t *testing.T, finished chan string) *httptest.Server {
+	s := NewService("", WithEnableInProcessGateway(),
+		WithHandlerServers(streamHandlers(finished)),
+		WithCompression(WithCompressMinSize(1)),
+		WithStreaming(
+			WithSSE("/v1/ticks/{name}", "/v1/wait"),
+			WithWebSocket("/v1/echo"),
+			WithStreamHeartbeat(20*time.Millisecond),
+		),
+	)
+	h, err := s.HTTPHandler()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	srv := httptest.NewServer(h)
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func TestStreamSSE(t *testing.T) {
+	finished := make(chan string, 10)
+	srv := newStreamServer(t, finished)
+
+	cases := []struct {
+		path        string
+		accept      string
+		contentType string
+		body        string
+	}{
+		{
+			"/v1/ticks/a", "text/event-stream", "text/event-stream",
+			"id: 1\ndata: \"a-1\"\n\nid: 2\ndata: \"a-2\"\n\nid: 3\ndata: \"a-3\"\n\n",
+		},
+		{
+			"/v1/ticks/b?fail=1", "text/event-stream", "text/event-stream",
+			"id: 1\ndata: \"b-1\"\n\nid: 2\ndata: \"b-2\"\n\nid: 3\ndata: \"b-3\"\n\n" +
+				"id: 4\nevent: error\ndata: {\"code\":10,\"message\":\"aborted\",\"details\":[]}\n\n",
+		},
+		{
+			"/v1/ticks/c", "", "application/json",
+			"{\"result\":\"c-1\"}\n{\"result\":\"c-2\"}\n{\"result\":\"c-3\"}\n",
+		},
+	}
+	for _, c := range cases {
+		req, _ := http.NewRequest(http.MethodGet, srv.URL+c.path, nil)
+		req.Header.Set("Accept", c.accept)
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		b, _ := io.ReadAll(resp.Body)
+		_ = resp.Body.Close()
+		if got := resp.Header.Get("Content-Type"); got != c.contentType {
+			t.Fatalf("%s: content type = %s", c.path, got)
+		}
+
+		if resp.Header.Get("Content-Encoding") != "" {
+			t.Fatalf("%s: the stream is compressed", c.path)
+		}
+
+		if body := strings.ReplaceAll(string(b), ": ping\n\n", ""); body != c.body {
+			t.Fatalf("%s: body = %q", c.path, body)
+		}
+	}
+}
+
+func TestStreamSSECancel(t *testing.T) {
+	finished := make(chan string, 10)
+	srv := newStreamServer(t, finished)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	req, _ := http.NewRequestWithContext(ctx, http.MethodGet, srv.URL+"/v1/wait", nil)
+	req.Header.Set("Accept", "text/event-stream")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer resp.Body.Close()
+
+	// the heartbeat starts the stream before the first message
+	line, err := bufio.NewReader(resp.Body).ReadString('\n')
+	if err != nil || line != ": ping\n" {
+		t.Fatalf("heartbeat = %q,err: %v", line, err)
+	}
+
+	cancel()
+	select {
+	case path := <-finished:
+		if path != "/v1/wait" {
+			t.Fatalf("finished path = %s", path)
+		}
+	case <-time.After(3 * time.Second):
+		t.Fatal("the stream is not canceled after the client disconnects")
+	}
+}
+
+func TestStreamWebSocket(t *testing.T) {
+	finished := make(chan string, 10)
+	srv := newStreamServer(t, finished)
+
+	conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(srv.URL, "http")+"/v1/echo", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer conn.Close()
+
+	for _, msg := range []string{"a", "b"} {
+		if err = conn.WriteMessage(websocket.TextMessage, []byte(strconv.Quote(msg))); err != nil {
+			t.Fatal(err)
+		}
+
+		_, b, err := conn.ReadMessage()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if want := `{"result":` + strconv.Quote(msg) + `}`; string(b) != want {
+			t.Fatalf("message = %s,want: %s", b, want)
+		}
+	}
+
+	err = conn.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseNormalClosure, ""))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case path := <-finished:
+		if path != "/v1/echo" {
+			t.Fatalf("finished path = %s", path)
+		}
+	case <-time.After(3 * time.Second):
+		t.Fatal("the stream is not canceled after the client closes")
+	}
+
+	// the requests which are not websocket are forwarded as is
+	resp, err := http.Post(srv.URL+"/v1/echo", "application/json", strings.NewReader(`"c"`+"\n"+`"d"`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	b, _ := io.ReadAll(resp.Body)
+	_ = resp.Body.Close()
+	if string(b) != "{\"result\":\"c\"}\n{\"result\":\"d\"}\n" {
+		t.Fatalf("body = %q", b)
+	}
+}
+
+func TestMatchStreamPaths(t *testing.T) {
+	cases := []struct {
+		template string
+		path     string
+		match    bool
+	}{
+		{"/v1/events", "/v1/events", true},
+		{"/v1/events", "/v1/events/1", false},
+		{"/v1/orders/{id}/events", "/v1/orders/1/events", true},
+		{"/v1/orders/{id}/events", "/v1/orders//events", false},
+		{"/v1/{name=shelves/*}/books", "/v1/shelves/1/books", true},
+		{"/v1/{name=shelves/*}/books", "/v1/users/1/books", false},
+		{"/v1/files/{path=**}", "/v1/files/a/b/c", true},
+		{"/v1/watch:stream", "/v1/watch:stream", true},
+	}
+	for _, c := range cases {
+		templates := [][]string{parseStreamPath(c.template)}
+		if got := matchStreamPaths(templates, c.path); got != c.match {
+			t.Fatalf("%s %s: match = %v", c.template, c.path, got)
+		}
+	}
+}
@@ -0,0 +1,407 @@
+package micro
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/url"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/daheige/hephfx/micro/auth"
+)
+
+// ErrTLSCertificateNotFound the tls config has no certificate.
+var ErrTLSCertificateNotFound = errors.New("tls certificate not found")
+
+// gatewayCommonName is the common name of the client certificate of the internal gateway dial.
+const gatewayCommonName = "hephfx-gateway"
+
+// certReloader loads the server certificate and client CA files from disk,
+// and reloads them when the files are changed.
+type certReloader struct {
+	certFile string
+	keyFile  string
+	caFile   string
+
+	// the files are checked at most once per interval,0 means never reload
+	interval time.Duration
+	logger   Logger
+
+	mu             sync.RWMutex
+	cert           *tls.Certificate
+	clientCAs      *x509.CertPool
+	extraClientCAs []*x509.Certificate // trusted besides the CA file,eg: the gateway certificate
+	modTime        time.Time           // the latest modification time of the files
+	checked        time.Time           // the last check time
+}
+
+func newCertReloader(certFile, keyFile, caFile string, interval time.Duration, logger Logger) (*certReloader, error) {
+	r := &certReloader{
+		certFile: certFile,
+		keyFile:  keyFile,
+		caFile:   caFile,
+		interval: interval,
+		logger:   logger,
+	}
+
+	modTime, err := r.latestModTime()
+	if err != nil {
+		return nil, err
+	}
+
+	err = r.load(modTime)
+	if err != nil {
+		return nil, err
+	}
+
+	return r, nil
+}
+
+// GetCertificate returns the current server certificate,it can be used as tls.Config.GetCertificate.
+func (r *certReloader) GetCertificate(_ *tls.ClientHelloInfo) (*tls.Certificate, error) {
+	r.maybeReload()
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	return r.cert, nil
+}
+
+// ClientCAs returns the current client CA cert pool.
+func (r *certReloader) ClientCAs() *x509.CertPool {
+	r.maybeReload()
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	return r.clientCAs
+}
+
+// trustClientCA adds cert to the client CAs,it is kept when the CA file is reloaded.
+func (r *certReloader) trustClientCA(cert *x509.Certificate) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.extraClientCAs = append(r.extraClientCAs, cert)
+	if r.clientCAs != nil {
+		r.clientCAs = withClientCA(r.clientCAs, cert)
+	}
+}
+
+func (r *certReloader) maybeReload() {
+	if r.interval <= 0 {
+		return
+	}
+
+	r.mu.Lock()
+	if time.Since(r.checked) < r.interval {
+		r.mu.Unlock()
+		return
+	}
+	r.checked = time.Now()
+	lastModTime := r.modTime
+	r.mu.Unlock()
+
+	modTime, err := r.latestModTime()
+	if err != nil {
+		r.logger.Printf("check tls certificate files error: %v\n", err)
+		return
+	}
+
+	if !modTime.After(lastModTime) {
+		return
+	}
+
+	// keep using the old certificate when the new one is invalid
+	err = r.load(modTime)
+	if err != nil {
+		r.logger.Printf("reload tls certificate files error: %v\n", err)
+		return
+	}
+
+	r.logger.Printf("reload tls certificate file:%s success\n", r.certFile)
+}
+
+func (r *certReloader) load(modTime time.Time) error {
+	cert, err := tls.LoadX509KeyPair(r.certFile, r.keyFile)
+	if err != nil {
+		return fmt.Errorf("load tls key pair error: %w", err)
+	}
+
+	var clientCAs *x509.CertPool
+	if r.caFile != "" {
+		clientCAs, err = loadCertPool(r.caFile)
+		if err != nil {
+			return err
+		}
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if clientCAs != nil {
+		for _, ca := range r.extraClientCAs {
+			clientCAs.AddCert(ca)
+		}
+	}
+
+	r.cert = &cert
+	r.clientCAs = clientCAs
+	r.modTime = modTime
+	return nil
+}
+
+func (r *certReloader) latestModTime() (time.Time, error) {
+	var latest time.Time
+	for _, file := range []string{r.certFile, r.keyFile, r.caFile} {
+		if file == "" {
+			continue
+		}
+
+		info, err := os.Stat(file)
+		if err != nil {
+			return latest, err
+		}
+
+		if info.ModTime().After(latest) {
+			latest = info.ModTime()
+		}
+	}
+
+	return latest, nil
+}
+
+func loadCertPool(caFile string) (*x509.CertPool, error) {
+	b, err := os.ReadFile(caFile)
+	if err != nil {
+		return nil, fmt.Errorf("read ca file error: %w", err)
+	}
+
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(b) {
+		return nil, fmt.Errorf("no valid certificate found in ca file: %s", caFile)
+	}
+
+	return pool, nil
+}
+
+// initTLSConfig builds the server tls config from the tls options.
+// It does nothing when neither WithTLSConfig nor WithTLSCertFile is used.
+func (s *Service) initTLSConfig() error {
+	if s.tlsConfig == nil && s.tlsCertFile == "" {
+		return nil
+	}
+
+	cfg := &tls.Config{MinVersion: tls.VersionTLS12}
+	if s.tlsConfig != nil {
+		cfg = s.tlsConfig.Clone()
+	}
+
+	if s.tlsCertFile != "" {
+		reloader, err := newCertReloader(s.tlsCertFile, s.tlsKeyFile, s.tlsClientCAFile,
+			s.tlsReloadInterval, s.logger)
+		if err != nil {
+			return err
+		}
+
+		s.certReloader = reloader
+		cfg.GetCertificate = reloader.GetCertificate
+	}
+
+	// the client CA file is loaded by the reloader when the certificate files are used
+	if s.tlsClientCAFile != "" && s.certReloader == nil {
+		pool, err := loadCertPool(s.tlsClientCAFile)
+		if err != nil {
+			return err
+		}
+
+		cfg.ClientCAs = pool
+	}
+
+	// mTLS: the client certificates are verified with the client CAs by the tls handshake
+	if s.tlsClientCAFile != "" && cfg.ClientAuth == tls.NoClientCert {
+		cfg.ClientAuth = tls.RequireAndVerifyClientCert
+	}
+
+	// the internal gateway dial presents its own client certificate,
+	// it is trusted as a client CA by this service only.
+	if cfg.ClientAuth != tls.NoClientCert && s.gatewayUsesTLS() {
+		cert, err := newGatewayCertificate()
+		if err != nil {
+			return err
+		}
+
+		s.gatewayCert = cert
+		if cfg.ClientAuth >= tls.VerifyClientCertIfGiven {
+			if s.certReloader != nil && s.tlsClientCAFile != "" {
+				s.certReloader.trustClientCA(cert.Leaf)
+			} else {
+				cfg.ClientCAs = withClientCA(cfg.ClientCAs, cert.Leaf)
+			}
+		}
+	}
+
+	// the client CAs are reloaded with the certificate files,see withReloadedClientCAs
+	if s.certReloader != nil && s.tlsClientCAFile != "" {
+		cfg.ClientCAs = s.certReloader.ClientCAs()
+	}
+
+	s.tlsConfig = cfg
+	return nil
+}
+
+// localCertificate returns the current server certificate.
+func (s *Service) localCertificate() (*tls.Certificate, error) {
+	if s.tlsConfig.GetCertificate != nil {
+		cert, err := s.tlsConfig.GetCertificate(&tls.ClientHelloInfo{})
+		if err != nil {
+			return nil, err
+		}
+
+		if cert != nil {
+			return cert, nil
+		}
+	}
+
+	if len(s.tlsConfig.Certificates) == 0 {
+		return nil, ErrTLSCertificateNotFound
+	}
+
+	return &s.tlsConfig.Certificates[0], nil
+}
+
+// isLocalCertificate reports whether raw is the current server certificate.
+func (s *Service) isLocalCertificate(raw []byte) bool {
+	cert, err := s.localCertificate()
+	if err != nil || len(cert.Certificate) == 0 {
+		return false
+	}
+
+	return bytes.Equal(cert.Certificate[0], raw)
+}
+
+// gatewayUsesTLS reports whether the gateway dials to the gRPC server with the tls credentials.
+// On the shared port the tls is handled by the http server,so the in-process dial is insecure.
+func (s *Service) gatewayUsesTLS() bool {
+	return (s.enableHTTPGateway || s.enableInProcessGateway) && len(s.gRPCEndpointDialOptions) == 0 &&
+		!(s.enableInProcessGateway && s.enableGRPCShareAddress)
+}
+
+// newGatewayCertificate creates a self-signed client certificate for the internal gateway dial.
+func newGatewayCertificate() (*tls.Certificate, error) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, fmt.Errorf("generate gateway key error: %w", err)
+	}
+
+	serial, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+	if err != nil {
+		return nil, fmt.Errorf("generate gateway certificate serial error: %w", err)
+	}
+
+	gatewayURI, err := url.Parse(auth.GatewayCertificateURI)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	tpl := &x509.Certificate{
+		SerialNumber:          serial,
+		Subject:               pkix.Name{CommonName: gatewayCommonName},
+		URIs:                  []*url.URL{gatewayURI},
+		NotBefore:             now.Add(-time.Hour),
+		NotAfter:              now.AddDate(10, 0, 0),
+		KeyUsage:              x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+		BasicConstraintsValid: true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tpl, tpl, &key.PublicKey, key)
+	if err != nil {
+		return nil, fmt.Errorf("create gateway certificate error: %w", err)
+	}
+
+	leaf, err := x509.ParseCertificate(der)
+	if err != nil {
+		return nil, fmt.Errorf("parse gateway certificate error: %w", err)
+	}
+
+	return &tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key, Leaf: leaf}, nil
+}
+
+// withClientCA returns a copy of pool with cert added,
+// the system cert pool is used when pool is nil as the tls handshake does.
+func withClientCA(pool *x509.CertPool, cert *x509.Certificate) *x509.CertPool {
+	if pool == nil {
+		var err error
+		pool, err = x509.SystemCertPool()
+		if err != nil {
+			pool = x509.NewCertPool()
+		}
+	} else {
+		pool = pool.Clone()
+	}
+
+	pool.AddCert(cert)
+	return pool
+}
+
+// withReloadedClientCAs makes cfg verify the client certificates with the reloaded client CAs.
+// The GetConfigForClient of cfg is kept when it is set.
+func (s *Service) withReloadedClientCAs(cfg *tls.Config) *tls.Config {
+	if s.certReloader == nil || s.tlsClientCAFile == "" || cfg.GetConfigForClient != nil {
+		return cfg
+	}
+
+	base := cfg.Clone()
+	cfg.GetConfigForClient = func(_ *tls.ClientHelloInfo) (*tls.Config, error) {
+		c := base.Clone()
+		c.ClientCAs = s.certReloader.ClientCAs()
+		return c, nil
+	}
+
+	return cfg
+}
+
+// gatewayTLSConfig returns the tls config for the internal gateway-to-gRPC dial.
+// The gRPC server is the service itself,so its certificate is pinned instead of
+// being verified by the system roots, and the gateway certificate is presented as the client
+// certificate when mTLS is enabled.
+func (s *Service) gatewayTLSConfig() *tls.Config {
+	cfg := &tls.Config{
+		MinVersion: tls.VersionTLS12,
+		// the server certificate is verified by VerifyConnection
+		InsecureSkipVerify: true, //nolint:gosec
+		VerifyConnection: func(cs tls.ConnectionState) error {
+			if len(cs.PeerCertificates) == 0 || !s.isLocalCertificate(cs.PeerCertificates[0].Raw) {
+				return errors.New("gRPC server certificate does not match the local certificate")
+			}
+
+			return nil
+		},
+	}
+
+	if s.gatewayCert != nil {
+		cfg.Certificates = []tls.Certificate{*s.gatewayCert}
+	}
+
+	return cfg
+}
+
+// serverTLSConfig returns a tls config for http server which supports HTTP/2 by ALPN.
+func (s *Service) serverTLSConfig() *tls.Config {
+	cfg := s.tlsConfig.Clone()
+	if len(cfg.NextProtos) == 0 {
+		cfg.NextProtos = []string{"h2", "http/1.1"}
+	}
+
+	return s.withReloadedClientCAs(cfg)
+}
@@ -0,0 +1,336 @@
+package micro
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"errors"
+	"io"
+	"math/big"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+
+	"github.com/daheige/hephfx/example/pb"
+)
+
+// testCA is a certificate authority for tests.
+type testCA struct {
+	cert *x509.Certificate
+	key  *ecdsa.PrivateKey
+	pool *x509.CertPool
+	pem  []byte
+}
+
+func newTestCA(t *testing.T) *testCA {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "hephfx test ca"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tpl, tpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cert, _ := x509.ParseCertificate(der)
+	pool := x509.NewCertPool()
+	pool.AddCert(cert)
+
+	return &testCA{
+		cert: cert,
+		key:  key,
+		pool: pool,
+		pem:  pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}),
+	}
+}
+
+// issue returns a certificate and private key pem signed by the CA.
+func (ca *testCA) issue(t *testing.T, cn string, usage x509.ExtKeyUsage) (certPEM []byte, keyPEM []byte) {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	serial, _ := rand.Int(rand.Reader, big.NewInt(1<<62))
+	tpl := &x509.Certificate{
+		SerialNumber: serial,
+		Subject:      pkix.Name{CommonName: cn},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{usage},
+		DNSNames:     []string{"localhost"},
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tpl, ca.cert, &key.PublicKey, ca.key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}),
+		pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER})
+}
+
+func (ca *testCA) keyPair(t *testing.T, cn string, usage x509.ExtKeyUsage) tls.Certificate {
+	t.Helper()
+
+	certPEM, keyPEM := ca.issue(t, cn, usage)
+	cert, err := tls.X509KeyPair(certPEM, keyPEM)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return cert
+}
+
+func writeFile(t *testing.T, path string, b []byte) {
+	t.Helper()
+
+	if err := os.WriteFile(path, b, 0600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestTLS(t *testing.T) {
+	ca := newTestCA(t)
+	dir := t.TempDir()
+	certPEM, keyPEM := ca.issue(t, "server", x509.ExtKeyUsageServerAuth)
+	certFile := filepath.Join(dir, "server.crt")
+	keyFile := filepath.Join(dir, "server.key")
+	caFile := filepath.Join(dir, "ca.crt")
+	writeFile(t, certFile, certPEM)
+	writeFile(t, keyFile, keyPEM)
+	writeFile(t, caFile, ca.pem)
+
+	clientCert := ca.keyPair(t, "client", x509.ExtKeyUsageClientAuth)
+	rogueCert := newTestCA(t).keyPair(t, "rogue", x509.ExtKeyUsageClientAuth)
+
+	cases := []struct {
+		name      string
+		shareAddr bool
+		mTLS      bool
+		inProcess bool
+	}{
+		{name: "share address", shareAddr: true},
+		{name: "two services"},
+		{name: "share address mTLS", shareAddr: true, mTLS: true},
+		{name: "two services mTLS", mTLS: true},
+		{name: "share address in-process", shareAddr: true, mTLS: true, inProcess: true},
+		{name: "two services in-process", mTLS: true, inProcess: true},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			address := freeAddress(t)
+			httpAddress := address
+			opts := []Option{
+				WithTLSCertFile(certFile, keyFile),
+				WithEnableHealthCheck(),
+				WithShutdownTimeout(time.Second),
+				WithHandlerFromEndpoints(pb.RegisterGreeterHandlerFromEndpoint),
+			}
+			if c.shareAddr {
+				opts = append(opts, WithEnableGRPCShareAddress())
+			} else {
+				httpAddress = freeAddress(t)
+				opts = append(opts, WithGRPCHTTPAddress(httpAddress))
+			}
+			if c.mTLS {
+				opts = append(opts, WithTLSClientCAFile(caFile))
+			}
+			if c.inProcess {
+				opts = append(opts, WithEnableInProcessGateway())
+			}
+
+			s := NewService(address, opts...)
+			pb.RegisterGreeterServer(s.GRPCServer, &testGreeter{})
+			runService(t, s, address, httpAddress)
+
+			clientTLS := &tls.Config{RootCAs: ca.pool, Certificates: []tls.Certificate{clientCert}}
+
+			// gRPC over tls
+			conn, err := grpc.NewClient(address, grpc.WithTransportCredentials(credentials.NewTLS(clientTLS)))
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer conn.Close()
+
+			_, err = healthpb.NewHealthClient(conn).Check(context.Background(), &healthpb.HealthCheckRequest{})
+			if err != nil {
+				t.Fatalf("gRPC health check error: %v", err)
+			}
+
+			// the gateway dials to the gRPC server with matching credentials
+			client := &http.Client{Transport: &http.Transport{TLSClientConfig: clientTLS, ForceAttemptHTTP2: true}}
+			resp, err := client.Get("https://" + httpAddress + "/v1/say/heige")
+			if err != nil {
+				t.Fatalf("gateway request error: %v", err)
+			}
+			body, _ := io.ReadAll(resp.Body)
+			resp.Body.Close()
+			if resp.StatusCode != http.StatusOK || !strings.Contains(string(body), "hello,heige") {
+				t.Fatalf("gateway response code:%d body:%s", resp.StatusCode, body)
+			}
+
+			if !c.mTLS {
+				return
+			}
+
+			// the client certificate not signed by the CA is rejected
+			rogueTLS := &tls.Config{RootCAs: ca.pool, Certificates: []tls.Certificate{rogueCert}}
+			rogue := &http.Client{Transport: &http.Transport{TLSClientConfig: rogueTLS}}
+			if resp, err := rogue.Get("https://" + httpAddress + "/healthz"); err == nil {
+				resp.Body.Close()
+				t.Fatalf("expected rogue client certificate to be rejected")
+			}
+
+			// the server certificate is not a client certificate of the CA
+			serverCert, err := tls.X509KeyPair(certPEM, keyPEM)
+			if err != nil {
+				t.Fatal(err)
+			}
+			serverTLS := &tls.Config{RootCAs: ca.pool, Certificates: []tls.Certificate{serverCert}}
+			impostor := &http.Client{Transport: &http.Transport{TLSClientConfig: serverTLS}}
+			if resp, err := impostor.Get("https://" + httpAddress + "/healthz"); err == nil {
+				resp.Body.Close()
+				t.Fatalf("expected server certificate to be rejected as a client certificate")
+			}
+		})
+	}
+}
+
+func TestTLSConfigClientAuth(t *testing.T) {
+	ca := newTestCA(t)
+	serverCert := ca.keyPair(t, "server", x509.ExtKeyUsageServerAuth)
+	clientCert := ca.keyPair(t, "client", x509.ExtKeyUsageClientAuth)
+
+	var verified atomic.Int32
+	address := freeAddress(t)
+	s := NewService(
+		address,
+		WithEnableGRPCShareAddress(),
+		WithShutdownTimeout(time.Second),
+		WithHandlerFromEndpoints(pb.RegisterGreeterHandlerFromEndpoint),
+		WithTLSConfig(&tls.Config{
+			Certificates: []tls.Certificate{serverCert},
+			ClientAuth:   tls.RequireAndVerifyClientCert,
+			ClientCAs:    ca.pool,
+			VerifyPeerCertificate: func(_ [][]byte, chains [][]*x509.Certificate) error {
+				if len(chains) == 0 {
+					return errors.New("client certificate is not verified")
+				}
+
+				verified.Add(1)
+				return nil
+			},
+		}),
+	)
+	pb.RegisterGreeterServer(s.GRPCServer, &testGreeter{})
+	runService(t, s, address)
+
+	if s.tlsConfig.ClientAuth != tls.RequireAndVerifyClientCert || s.tlsConfig.VerifyPeerCertificate == nil {
+		t.Fatalf("the client auth of the tls config is changed")
+	}
+
+	clientTLS := &tls.Config{RootCAs: ca.pool, Certificates: []tls.Certificate{clientCert}}
+	client := &http.Client{Transport: &http.Transport{TLSClientConfig: clientTLS, ForceAttemptHTTP2: true}}
+	resp, err := client.Get("https://" + address + "/v1/say/heige")
+	if err != nil {
+		t.Fatalf("gateway request error: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("gateway response code:%d", resp.StatusCode)
+	}
+
+	// the client and the gateway dial are both verified by the caller
+	if verified.Load() < 2 {
+		t.Fatalf("VerifyPeerCertificate is called %d times,want at least 2", verified.Load())
+	}
+}
+
+func TestTLSReload(t *testing.T) {
+	ca := newTestCA(t)
+	dir := t.TempDir()
+	certFile := filepath.Join(dir, "server.crt")
+	keyFile := filepath.Join(dir, "server.key")
+	certPEM, keyPEM := ca.issue(t, "server-v1", x509.ExtKeyUsageServerAuth)
+	writeFile(t, certFile, certPEM)
+	writeFile(t, keyFile, keyPEM)
+
+	address := freeAddress(t)
+	s := NewService(
+		address,
+		WithEnableGRPCShareAddress(),
+		WithTLSCertFile(certFile, keyFile),
+		WithTLSReloadInterval(10*time.Millisecond),
+		WithShutdownTimeout(time.Second),
+	)
+	runService(t, s, address)
+
+	serverName := func() string {
+		conn, err := tls.Dial("tcp", address, &tls.Config{RootCAs: ca.pool})
+		if err != nil {
+			t.Fatalf("tls dial error: %v", err)
+		}
+		defer conn.Close()
+
+		return conn.ConnectionState().PeerCertificates[0].Subject.CommonName
+	}
+
+	if got := serverName(); got != "server-v1" {
+		t.Fatalf("server certificate = %s, want server-v1", got)
+	}
+
+	certPEM, keyPEM = ca.issue(t, "server-v2", x509.ExtKeyUsageServerAuth)
+	writeFile(t, certFile, certPEM)
+	writeFile(t, keyFile, keyPEM)
+	later := time.Now().Add(time.Second)
+	_ = os.Chtimes(certFile, later, later)
+	_ = os.Chtimes(keyFile, later, later)
+	time.Sleep(20 * time.Millisecond)
+
+	if got := serverName(); got != "server-v2" {
+		t.Fatalf("server certificate = %s, want server-v2", got)
+	}
+}
+
+func TestTLSInitError(t *testing.T) {
+	s := NewService(freeAddress(t), WithTLSCertFile("not-exist.crt", "not-exist.key"))
+	if err := s.RunContext(context.Background()); err == nil {
+		t.Fatalf("expected load certificate error")
+	}
+}
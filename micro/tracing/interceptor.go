@@ -0,0 +1,216 @@
+package tracing
+
+import (
+	"context"
+	"errors"
+	"io"
+	"sync"
+
+	"go.opentelemetry.io/otel/attribute"
+	otelCodes "go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+
+	"github.com/daheige/hephfx/micro/gerrors"
+)
+
+// UnaryServerInterceptor returns a server unary interceptor which starts a server span
+// with the trace context extracted from incoming metadata.
+func UnaryServerInterceptor(opts ...Option) grpc.UnaryServerInterceptor {
+	c := newConfig(opts...)
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo,
+		handler grpc.UnaryHandler) (interface{}, error) {
+		if c.skipMethods[info.FullMethod] {
+			return handler(ctx, req)
+		}
+
+		ctx, span := c.startServerSpan(ctx, info.FullMethod)
+		defer span.End()
+
+		reply, err := handler(ctx, req)
+		endSpan(span, err, true)
+		return reply, err
+	}
+}
+
+// StreamServerInterceptor returns a server stream interceptor which starts a server span
+// with the trace context extracted from incoming metadata.
+func StreamServerInterceptor(opts ...Option) grpc.StreamServerInterceptor {
+	c := newConfig(opts...)
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		if c.skipMethods[info.FullMethod] {
+			return handler(srv, ss)
+		}
+
+		ctx, span := c.startServerSpan(ss.Context(), info.FullMethod)
+		defer span.End()
+
+		err := handler(srv, &serverStream{ServerStream: ss, ctx: ctx})
+		endSpan(span, err, true)
+		return err
+	}
+}
+
+// UnaryClientInterceptor returns a client unary interceptor which starts a client span
+// and injects the trace context into outgoing metadata.
+func UnaryClientInterceptor(opts ...Option) grpc.UnaryClientInterceptor {
+	c := newConfig(opts...)
+	return func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn,
+		invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+		if c.skipMethods[method] {
+			return invoker(ctx, method, req, reply, cc, opts...)
+		}
+
+		ctx, span := c.startClientSpan(ctx, method)
+		defer span.End()
+
+		err := invoker(ctx, method, req, reply, cc, opts...)
+		endSpan(span, err, false)
+		return err
+	}
+}
+
+// StreamClientInterceptor returns a client stream interceptor which starts a client span
+// and injects the trace context into outgoing metadata.
+// The span ends when the stream is finished,so the stream must be received until an error or io.EOF.
+func StreamClientInterceptor(opts ...Option) grpc.StreamClientInterceptor {
+	c := newConfig(opts...)
+	return func(ctx context.Context, desc *grpc.StreamDesc, cc *grpc.ClientConn, method string,
+		streamer grpc.Streamer, opts ...grpc.CallOption) (grpc.ClientStream, error) {
+		if c.skipMethods[method] {
+			return streamer(ctx, desc, cc, method, opts...)
+		}
+
+		ctx, span := c.startClientSpan(ctx, method)
+		cs, err := streamer(ctx, desc, cc, method, opts...)
+		if err != nil {
+			endSpan(span, err, false)
+			span.End()
+			return nil, err
+		}
+
+		return &clientStream{ClientStream: cs, span: span, serverStreams: desc.ServerStreams}, nil
+	}
+}
+
+func (c *config) startServerSpan(ctx context.Context, fullMethod string) (context.Context, trace.Span) {
+	md, _ := metadata.FromIncomingContext(ctx)
+	ctx = c.propagator.Extract(ctx, metadataCarrier(md))
+	return c.tracer().Start(ctx, spanName(fullMethod),
+		trace.WithSpanKind(trace.SpanKindServer),
+		trace.WithAttributes(methodAttributes(fullMethod)...),
+	)
+}
+
+func (c *config) startClientSpan(ctx context.Context, fullMethod string) (context.Context, trace.Span) {
+	ctx, span := c.tracer().Start(ctx, spanName(fullMethod),
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(methodAttributes(fullMethod)...),
+	)
+
+	md, ok := metadata.FromOutgoingContext(ctx)
+	if !ok {
+		md = metadata.MD{}
+	}
+
+	c.propagator.Inject(ctx, metadataCarrier(md))
+	return metadata.NewOutgoingContext(ctx, md), span
+}
+
+// spanName returns the span name,eg: Hello.Greeter/SayHello
+func spanName(fullMethod string) string {
+	service, method := splitMethod(fullMethod)
+	if service == "" {
+		return method
+	}
+
+	return service + "/" + method
+}
+
+func methodAttributes(fullMethod string) []attribute.KeyValue {
+	service, method := splitMethod(fullMethod)
+	return []attribute.KeyValue{
+		attribute.String("rpc.system", "grpc"),
+		attribute.String("rpc.service", service),
+		attribute.String("rpc.method", method),
+	}
+}
+
+// endSpan records the gRPC status code of the call,
+// the server span is marked as error only for the server side errors.
+func endSpan(span trace.Span, err error, server bool) {
+	s := status.Convert(err)
+	span.SetAttributes(attribute.Int64("rpc.grpc.status_code", int64(s.Code())))
+	if err == nil {
+		return
+	}
+
+	span.RecordError(err)
+	if !server || gerrors.IsServerErrorCode(s.Code()) {
+		span.SetStatus(otelCodes.Error, s.Message())
+	}
+}
+
+// serverStream overrides the context of grpc.ServerStream.
+type serverStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+// Context returns the context with the server span.
+func (s *serverStream) Context() context.Context {
+	return s.ctx
+}
+
+// clientStream ends the client span when the stream is finished.
+type clientStream struct {
+	grpc.ClientStream
+	span          trace.Span
+	serverStreams bool
+	once          sync.Once
+}
+
+// RecvMsg ends the span when the stream returns an error or io.EOF,
+// or the first message of a non server streaming call is received.
+func (s *clientStream) RecvMsg(m interface{}) error {
+	err := s.ClientStream.RecvMsg(m)
+	switch {
+	case errors.Is(err, io.EOF):
+		s.end(nil)
+	case err != nil:
+		s.end(err)
+	case !s.serverStreams:
+		s.end(nil)
+	}
+
+	return err
+}
+
+// SendMsg ends the span when the stream returns an error.
+func (s *clientStream) SendMsg(m interface{}) error {
+	err := s.ClientStream.SendMsg(m)
+	if err != nil && !errors.Is(err, io.EOF) {
+		s.end(err)
+	}
+
+	return err
+}
+
+// Header ends the span when the stream returns an error.
+func (s *clientStream) Header() (metadata.MD, error) {
+	md, err := s.ClientStream.Header()
+	if err != nil {
+		s.end(err)
+	}
+
+	return md, err
+}
+
+func (s *clientStream) end(err error) {
+	s.once.Do(func() {
+		endSpan(s.span, err, false)
+		s.span.End()
+	})
+}
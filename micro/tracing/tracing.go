@@ -0,0 +1,136 @@
+// Package tracing provides OpenTelemetry tracing for gRPC servers, clients and the http gateway.
+// The trace context is propagated by the W3C traceparent header.
+package tracing
+
+import (
+	"context"
+	"net/http"
+	"strings"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+)
+
+// instrumentationName the name of the tracer.
+const instrumentationName = "github.com/daheige/hephfx/micro/tracing"
+
+// Option tracing option
+type Option func(c *config)
+
+type config struct {
+	tracerProvider trace.TracerProvider
+	propagator     propagation.TextMapPropagator
+	skipMethods    map[string]bool // the full methods which are not traced
+}
+
+// WithTracerProvider returns an Option to set the tracer provider,
+// default: otel.GetTracerProvider()
+func WithTracerProvider(tp trace.TracerProvider) Option {
+	return func(c *config) {
+		c.tracerProvider = tp
+	}
+}
+
+// WithPropagator returns an Option to set the propagator,
+// default: W3C trace context and baggage.
+func WithPropagator(p propagation.TextMapPropagator) Option {
+	return func(c *config) {
+		c.propagator = p
+	}
+}
+
+// WithSkipMethods returns an Option to skip the tracing of the full methods,
+// eg: /grpc.health.v1.Health/Check
+func WithSkipMethods(methods ...string) Option {
+	return func(c *config) {
+		for _, method := range methods {
+			c.skipMethods[method] = true
+		}
+	}
+}
+
+func newConfig(opts ...Option) *config {
+	c := &config{
+		propagator: propagation.NewCompositeTextMapPropagator(
+			propagation.TraceContext{}, propagation.Baggage{},
+		),
+		skipMethods: make(map[string]bool),
+	}
+
+	for _, o := range opts {
+		o(c)
+	}
+
+	if c.tracerProvider == nil {
+		c.tracerProvider = otel.GetTracerProvider()
+	}
+
+	return c
+}
+
+func (c *config) tracer() trace.Tracer {
+	return c.tracerProvider.Tracer(instrumentationName)
+}
+
+// DialOptions returns the grpc.DialOption list to install the client interceptors,
+// it can be used by gclient.InitGRPCClient and bridge.WithDialOptions.
+func DialOptions(opts ...Option) []grpc.DialOption {
+	return []grpc.DialOption{
+		grpc.WithChainUnaryInterceptor(UnaryClientInterceptor(opts...)),
+		grpc.WithChainStreamInterceptor(StreamClientInterceptor(opts...)),
+	}
+}
+
+// GatewayAnnotator returns a grpc-gateway annotator which carries the trace context
+// of the http request headers into gRPC metadata.
+// Use it by runtime.WithMetadata or micro.WithAnnotators.
+func GatewayAnnotator(opts ...Option) func(ctx context.Context, r *http.Request) metadata.MD {
+	c := newConfig(opts...)
+	return func(ctx context.Context, r *http.Request) metadata.MD {
+		md := metadata.MD{}
+		ctx = c.propagator.Extract(ctx, propagation.HeaderCarrier(r.Header))
+		c.propagator.Inject(ctx, metadataCarrier(md))
+		return md
+	}
+}
+
+// metadataCarrier adapts metadata.MD to propagation.TextMapCarrier.
+type metadataCarrier metadata.MD
+
+// Get returns the first value of the key.
+func (m metadataCarrier) Get(key string) string {
+	values := metadata.MD(m).Get(key)
+	if len(values) == 0 {
+		return ""
+	}
+
+	return values[0]
+}
+
+// Set sets the value of the key.
+func (m metadataCarrier) Set(key string, value string) {
+	metadata.MD(m).Set(key, value)
+}
+
+// Keys returns the keys of the metadata.
+func (m metadataCarrier) Keys() []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+
+	return keys
+}
+
+// splitMethod splits the full method into service and method,eg: /Hello.Greeter/SayHello
+func splitMethod(fullMethod string) (string, string) {
+	name := strings.TrimPrefix(fullMethod, "/")
+	if i := strings.LastIndex(name, "/"); i >= 0 {
+		return name[:i], name[i+1:]
+	}
+
+	return "", name
+}
@@ -0,0 +1,201 @@
+package tracing
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"testing"
+
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/test/bufconn"
+
+	"github.com/daheige/hephfx/example/pb"
+)
+
+// greeter records the span context of the last call.
+type greeter struct {
+	pb.UnimplementedGreeterServer
+	spanContext trace.SpanContext
+}
+
+func (s *greeter) SayHello(ctx context.Context, req *pb.HelloReq) (*pb.HelloReply, error) {
+	s.spanContext = trace.SpanContextFromContext(ctx)
+	return &pb.HelloReply{Message: "hello," + req.Name}, nil
+}
+
+// newTestConn starts a gRPC server with the tracing interceptors on bufconn,
+// returns the client connection with the tracing client interceptors.
+func newTestConn(t *testing.T, g pb.GreeterServer, opts ...Option) *grpc.ClientConn {
+	t.Helper()
+
+	lis := bufconn.Listen(1 << 20)
+	server := grpc.NewServer(
+		grpc.ChainUnaryInterceptor(UnaryServerInterceptor(opts...)),
+		grpc.ChainStreamInterceptor(StreamServerInterceptor(opts...)),
+	)
+	pb.RegisterGreeterServer(server, g)
+	healthpb.RegisterHealthServer(server, health.NewServer())
+	go func() {
+		_ = server.Serve(lis)
+	}()
+
+	dialOptions := append([]grpc.DialOption{
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+			return lis.DialContext(ctx)
+		}),
+	}, DialOptions(opts...)...)
+	conn, err := grpc.NewClient("passthrough:///bufconn", dialOptions...)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Cleanup(func() {
+		conn.Close()
+		server.Stop()
+	})
+
+	return conn
+}
+
+func newTestProvider() (*sdktrace.TracerProvider, *tracetest.InMemoryExporter) {
+	exporter := tracetest.NewInMemoryExporter()
+	return sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter)), exporter
+}
+
+// findSpan returns the span of the kind.
+func findSpan(t *testing.T, spans tracetest.SpanStubs, kind trace.SpanKind) tracetest.SpanStub {
+	t.Helper()
+
+	for _, span := range spans {
+		if span.SpanKind == kind {
+			return span
+		}
+	}
+
+	t.Fatalf("span kind %s not found in %d spans", kind, len(spans))
+	return tracetest.SpanStub{}
+}
+
+func TestUnaryTracing(t *testing.T) {
+	tp, exporter := newTestProvider()
+	g := &greeter{}
+	conn := newTestConn(t, g, WithTracerProvider(tp))
+
+	ctx, parent := tp.Tracer("test").Start(context.Background(), "parent")
+	_, err := pb.NewGreeterClient(conn).SayHello(ctx, &pb.HelloReq{Name: "heige"})
+	parent.End()
+	if err != nil {
+		t.Fatalf("say hello error: %v", err)
+	}
+
+	spans := exporter.GetSpans()
+	client := findSpan(t, spans, trace.SpanKindClient)
+	server := findSpan(t, spans, trace.SpanKindServer)
+	if client.Name != "Hello.Greeter/SayHello" || server.Name != client.Name {
+		t.Fatalf("unexpected span names: client %s server %s", client.Name, server.Name)
+	}
+
+	// parent -> client -> server
+	if client.Parent.SpanID() != parent.SpanContext().SpanID() {
+		t.Fatalf("client span parent = %s, want %s", client.Parent.SpanID(), parent.SpanContext().SpanID())
+	}
+	if server.Parent.SpanID() != client.SpanContext.SpanID() || !server.Parent.IsRemote() {
+		t.Fatalf("server span parent = %v, want remote %s", server.Parent, client.SpanContext.SpanID())
+	}
+	if g.spanContext.SpanID() != server.SpanContext.SpanID() {
+		t.Fatalf("handler span = %s, want %s", g.spanContext.SpanID(), server.SpanContext.SpanID())
+	}
+}
+
+func TestUnaryTracingError(t *testing.T) {
+	tp, exporter := newTestProvider()
+	conn := newTestConn(t, pb.UnimplementedGreeterServer{}, WithTracerProvider(tp))
+
+	_, err := pb.NewGreeterClient(conn).SayHello(context.Background(), &pb.HelloReq{Name: "heige"})
+	if err == nil {
+		t.Fatalf("expected unimplemented error")
+	}
+
+	for _, span := range exporter.GetSpans() {
+		if span.Status.Code != codes.Error {
+			t.Fatalf("span %s status = %v, want error", span.SpanKind, span.Status)
+		}
+	}
+}
+
+func TestStreamTracing(t *testing.T) {
+	tp, exporter := newTestProvider()
+	conn := newTestConn(t, &greeter{}, WithTracerProvider(tp),
+		WithSkipMethods("/grpc.health.v1.Health/Check"))
+	client := healthpb.NewHealthClient(conn)
+
+	if _, err := client.Check(context.Background(), &healthpb.HealthCheckRequest{}); err != nil {
+		t.Fatal(err)
+	}
+	if n := len(exporter.GetSpans()); n != 0 {
+		t.Fatalf("skip method spans = %d, want 0", n)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	stream, err := client.Watch(ctx, &healthpb.HealthCheckRequest{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = stream.Recv(); err != nil {
+		t.Fatalf("watch recv error: %v", err)
+	}
+
+	// the client span ends when the stream is canceled
+	cancel()
+	if _, err = stream.Recv(); err == nil {
+		t.Fatalf("expected canceled error")
+	}
+
+	span := findSpan(t, exporter.GetSpans(), trace.SpanKindClient)
+	if span.Name != "grpc.health.v1.Health/Watch" || span.Status.Code != codes.Error {
+		t.Fatalf("unexpected stream client span: %s %v", span.Name, span.Status)
+	}
+}
+
+func TestGatewayAnnotator(t *testing.T) {
+	r, _ := http.NewRequest(http.MethodGet, "/v1/say/heige", nil)
+	r.Header.Set("traceparent", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+
+	md := GatewayAnnotator()(context.Background(), r)
+	if got := md.Get("traceparent"); len(got) != 1 || got[0] != r.Header.Get("traceparent") {
+		t.Fatalf("traceparent metadata = %v", got)
+	}
+
+	// the trace context of the annotator metadata is extracted by the server interceptor
+	tp, exporter := newTestProvider()
+	interceptor := UnaryServerInterceptor(WithTracerProvider(tp))
+	ctx := metadata.NewIncomingContext(context.Background(), md)
+	_, err := interceptor(ctx, nil, &grpc.UnaryServerInfo{FullMethod: "/Hello.Greeter/SayHello"},
+		func(ctx context.Context, _ interface{}) (interface{}, error) {
+			return nil, nil
+		})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	span := findSpan(t, exporter.GetSpans(), trace.SpanKindServer)
+	if span.SpanContext.TraceID().String() != "4bf92f3577b34da6a3ce929d0e0e4736" ||
+		span.Parent.SpanID().String() != "00f067aa0ba902b7" {
+		t.Fatalf("server span is not a child of traceparent: %v", span.Parent)
+	}
+
+	// no trace context in the request headers
+	r.Header.Del("traceparent")
+	if md = GatewayAnnotator()(context.Background(), r); len(md) != 0 {
+		t.Fatalf("unexpected metadata: %v", md)
+	}
+}
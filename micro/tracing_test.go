@@ -0,0 +1,51 @@
+package micro
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/daheige/hephfx/example/pb"
+	"github.com/daheige/hephfx/micro/tracing"
+)
+
+func TestWithTracing(t *testing.T) {
+	exporter := tracetest.NewInMemoryExporter()
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter))
+	greeter := &testGreeter{}
+	s := newTestService(t, greeter,
+		WithHandlerFromEndpoints(pb.RegisterGreeterHandlerFromEndpoint),
+		WithTracing(tracing.WithTracerProvider(tp)),
+	)
+
+	handler, err := s.HTTPHandler()
+	if err != nil {
+		t.Fatalf("http handler error: %v", err)
+	}
+
+	// the trace context of the http request is carried to the gRPC server span
+	r := httptest.NewRequest(http.MethodGet, "/v1/say/heige", nil)
+	r.Header.Set("traceparent", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, r)
+	if w.Code != http.StatusOK {
+		t.Fatalf("gateway response code:%d body:%s", w.Code, w.Body.String())
+	}
+
+	spans := exporter.GetSpans()
+	if len(spans) != 1 || spans[0].SpanKind != trace.SpanKindServer {
+		t.Fatalf("spans = %v, want 1 server span", spans)
+	}
+
+	sc := trace.SpanContextFromContext(greeter.lastContext())
+	if sc.TraceID().String() != "4bf92f3577b34da6a3ce929d0e0e4736" || sc.SpanID() != spans[0].SpanContext.SpanID() {
+		t.Fatalf("handler span context = %s/%s", sc.TraceID(), sc.SpanID())
+	}
+	if spans[0].Parent.SpanID().String() != "00f067aa0ba902b7" {
+		t.Fatalf("server span parent = %s", spans[0].Parent.SpanID())
+	}
+}
@@ -0,0 +1,298 @@
+package micro
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+
+	ut "github.com/go-playground/universal-translator"
+	"github.com/go-playground/validator/v10"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+
+	"github.com/daheige/hephfx/micro/gerrors"
+)
+
+// ValidatorOption request validator option
+type ValidatorOption func(v *requestValidator)
+
+// requestValidator validates the requests which implement ValidateAll() error,
+// Validate(all bool) error or Validate() error,
+// the validator.ValidationErrors are translated into the BadRequest field violations.
+type requestValidator struct {
+	translator    *ut.UniversalTranslator
+	useProtoNames bool
+}
+
+// WithValidatorTranslator returns a ValidatorOption to translate the field violations,
+// the locale is chosen by the accept-language metadata or the fallback locale of the translator.
+// The translations must be registered to the validator of the pb package,
+// eg: en_translations.RegisterDefaultTranslations(pb.Validator(), trans)
+func WithValidatorTranslator(translator *ut.UniversalTranslator) ValidatorOption {
+	return func(v *requestValidator) {
+		v.translator = translator
+	}
+}
+
+// WithValidatorUseProtoNames returns a ValidatorOption to use the proto field names
+// instead of the proto json names in the field violations,eg: user_id instead of userId
+func WithValidatorUseProtoNames() ValidatorOption {
+	return func(v *requestValidator) {
+		v.useProtoNames = true
+	}
+}
+
+func newRequestValidator(opts ...ValidatorOption) *requestValidator {
+	v := &requestValidator{}
+	for _, o := range opts {
+		o(v)
+	}
+
+	return v
+}
+
+// ValidatorUnaryInterceptor returns a server unary interceptor which validates the requests,
+// the invalid requests are rejected with codes.InvalidArgument and the BadRequest details.
+func ValidatorUnaryInterceptor(opts ...ValidatorOption) grpc.UnaryServerInterceptor {
+	v := newRequestValidator(opts...)
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo,
+		handler grpc.UnaryHandler) (interface{}, error) {
+		if err := v.validate(ctx, req); err != nil {
+			return nil, err
+		}
+
+		return handler(ctx, req)
+	}
+}
+
+// ValidatorStreamInterceptor returns a server stream interceptor which validates
+// the received messages of the streams.
+func ValidatorStreamInterceptor(opts ...ValidatorOption) grpc.StreamServerInterceptor {
+	v := newRequestValidator(opts...)
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		return handler(srv, &validatorServerStream{ServerStream: ss, validator: v})
+	}
+}
+
+// validatorServerStream validates the received messages.
+type validatorServerStream struct {
+	grpc.ServerStream
+	validator *requestValidator
+}
+
+// RecvMsg receives and validates the message.
+func (s *validatorServerStream) RecvMsg(m interface{}) error {
+	if err := s.ServerStream.RecvMsg(m); err != nil {
+		return err
+	}
+
+	return s.validator.validate(s.Context(), m)
+}
+
+// validate validates the request,returns the status error with the BadRequest details.
+// All the violations are reported by ValidateAll() error or Validate(true) when they are implemented,
+// eg: the messages generated by protoc-gen-validate.
+func (v *requestValidator) validate(ctx context.Context, req interface{}) error {
+	var err error
+	switch r := req.(type) {
+	case interface{ ValidateAll() error }:
+		err = r.ValidateAll()
+	case interface{ Validate(all bool) error }:
+		err = r.Validate(true)
+	case interface{ Validate() error }:
+		err = r.Validate()
+	default:
+		return nil
+	}
+
+	if err == nil {
+		return nil
+	}
+
+	var fieldErrors validator.ValidationErrors
+	if !errors.As(err, &fieldErrors) {
+		return reasonFieldViolations(err)
+	}
+
+	var (
+		trans  ut.Translator
+		locale string
+	)
+	if v.translator != nil {
+		trans, _ = v.translator.FindTranslator(acceptLanguages(ctx)...)
+		locale = trans.Locale()
+	}
+
+	e := gerrors.New(codes.InvalidArgument, err.Error())
+	descriptions := make([]string, 0, len(fieldErrors))
+	for _, fe := range fieldErrors {
+		description := fieldDescription(fe)
+		if trans != nil {
+			description = fe.Translate(trans)
+		}
+
+		descriptions = append(descriptions, description)
+		e.WithFieldViolation(v.fieldPath(reflect.ValueOf(req), fe.StructNamespace()), description)
+	}
+
+	if trans != nil {
+		e.WithLocalizedMessage(locale, strings.Join(descriptions, "; "))
+	}
+
+	return e
+}
+
+// fieldReasonError is the field error which has the reason,
+// eg: the validation errors generated by protoc-gen-validate.
+type fieldReasonError interface {
+	Field() string
+	Reason() string
+}
+
+// reasonFieldViolations returns the InvalidArgument error of err,
+// the field errors with the reasons are added as the BadRequest field violations,
+// the multiple errors are unwrapped by AllErrors() []error or Unwrap() []error.
+func reasonFieldViolations(err error) error {
+	var errs []error
+	switch e := err.(type) {
+	case interface{ AllErrors() []error }:
+		errs = e.AllErrors()
+	case interface{ Unwrap() []error }:
+		errs = e.Unwrap()
+	default:
+		errs = []error{err}
+	}
+
+	e := gerrors.New(codes.InvalidArgument, err.Error())
+	for _, err := range errs {
+		var fe fieldReasonError
+		if errors.As(err, &fe) {
+			e.WithFieldViolation(fe.Field(), fe.Reason())
+		}
+	}
+
+	return e
+}
+
+// fieldPath converts the struct namespace of the field error to the proto field path,
+// the oneof wrappers are resolved by the request value,
+// eg: HelloReq.UserInfo.Tags[0] => userInfo.tags[0], HelloReq.Contact.Email => email
+func (v *requestValidator) fieldPath(req reflect.Value, namespace string) string {
+	segments := strings.Split(namespace, ".")
+	path := make([]string, 0, len(segments))
+	value := req
+	for _, segment := range segments[1:] { // the first segment is the struct name
+		name, index, _ := strings.Cut(segment, "[")
+		for value.Kind() == reflect.Ptr || value.Kind() == reflect.Interface {
+			value = value.Elem()
+		}
+
+		if value.Kind() == reflect.Struct {
+			if f, ok := value.Type().FieldByName(name); ok {
+				value = value.FieldByIndex(f.Index)
+				if f.Tag.Get("protobuf_oneof") != "" {
+					// the oneof field is the wrapper of the actual field,eg: Contact => Email
+					continue
+				}
+
+				name = v.protoFieldName(f)
+			} else {
+				value = reflect.Value{}
+			}
+		}
+
+		if index != "" {
+			name += "[" + index
+			value = elemValue(value, strings.TrimSuffix(index, "]"))
+		}
+
+		path = append(path, name)
+	}
+
+	return strings.Join(path, ".")
+}
+
+// elemValue returns the element of the slice or the map by the index of the namespace.
+func elemValue(value reflect.Value, index string) reflect.Value {
+	switch value.Kind() {
+	case reflect.Slice, reflect.Array:
+		i, err := strconv.Atoi(index)
+		if err != nil || i < 0 || i >= value.Len() {
+			return reflect.Value{}
+		}
+
+		return value.Index(i)
+	case reflect.Map:
+		if value.Type().Key().Kind() != reflect.String {
+			return reflect.Value{}
+		}
+
+		return value.MapIndex(reflect.ValueOf(index).Convert(value.Type().Key()))
+	default:
+		return reflect.Value{}
+	}
+}
+
+// protoFieldName returns the proto json name or the proto name of the struct field
+// by the protobuf tag,eg: `protobuf:"bytes,1,opt,name=user_id,json=userId,proto3"`
+func (v *requestValidator) protoFieldName(f reflect.StructField) string {
+	var name, jsonName string
+	for _, part := range strings.Split(f.Tag.Get("protobuf"), ",") {
+		if value, ok := strings.CutPrefix(part, "name="); ok {
+			name = value
+		} else if value, ok := strings.CutPrefix(part, "json="); ok {
+			jsonName = value
+		}
+	}
+
+	switch {
+	case name == "":
+		return f.Name
+	case v.useProtoNames || jsonName == "":
+		return name
+	default:
+		return jsonName
+	}
+}
+
+// fieldDescription returns the description of the field error without the go field names.
+func fieldDescription(fe validator.FieldError) string {
+	if fe.Param() != "" {
+		return fmt.Sprintf("failed on the '%s' tag with param '%s'", fe.Tag(), fe.Param())
+	}
+
+	return fmt.Sprintf("failed on the '%s' tag", fe.Tag())
+}
+
+// acceptLanguages returns the locales of the accept-language metadata,
+// eg: zh-CN,zh;q=0.9,en;q=0.8 => zh_cn,zh,en
+func acceptLanguages(ctx context.Context) []string {
+	md, _ := metadata.FromIncomingContext(ctx)
+	values := md.Get("accept-language")
+	if len(values) == 0 {
+		// grpc-gateway forwards the Accept-Language header with the grpcgateway- prefix
+		values = md.Get("grpcgateway-accept-language")
+	}
+
+	var locales []string
+	for _, value := range values {
+		for _, tag := range strings.Split(value, ",") {
+			tag, _, _ = strings.Cut(strings.TrimSpace(tag), ";")
+			if tag == "" || tag == "*" {
+				continue
+			}
+
+			locale := strings.ReplaceAll(tag, "-", "_")
+			locales = append(locales, locale)
+			if base, _, ok := strings.Cut(locale, "_"); ok {
+				locales = append(locales, base)
+			}
+		}
+	}
+
+	return locales
+}
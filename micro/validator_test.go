@@ -0,0 +1,177 @@
+package micro
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/go-playground/locales/en"
+	"github.com/go-playground/locales/zh"
+	ut "github.com/go-playground/universal-translator"
+	"github.com/go-playground/validator/v10"
+	zhTranslations "github.com/go-playground/validator/v10/translations/zh"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+
+	"github.com/daheige/hephfx/example/pb"
+	"github.com/daheige/hephfx/micro/gerrors"
+)
+
+var testValidate = validator.New()
+
+// userItem and userReq are shaped like the protoc-gen-go messages.
+type userItem struct {
+	SkuId string `protobuf:"bytes,1,opt,name=sku_id,json=skuId,proto3" json:"sku_id,omitempty" validate:"required"`
+}
+
+type userReq struct {
+	UserName string      `protobuf:"bytes,1,opt,name=user_name,json=userName,proto3" json:"user_name,omitempty" validate:"required"`
+	Age      int32       `protobuf:"varint,2,opt,name=age,proto3" json:"age,omitempty" validate:"gte=18"`
+	Items    []*userItem `protobuf:"bytes,3,rep,name=items,proto3" json:"items,omitempty" validate:"dive"`
+	Contact  isContact   `protobuf_oneof:"contact"`
+}
+
+type isContact interface {
+	isContact()
+}
+
+type userReqEmail struct {
+	Email string `protobuf:"bytes,4,opt,name=email,proto3,oneof" validate:"email"`
+}
+
+func (*userReqEmail) isContact() {}
+
+func (r *userReq) Validate() error {
+	return testValidate.Struct(r)
+}
+
+func validateUnary(ctx context.Context, req interface{}, opts ...ValidatorOption) error {
+	_, err := ValidatorUnaryInterceptor(opts...)(ctx, req, &grpc.UnaryServerInfo{FullMethod: "/User.User/Create"},
+		func(ctx context.Context, req interface{}) (interface{}, error) {
+			return nil, nil
+		})
+	return err
+}
+
+func TestValidatorFieldViolations(t *testing.T) {
+	req := &userReq{Age: 10, Items: []*userItem{{SkuId: "1"}, {}}, Contact: &userReqEmail{Email: "heige"}}
+	err := validateUnary(context.Background(), req)
+	if status.Code(err) != codes.InvalidArgument {
+		t.Fatalf("expected InvalidArgument,got:%v", err)
+	}
+
+	want := map[string]string{
+		"userName":       "failed on the 'required' tag",
+		"age":            "failed on the 'gte' tag with param '18'",
+		"items[1].skuId": "failed on the 'required' tag",
+		"email":          "failed on the 'email' tag",
+	}
+	violations := gerrors.FieldViolations(err)
+	if len(violations) != len(want) {
+		t.Fatalf("violations = %v", violations)
+	}
+	for _, v := range violations {
+		if want[v.GetField()] != v.GetDescription() {
+			t.Errorf("violation %s: %s", v.GetField(), v.GetDescription())
+		}
+	}
+
+	err = validateUnary(context.Background(), req, WithValidatorUseProtoNames())
+	if field := gerrors.FieldViolations(err)[2].GetField(); field != "items[1].sku_id" {
+		t.Fatalf("proto name field = %s", field)
+	}
+
+	if err = validateUnary(context.Background(), &userReq{UserName: "heige", Age: 18}); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// pgvFieldError and pgvMultiError are shaped like the protoc-gen-validate errors.
+type pgvFieldError struct {
+	field  string
+	reason string
+}
+
+func (e pgvFieldError) Field() string  { return e.field }
+func (e pgvFieldError) Reason() string { return e.reason }
+func (e pgvFieldError) Error() string  { return "invalid " + e.field + ": " + e.reason }
+
+type pgvMultiError []error
+
+func (m pgvMultiError) Error() string      { return errors.Join(m...).Error() }
+func (m pgvMultiError) AllErrors() []error { return m }
+
+// pgvReq reports the first violation by Validate() and all of them by ValidateAll().
+type pgvReq struct{}
+
+func (r *pgvReq) Validate() error {
+	return pgvFieldError{field: "name", reason: "value length must be at least 1 runes"}
+}
+
+func (r *pgvReq) ValidateAll() error {
+	return pgvMultiError{
+		pgvFieldError{field: "name", reason: "value length must be at least 1 runes"},
+		pgvFieldError{field: "age", reason: "value must be greater than or equal to 18"},
+	}
+}
+
+// allFlagReq reports all the violations when all is true.
+type allFlagReq struct{}
+
+func (r *allFlagReq) Validate(all bool) error {
+	if !all {
+		return errors.New("name is required")
+	}
+
+	return errors.Join(
+		pgvFieldError{field: "name", reason: "required"},
+		pgvFieldError{field: "age", reason: "too young"},
+		errors.New("custom rule failed"),
+	)
+}
+
+func TestValidatorValidateAll(t *testing.T) {
+	for name, req := range map[string]interface{}{"ValidateAll": &pgvReq{}, "Validate(all)": &allFlagReq{}} {
+		err := validateUnary(context.Background(), req)
+		if status.Code(err) != codes.InvalidArgument {
+			t.Fatalf("%s: expected InvalidArgument,got:%v", name, err)
+		}
+
+		violations := gerrors.FieldViolations(err)
+		if len(violations) != 2 || violations[0].GetField() != "name" || violations[1].GetField() != "age" {
+			t.Fatalf("%s: violations = %v", name, violations)
+		}
+	}
+}
+
+func TestValidatorTranslator(t *testing.T) {
+	zhLocale := zh.New()
+	uni := ut.New(en.New(), zhLocale)
+	trans, _ := uni.GetTranslator("zh")
+	if err := zhTranslations.RegisterDefaultTranslations(testValidate, trans); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx := metadata.NewIncomingContext(context.Background(), metadata.Pairs("accept-language", "zh-CN,zh;q=0.9"))
+	err := validateUnary(ctx, &userReq{Age: 18}, WithValidatorTranslator(uni))
+
+	d, _ := gerrors.FromError(err)
+	if v := d.BadRequest.GetFieldViolations(); len(v) != 1 || v[0].GetDescription() != "UserName为必填字段" {
+		t.Fatalf("violations = %v", v)
+	}
+	if d.LocalizedMessage.GetLocale() != "zh" {
+		t.Fatalf("localized message = %v", d.LocalizedMessage)
+	}
+}
+
+func TestWithRequestValidator(t *testing.T) {
+	s := newTestService(t, &testGreeter{}, WithRequestValidator())
+
+	conn := dialService(t, s)
+	_, err := pb.NewGreeterClient(conn).SayHello(context.Background(), &pb.HelloReq{})
+	if v := gerrors.FieldViolations(err); len(v) != 1 || v[0].GetField() != "name" {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
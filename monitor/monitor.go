@@ -42,6 +42,16 @@ var HdFailures = prometheus.NewCounterVec(
 	[]string{"device"},
 )
 
+// GRPCDeadlineExceededTotal grpc_server_deadline_exceeded_total，counter类型指标
+// 表示gRPC请求超过截止时间的次数，标签为gRPC方法全名
+var GRPCDeadlineExceededTotal = prometheus.NewCounterVec(
+	prometheus.CounterOpts{
+		Name: "grpc_server_deadline_exceeded_total",
+		Help: "Number of gRPC requests which exceed the deadline",
+	},
+	[]string{"grpc_method"},
+)
+
 // MonitorHandlerFunc 对于http原始的处理器函数，包装 handler function,不侵入业务逻辑
 // 可以对单个接口做metrics监控
 func MonitorHandlerFunc(h http.HandlerFunc) http.HandlerFunc {
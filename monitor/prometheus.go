@@ -22,6 +22,7 @@ func InitMonitor(port uint16, isWeb ...bool) {
 
 	prometheus.MustRegister(CpuTemp)
 	prometheus.MustRegister(HdFailures)
+	prometheus.MustRegister(GRPCDeadlineExceededTotal)
 
 	// 性能监控的端口port+1000,只能在内网访问
 	httpMux := gpprof.New()
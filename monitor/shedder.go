@@ -0,0 +1,79 @@
+package monitor
+
+import (
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// ShedderStats 自适应限流器的状态
+type ShedderStats struct {
+	InFlight    int64         // 当前正在处理的请求数
+	MaxInFlight int64         // 根据窗口内最大通过数与最小耗时估算的最大并发数
+	MinRT       time.Duration // 窗口内的最小平均耗时
+	CPUUsage    float64       // 进程cpu使用率，范围[0,1]
+	Dropping    bool          // 是否正在丢弃请求
+	Dropped     uint64        // 丢弃的请求总数
+}
+
+// shedderCollector 在采集指标时读取自适应限流器的状态
+type shedderCollector struct {
+	stats       func() ShedderStats
+	inFlight    *prometheus.Desc
+	maxInFlight *prometheus.Desc
+	minRT       *prometheus.Desc
+	cpuUsage    *prometheus.Desc
+	dropping    *prometheus.Desc
+	dropped     *prometheus.Desc
+}
+
+// NewShedderCollector 创建自适应限流器的指标采集器，name 作为 shedder 标签的值
+func NewShedderCollector(name string, stats func() ShedderStats) prometheus.Collector {
+	labels := prometheus.Labels{"shedder": name}
+	return &shedderCollector{
+		stats: stats,
+		inFlight: prometheus.NewDesc("shedder_in_flight",
+			"Number of in-flight requests of the load shedder", nil, labels),
+		maxInFlight: prometheus.NewDesc("shedder_max_in_flight",
+			"Estimated max in-flight requests of the load shedder", nil, labels),
+		minRT: prometheus.NewDesc("shedder_min_rt_seconds",
+			"Min windowed response time of the load shedder", nil, labels),
+		cpuUsage: prometheus.NewDesc("shedder_cpu_usage",
+			"Process cpu usage seen by the load shedder,range: [0,1]", nil, labels),
+		dropping: prometheus.NewDesc("shedder_dropping",
+			"Whether the load shedder is dropping requests", nil, labels),
+		dropped: prometheus.NewDesc("shedder_dropped_total",
+			"Number of requests dropped by the load shedder", nil, labels),
+	}
+}
+
+// Describe 实现 prometheus.Collector 接口
+func (c *shedderCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.inFlight
+	ch <- c.maxInFlight
+	ch <- c.minRT
+	ch <- c.cpuUsage
+	ch <- c.dropping
+	ch <- c.dropped
+}
+
+// Collect 实现 prometheus.Collector 接口
+func (c *shedderCollector) Collect(ch chan<- prometheus.Metric) {
+	stats := c.stats()
+	var dropping float64
+	if stats.Dropping {
+		dropping = 1
+	}
+
+	ch <- prometheus.MustNewConstMetric(c.inFlight, prometheus.GaugeValue, float64(stats.InFlight))
+	ch <- prometheus.MustNewConstMetric(c.maxInFlight, prometheus.GaugeValue, float64(stats.MaxInFlight))
+	ch <- prometheus.MustNewConstMetric(c.minRT, prometheus.GaugeValue, stats.MinRT.Seconds())
+	ch <- prometheus.MustNewConstMetric(c.cpuUsage, prometheus.GaugeValue, stats.CPUUsage)
+	ch <- prometheus.MustNewConstMetric(c.dropping, prometheus.GaugeValue, dropping)
+	ch <- prometheus.MustNewConstMetric(c.dropped, prometheus.CounterValue, float64(stats.Dropped))
+}
+
+// RegisterShedder 注册自适应限流器的指标，通过 InitMonitor 提供的 /metrics 接口输出
+func RegisterShedder(name string, stats func() ShedderStats) error {
+	return prometheus.Register(NewShedderCollector(name, stats))
+}
@@ -14,3 +14,10 @@ type Config interface {
 	// Store save config to file
 	Store(path string) error
 }
+
+// Watcher is implemented by the Config which watches the config file,
+// eg: the Config created with WithWatchFile option.
+type Watcher interface {
+	// OnChange registers fn to be called after the config sections are reloaded
+	OnChange(fn func())
+}
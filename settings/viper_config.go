@@ -5,6 +5,7 @@ import (
 	"log"
 	"path/filepath"
 	"strings"
+	"sync"
 
 	"github.com/fsnotify/fsnotify"
 	"github.com/spf13/viper"
@@ -54,6 +55,9 @@ type viperConfig struct {
 	configFile string
 	watchFile  bool
 	sections   map[string]interface{}
+
+	mu        sync.Mutex
+	onChanges []func()
 }
 
 // Load load config
@@ -120,16 +124,37 @@ func (c *viperConfig) reload() error {
 	return nil
 }
 
+// OnChange registers fn to be called after the config sections are reloaded
+func (c *viperConfig) OnChange(fn func()) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.onChanges = append(c.onChanges, fn)
+}
+
+func (c *viperConfig) notifyChange() {
+	c.mu.Lock()
+	fns := append([]func(){}, c.onChanges...)
+	c.mu.Unlock()
+
+	for _, fn := range fns {
+		fn()
+	}
+}
+
 // when the configuration file is changed, reload all the configured keys/values
 func (c *viperConfig) watch() {
-	go func() {
-		c.vp.WatchConfig()
-		c.vp.OnConfigChange(func(in fsnotify.Event) {
-			log.Println("config file has changed...")
-			err := c.reload()
-			if err != nil {
-				log.Printf("read all config section err:%s\n", err.Error())
-			}
-		})
-	}()
+	// the change handler must be registered before watching,
+	// otherwise it races with the watcher goroutine
+	c.vp.OnConfigChange(func(in fsnotify.Event) {
+		log.Println("config file has changed...")
+		err := c.reload()
+		if err != nil {
+			log.Printf("read all config section err:%s\n", err.Error())
+			return
+		}
+
+		c.notifyChange()
+	})
+	c.vp.WatchConfig()
 }